@@ -0,0 +1,218 @@
+// Package cfg loads the config of the bots from the json file, every value
+// can be overridden by the environment variable listed in env.go
+package cfg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPath        = "./cfg/config.json"
+	defaultRedisAddr   = "127.0.0.1:6379"
+	defaultMetricsPort = 7011
+	defaultRateLimit   = 120
+	defaultUpdateTime  = 30  // seconds
+	defaultTgTimeout   = 120 // seconds
+	defaultSQLitePath  = "./data"
+
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+
+	StateStoreRedis  = "redis"
+	StateStoreMemory = "memory"
+
+	UpdateModePolling = "polling"
+	UpdateModeWebhook = "webhook"
+)
+
+// App is the config of the running process, filled by Init
+var App = &Config{}
+
+type Config struct {
+	Bots  map[string]*Bot `json:"bots"`
+	DB    DB              `json:"db"`
+	Redis Redis           `json:"redis"`
+
+	// StateStore keeps the levels of the users: redis or memory, redis is used when it is empty.
+	// The memory store is also used when redis is not available at the start
+	StateStore string `json:"state_store"`
+
+	// UpdateMode is polling or webhook, polling is used when it is empty.
+	// The webhooks are served on the metrics port
+	UpdateMode string  `json:"update_mode"`
+	Webhook    Webhook `json:"webhook"`
+
+	MetricsPort int `json:"metrics_port"`
+
+	// UpdateTimeout is the deadline of the handler in seconds, the database and redis
+	// calls of the update are canceled after it and the handler is reported
+	UpdateTimeout int `json:"update_timeout"`
+	// TelegramTimeout limits one request to telegram in seconds, it is longer than the update
+	// deadline because the long polling, the mailings and the uploads wait longer
+	TelegramTimeout int `json:"telegram_timeout"`
+
+	// RateLimit is the most updates of one user per minute, the rest are dropped. The admins are not limited.
+	// 0 is the default limit, the negative limit turns the limiting off
+	RateLimit int `json:"rate_limit"`
+
+	// DeveloperChats get the errors and the notifications of the bots
+	DeveloperChats []int64 `json:"developer_chats"`
+	// SpecialIDs get the special possibilities when they become the admins and are hidden in the admin list
+	SpecialIDs []int64 `json:"special_ids"`
+	// MaintenanceIDs use the bots during the maintenance
+	MaintenanceIDs []int64 `json:"maintenance_ids"`
+}
+
+type Bot struct {
+	Token     string   `json:"bot_token"`
+	Link      string   `json:"bot_link"`
+	Languages []string `json:"language_in_bot"`
+	Timezone  string   `json:"timezone"`
+	DBName    string   `json:"db_name"`
+}
+
+type DB struct {
+	// Driver is mysql or sqlite, mysql is used when it is empty
+	Driver string `json:"driver"`
+	// DSN is the mysql server without the database, like "user:password@tcp(127.0.0.1:3306)/"
+	DSN string `json:"dsn"`
+	// Path is the directory of the sqlite files, one file per bot named by db_name
+	Path string `json:"path"`
+}
+
+type Webhook struct {
+	// URL is the public address of the metrics server, the bot "it" gets the updates on URL/webhook/it
+	URL string `json:"url"`
+	// SecretToken is sent by telegram in every update, the requests without it are rejected
+	SecretToken string `json:"secret_token"`
+}
+
+type Redis struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+// Init loads the config from the path in MINER_CONFIG or from ./cfg/config.json
+func Init() error {
+	path := os.Getenv(envConfigPath)
+	if path == "" {
+		path = defaultPath
+	}
+
+	config, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	App = config
+	return nil
+}
+
+// Load reads the file, applies the environment overrides and validates the result
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read config")
+	}
+
+	config := &Config{}
+	if err = json.Unmarshal(data, config); err != nil {
+		return nil, errors.Wrap(err, "parse config "+path)
+	}
+
+	if err = config.applyEnv(); err != nil {
+		return nil, err
+	}
+	config.setDefaults()
+
+	if err = config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (c *Config) setDefaults() {
+	if c.DB.Driver == "" {
+		c.DB.Driver = DriverMySQL
+	}
+	if c.DB.Driver == DriverSQLite && c.DB.Path == "" {
+		c.DB.Path = defaultSQLitePath
+	}
+	if c.Redis.Addr == "" {
+		c.Redis.Addr = defaultRedisAddr
+	}
+	if c.StateStore == "" {
+		c.StateStore = StateStoreRedis
+	}
+	if c.UpdateMode == "" {
+		c.UpdateMode = UpdateModePolling
+	}
+	if c.MetricsPort == 0 {
+		c.MetricsPort = defaultMetricsPort
+	}
+	if c.UpdateTimeout == 0 {
+		c.UpdateTimeout = defaultUpdateTime
+	}
+	if c.TelegramTimeout == 0 {
+		c.TelegramTimeout = defaultTgTimeout
+	}
+	if c.RateLimit == 0 {
+		c.RateLimit = defaultRateLimit
+	}
+}
+
+// UpdateDeadline is the time given to the handler of the update
+func (c *Config) UpdateDeadline() time.Duration {
+	return time.Duration(c.UpdateTimeout) * time.Second
+}
+
+// TelegramDeadline is the timeout of the http client of telegram
+func (c *Config) TelegramDeadline() time.Duration {
+	return time.Duration(c.TelegramTimeout) * time.Second
+}
+
+// IsSpecial reports whether the user is in the special ids of the config
+func (c *Config) IsSpecial(userID int64) bool {
+	return containsID(c.SpecialIDs, userID)
+}
+
+// IsOwner reports whether the user is in the maintenance ids of the config,
+// the owners use the bots during the maintenance
+func (c *Config) IsOwner(userID int64) bool {
+	return containsID(c.MaintenanceIDs, userID)
+}
+
+func containsID(ids []int64, userID int64) bool {
+	for _, id := range ids {
+		if id == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SQLiteFile returns the file of the sqlite database
+func (d DB) SQLiteFile(dbName string) string {
+	return filepath.Join(d.Path, dbName+".db")
+}
+
+// DataSourceName returns the dsn of the database on the configured mysql server
+func (d DB) DataSourceName(dbName string) string {
+	config, err := mysql.ParseDSN(d.DSN)
+	if err != nil {
+		// the dsn is checked by Validate
+		return d.DSN + dbName
+	}
+
+	config.DBName = dbName
+	return config.FormatDSN()
+}
@@ -0,0 +1,129 @@
+package cfg
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	envConfigPath = "MINER_CONFIG"
+
+	envDBDriver       = "MINER_DB_DRIVER"
+	envDBDSN          = "MINER_DB_DSN"
+	envDBPath         = "MINER_DB_PATH"
+	envRedisAddr      = "MINER_REDIS_ADDR"
+	envRedisPassword  = "MINER_REDIS_PASSWORD"
+	envRedisDB        = "MINER_REDIS_DB"
+	envStateStore     = "MINER_STATE_STORE"
+	envUpdateMode     = "MINER_UPDATE_MODE"
+	envWebhookURL     = "MINER_WEBHOOK_URL"
+	envWebhookSecret  = "MINER_WEBHOOK_SECRET"
+	envMetricsPort    = "MINER_METRICS_PORT"
+	envRateLimit      = "MINER_RATE_LIMIT"
+	envUpdateTimeout  = "MINER_UPDATE_TIMEOUT"
+	envTgTimeout      = "MINER_TELEGRAM_TIMEOUT"
+	envDeveloperChats = "MINER_DEVELOPER_CHATS" // comma separated
+	envSpecialIDs     = "MINER_SPECIAL_IDS"     // comma separated
+	envMaintenanceIDs = "MINER_MAINTENANCE_IDS" // comma separated
+
+	// the token of the bot "it" is read from MINER_BOT_IT_TOKEN
+	envBotPrefix      = "MINER_BOT_"
+	envBotTokenSuffix = "_TOKEN"
+)
+
+// applyEnv overrides the values of the file by the set environment variables
+func (c *Config) applyEnv() error {
+	var err error
+
+	setString(&c.DB.Driver, envDBDriver)
+	setString(&c.DB.DSN, envDBDSN)
+	setString(&c.DB.Path, envDBPath)
+	setString(&c.Redis.Addr, envRedisAddr)
+	setString(&c.Redis.Password, envRedisPassword)
+	setString(&c.StateStore, envStateStore)
+	setString(&c.UpdateMode, envUpdateMode)
+	setString(&c.Webhook.URL, envWebhookURL)
+	setString(&c.Webhook.SecretToken, envWebhookSecret)
+
+	if err = setInt(&c.Redis.DB, envRedisDB); err != nil {
+		return err
+	}
+	if err = setInt(&c.MetricsPort, envMetricsPort); err != nil {
+		return err
+	}
+	if err = setInt(&c.RateLimit, envRateLimit); err != nil {
+		return err
+	}
+	if err = setInt(&c.UpdateTimeout, envUpdateTimeout); err != nil {
+		return err
+	}
+	if err = setInt(&c.TelegramTimeout, envTgTimeout); err != nil {
+		return err
+	}
+	if err = setIDs(&c.DeveloperChats, envDeveloperChats); err != nil {
+		return err
+	}
+	if err = setIDs(&c.SpecialIDs, envSpecialIDs); err != nil {
+		return err
+	}
+	if err = setIDs(&c.MaintenanceIDs, envMaintenanceIDs); err != nil {
+		return err
+	}
+
+	for lang, bot := range c.Bots {
+		if bot == nil {
+			continue
+		}
+		setString(&bot.Token, envBotPrefix+strings.ToUpper(lang)+envBotTokenSuffix)
+	}
+
+	return nil
+}
+
+func setString(value *string, key string) {
+	if env, ok := os.LookupEnv(key); ok {
+		*value = env
+	}
+}
+
+func setInt(value *int, key string) error {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	number, err := strconv.Atoi(strings.TrimSpace(env))
+	if err != nil {
+		return errors.Errorf("%s must be a number, got %q", key, env)
+	}
+
+	*value = number
+	return nil
+}
+
+func setIDs(value *[]int64, key string) error {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	ids := make([]int64, 0)
+	for _, field := range strings.Split(env, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return errors.Errorf("%s must be the comma separated ids, got %q", key, field)
+		}
+		ids = append(ids, id)
+	}
+
+	*value = ids
+	return nil
+}
@@ -0,0 +1,156 @@
+package cfg
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// secretTokenRe are the characters telegram allows in the secret token of the webhook
+var secretTokenRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// ValidationError lists every problem of the config, so all of them are fixed at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+func (e *ValidationError) add(format string, values ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, values...))
+}
+
+// Validate checks the config before any bot is started
+func (c *Config) Validate() error {
+	result := &ValidationError{}
+
+	c.validateBots(result)
+	c.validateDB(result)
+
+	if c.Redis.Addr == "" {
+		result.add("redis.addr is empty")
+	}
+	if c.Redis.DB < 0 {
+		result.add("redis.db must not be negative, got %d", c.Redis.DB)
+	}
+	if c.StateStore != StateStoreRedis && c.StateStore != StateStoreMemory {
+		result.add("state_store must be %s or %s, got %q", StateStoreRedis, StateStoreMemory, c.StateStore)
+	}
+
+	c.validateUpdates(result)
+
+	if c.MetricsPort <= 0 || c.MetricsPort > 65535 {
+		result.add("metrics_port must be from 1 to 65535, got %d", c.MetricsPort)
+	}
+	if c.UpdateTimeout < 0 {
+		result.add("update_timeout must be positive, got %d", c.UpdateTimeout)
+	}
+	if c.TelegramTimeout < 0 {
+		result.add("telegram_timeout must be positive, got %d", c.TelegramTimeout)
+	}
+	if len(c.DeveloperChats) == 0 {
+		result.add("developer_chats is empty, set it in the file or in %s", envDeveloperChats)
+	}
+
+	if len(result.Problems) != 0 {
+		return result
+	}
+
+	return nil
+}
+
+func (c *Config) validateDB(result *ValidationError) {
+	switch c.DB.Driver {
+	case DriverMySQL:
+		if c.DB.DSN == "" {
+			result.add("db.dsn is empty, set it in the file or in %s", envDBDSN)
+		} else if dsn, err := mysql.ParseDSN(c.DB.DSN); err != nil {
+			result.add("db.dsn is invalid: %s", err.Error())
+		} else if dsn.DBName != "" {
+			result.add("db.dsn must not contain the database, it is taken from db_name of the bot")
+		}
+	case DriverSQLite:
+		if c.DB.Path == "" {
+			result.add("db.path is empty, set it in the file or in %s", envDBPath)
+		}
+	default:
+		result.add("db.driver must be %s or %s, got %q", DriverMySQL, DriverSQLite, c.DB.Driver)
+	}
+}
+
+func (c *Config) validateUpdates(result *ValidationError) {
+	switch c.UpdateMode {
+	case UpdateModePolling:
+		return
+	case UpdateModeWebhook:
+	default:
+		result.add("update_mode must be %s or %s, got %q", UpdateModePolling, UpdateModeWebhook, c.UpdateMode)
+		return
+	}
+
+	if c.Webhook.URL == "" {
+		result.add("webhook.url is empty, set it in the file or in %s", envWebhookURL)
+	} else if link, err := url.Parse(c.Webhook.URL); err != nil || link.Scheme != "https" || link.Host == "" {
+		result.add("webhook.url must be the https address, got %q", c.Webhook.URL)
+	}
+
+	if c.Webhook.SecretToken == "" {
+		result.add("webhook.secret_token is empty, set it in the file or in %s", envWebhookSecret)
+	} else if !secretTokenRe.MatchString(c.Webhook.SecretToken) {
+		result.add("webhook.secret_token must be 1-256 characters of A-Z, a-z, 0-9, _ and -")
+	}
+}
+
+func (c *Config) validateBots(result *ValidationError) {
+	if len(c.Bots) == 0 {
+		result.add("bots is empty")
+		return
+	}
+
+	langs := make([]string, 0, len(c.Bots))
+	for lang := range c.Bots {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	dbNames := make(map[string]string)
+	for _, lang := range langs {
+		bot := c.Bots[lang]
+		if bot == nil {
+			result.add("bots.%s is empty", lang)
+			continue
+		}
+
+		if bot.Token == "" {
+			result.add("bots.%s.bot_token is empty, set it in the file or in %s%s%s",
+				lang, envBotPrefix, strings.ToUpper(lang), envBotTokenSuffix)
+		}
+		if bot.Link == "" {
+			result.add("bots.%s.bot_link is empty", lang)
+		}
+		if len(bot.Languages) == 0 {
+			result.add("bots.%s.language_in_bot is empty", lang)
+		}
+		if bot.Timezone != "" {
+			if _, err := time.LoadLocation(bot.Timezone); err != nil {
+				result.add("bots.%s.timezone is unknown: %s", lang, bot.Timezone)
+			}
+		}
+
+		switch other, taken := dbNames[bot.DBName]; {
+		case bot.DBName == "":
+			result.add("bots.%s.db_name is empty", lang)
+		case taken:
+			result.add("bots.%s.db_name %q is already used by bots.%s", lang, bot.DBName, other)
+		default:
+			dbNames[bot.DBName] = lang
+		}
+	}
+}
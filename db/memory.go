@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+)
+
+// sweepEvery is the number of the writes between the removals of the expired keys,
+// the keys which are never read again would stay in memory without it
+const sweepEvery = 1024
+
+// MemoryStore keeps the state in the process, it is lost on the restart
+type MemoryStore struct {
+	mu     sync.Mutex
+	items  map[string]memoryItem
+	writes int
+}
+
+type memoryItem struct {
+	value   string
+	expires time.Time // zero when the value never expires
+}
+
+func (i memoryItem) expired(now time.Time) bool {
+	return !i.expires.IsZero() && !now.Before(i.expires)
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]memoryItem)}
+}
+
+func (m *MemoryStore) Get(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.items[key]
+	if !ok {
+		return "", model.ErrStateNotFound
+	}
+	if item.expired(time.Now()) {
+		delete(m.items, key)
+		return "", model.ErrStateNotFound
+	}
+
+	return item.value, nil
+}
+
+func (m *MemoryStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, value, ttl)
+	return nil
+}
+
+func (m *MemoryStore) SetNX(_ context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if item, ok := m.items[key]; ok && !item.expired(time.Now()) {
+		return false, nil
+	}
+
+	m.set(key, value, ttl)
+	return true, nil
+}
+
+func (m *MemoryStore) set(key, value string, ttl time.Duration) {
+	item := memoryItem{value: value}
+	if ttl > 0 {
+		item.expires = time.Now().Add(ttl)
+	}
+	m.items[key] = item
+
+	m.writes++
+	if m.writes%sweepEvery == 0 {
+		m.sweep()
+	}
+}
+
+func (m *MemoryStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.items, key)
+	return nil
+}
+
+func (m *MemoryStore) sweep() {
+	now := time.Now()
+	for key, item := range m.items {
+		if item.expired(now) {
+			delete(m.items, key)
+		}
+	}
+}
@@ -1,159 +1,51 @@
 package db
 
 import (
-	"log"
-	"strconv"
+	"context"
 	"time"
 
 	"github.com/Stepan1328/miner-bot/model"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
 )
 
-const (
-	emptyLevelName = "empty"
-)
-
-func RdbSetUser(botLang string, ID int64, level string) {
-	userID := userIDToRdb(botLang, ID)
-	_, err := model.Bots[botLang].Rdb.Set(userID, level, 0).Result()
-	if err != nil {
-		log.Println(err)
-	}
+// RedisStore keeps the state in redis, it survives the restarts of the bot
+type RedisStore struct {
+	rdb *redis.Client
 }
 
-func userIDToRdb(botLang string, userID int64) string {
-	return botLang + ":user:" + strconv.FormatInt(userID, 10)
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
 }
 
-func GetLevel(botLang string, id int64) string {
-	userID := userIDToRdb(botLang, id)
-	have, err := model.Bots[botLang].Rdb.Exists(userID).Result()
-	if err != nil {
-		log.Println(err)
-	}
-	if have == 0 {
-		return emptyLevelName
-	}
-
-	value, err := model.Bots[botLang].Rdb.Get(userID).Result()
-	if err != nil {
-		log.Println(err)
-	}
-	return value
-}
-
-func SaveUserClickerMsgID(botLang string, userID int64, msgID int) {
-	userClickerID := userClickerMsgIDToRdb(botLang, userID)
-	_, err := model.Bots[botLang].Rdb.Set(userClickerID, strconv.Itoa(msgID), 0).Result()
-	if err != nil {
-		log.Println(err)
+func (r *RedisStore) Get(ctx context.Context, key string) (string, error) {
+	value, err := r.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", model.ErrStateNotFound
 	}
-
-	UpdateClickerMsgTTL(botLang, userID)
-}
-
-func UpdateClickerMsgTTL(botLang string, userID int64) {
-	userClickerID := userClickerMsgIDToRdb(botLang, userID)
-
-	_, err := model.Bots[botLang].Rdb.Expire(userClickerID, time.Minute*3).Result()
 	if err != nil {
-		log.Println(err)
+		return "", errors.Wrap(err, "get "+key)
 	}
-}
 
-func userClickerMsgIDToRdb(botLang string, userID int64) string {
-	return botLang + ":user_clicker_id:" + strconv.FormatInt(userID, 10)
+	return value, nil
 }
 
-func GetUserClickerMsgID(botLang string, userID int64) int {
-	userClickerID := userClickerMsgIDToRdb(botLang, userID)
-	result, err := model.Bots[botLang].Rdb.Get(userClickerID).Result()
-	if err != nil {
-		log.Println(err)
-	}
-	msgID, _ := strconv.Atoi(result)
-	return msgID
+func (r *RedisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	err := r.rdb.Set(ctx, key, value, ttl).Err()
+	return errors.Wrap(err, "set "+key)
 }
 
-func RdbSetAdminMsgID(botLang string, userID int64, msgID int) {
-	adminMsgID := adminMsgIDToRdb(botLang, userID)
-	_, err := model.Bots[botLang].Rdb.Set(adminMsgID, strconv.Itoa(msgID), 0).Result()
-	if err != nil {
-		log.Println(err)
-	}
-}
-
-func adminMsgIDToRdb(botLang string, userID int64) string {
-	return botLang + ":admin_msg_id:" + strconv.FormatInt(userID, 10)
+func (r *RedisStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	ok, err := r.rdb.SetNX(ctx, key, value, ttl).Result()
+	return ok, errors.Wrap(err, "setnx "+key)
 }
 
-func RdbGetAdminMsgID(botLang string, userID int64) int {
-	adminMsgID := adminMsgIDToRdb(botLang, userID)
-	result, err := model.Bots[botLang].Rdb.Get(adminMsgID).Result()
-	if err != nil {
-		log.Println(err)
-	}
-	msgID, _ := strconv.Atoi(result)
-	return msgID
+func (r *RedisStore) Delete(ctx context.Context, key string) error {
+	err := r.rdb.Del(ctx, key).Err()
+	return errors.Wrap(err, "delete "+key)
 }
 
-func DeleteOldAdminMsg(botLang string, userID int64) {
-	adminMsgID := adminMsgIDToRdb(botLang, userID)
-	result, err := model.Bots[botLang].Rdb.Get(adminMsgID).Result()
-	if err != nil {
-		log.Println(err)
-	}
-
-	if oldMsgID, _ := strconv.Atoi(result); oldMsgID != 0 {
-		msg := tgbotapi.NewDeleteMessage(userID, oldMsgID)
-
-		if _, err = model.Bots[botLang].Bot.Send(msg); err != nil {
-			log.Println(err)
-		}
-		RdbSetAdminMsgID(botLang, userID, 0)
-	}
-}
-
-func RdbSetMinerLevelSetting(botLang string, userID int64, level int) {
-	minerLevel := minerLevelSettingToRdb(botLang, userID)
-	_, err := model.Bots[botLang].Rdb.Set(minerLevel, strconv.Itoa(level), 0).Result()
-	if err != nil {
-		log.Println(err)
-	}
-}
-
-func minerLevelSettingToRdb(botLang string, userID int64) string {
-	return botLang + ":miner_level_setting:" + strconv.FormatInt(userID, 10)
-}
-
-func RdbGetMinerLevelSetting(botLang string, userID int64) int {
-	minerLevel := minerLevelSettingToRdb(botLang, userID)
-	result, err := model.Bots[botLang].Rdb.Get(minerLevel).Result()
-	if err != nil {
-		log.Println(err)
-	}
-	level, _ := strconv.Atoi(result)
-	return level
-}
-
-func topLevelSettingToRdb(botLang string, userID int64) string {
-	return botLang + ":top_level_setting:" + strconv.FormatInt(userID, 10)
-}
-
-func RdbSetTopLevelSetting(botLang string, userID int64, level int) {
-	topLevel := topLevelSettingToRdb(botLang, userID)
-	_, err := model.Bots[botLang].Rdb.Set(topLevel, strconv.Itoa(level), 0).Result()
-	if err != nil {
-		log.Println(err)
-	}
-}
-
-func RdbGetTopLevelSetting(botLang string, userID int64) int {
-	topLevel := topLevelSettingToRdb(botLang, userID)
-	result, err := model.Bots[botLang].Rdb.Get(topLevel).Result()
-	if err != nil {
-		log.Println(err)
-	}
-	level, _ := strconv.Atoi(result)
-	return level
+// Ping checks the connection, the bot falls back to the memory store without it
+func (r *RedisStore) Ping(ctx context.Context) error {
+	return errors.Wrap(r.rdb.Ping(ctx).Err(), "ping redis")
 }
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EmptyLevel is the level of the user without the saved state
+	EmptyLevel = "empty"
+
+	// MainLevel and AdminLevel are the resting levels, they never expire
+	MainLevel  = "main"
+	AdminLevel = "admin"
+
+	// InputLevelTTL is how long the bot waits for the answer of the user, after it
+	// the user is back on the main or the admin level
+	InputLevelTTL = 3 * time.Hour
+	// ClickerMsgTTL is how long the message of the clicker is edited instead of the new one
+	ClickerMsgTTL = 3 * time.Minute
+)
+
+// State is the conversation state of the users of one bot on top of the store
+type State struct {
+	botLang string
+	store   model.StateStore
+}
+
+func NewState(botLang string, store model.StateStore) *State {
+	return &State{
+		botLang: botLang,
+		store:   store,
+	}
+}
+
+func (s *State) key(name string, userID int64) string {
+	return s.botLang + ":" + name + ":" + strconv.FormatInt(userID, 10)
+}
+
+// Level returns the level waiting for the input if it is not expired,
+// otherwise the resting one or EmptyLevel
+func (s *State) Level(ctx context.Context, userID int64) (string, error) {
+	level, err := s.store.Get(ctx, s.key("user_input", userID))
+	if err == nil {
+		return level, nil
+	}
+	if err != model.ErrStateNotFound {
+		return "", err
+	}
+
+	level, err = s.store.Get(ctx, s.key("user", userID))
+	if err == model.ErrStateNotFound {
+		return EmptyLevel, nil
+	}
+
+	return level, err
+}
+
+// SetLevel saves the level. The main and the admin levels are kept forever,
+// the others wait for the input of the user and expire in InputLevelTTL
+func (s *State) SetLevel(ctx context.Context, userID int64, level string) error {
+	return s.SetLevelFor(ctx, userID, level, InputLevelTTL)
+}
+
+// SetLevelFor saves the level waiting for the input with its own ttl
+func (s *State) SetLevelFor(ctx context.Context, userID int64, level string, ttl time.Duration) error {
+	if level == MainLevel || level == AdminLevel {
+		if err := s.store.Set(ctx, s.key("user", userID), level, 0); err != nil {
+			return err
+		}
+		return s.store.Delete(ctx, s.key("user_input", userID))
+	}
+
+	if err := s.store.Set(ctx, s.key("user", userID), restingLevel(level), 0); err != nil {
+		return err
+	}
+	return s.store.Set(ctx, s.key("user_input", userID), level, ttl)
+}
+
+// restingLevel is the level the user gets back to when the input level expires,
+// the admin levels keep the admin callbacks working
+func restingLevel(level string) string {
+	if strings.HasPrefix(level, AdminLevel) {
+		return AdminLevel
+	}
+
+	return MainLevel
+}
+
+// AdminMsgID returns the id of the message of the admin panel, 0 when there is no one
+func (s *State) AdminMsgID(ctx context.Context, userID int64) (int, error) {
+	return s.getInt(ctx, s.key("admin_msg_id", userID))
+}
+
+func (s *State) SetAdminMsgID(ctx context.Context, userID int64, msgID int) error {
+	return s.store.Set(ctx, s.key("admin_msg_id", userID), strconv.Itoa(msgID), 0)
+}
+
+// ClickerMsgID returns the id of the message of the clicker, 0 when it is expired
+func (s *State) ClickerMsgID(ctx context.Context, userID int64) (int, error) {
+	return s.getInt(ctx, s.key("user_clicker_id", userID))
+}
+
+func (s *State) SetClickerMsgID(ctx context.Context, userID int64, msgID int) error {
+	return s.store.Set(ctx, s.key("user_clicker_id", userID), strconv.Itoa(msgID), ClickerMsgTTL)
+}
+
+// MinerLevelSetting is the miner level opened in the settings of the admin
+func (s *State) MinerLevelSetting(ctx context.Context, userID int64) (int, error) {
+	return s.getInt(ctx, s.key("miner_level_setting", userID))
+}
+
+func (s *State) SetMinerLevelSetting(ctx context.Context, userID int64, level int) error {
+	return s.store.Set(ctx, s.key("miner_level_setting", userID), strconv.Itoa(level), 0)
+}
+
+// TopLevelSetting is the place of the top opened in the settings of the admin
+func (s *State) TopLevelSetting(ctx context.Context, userID int64) (int, error) {
+	return s.getInt(ctx, s.key("top_level_setting", userID))
+}
+
+func (s *State) SetTopLevelSetting(ctx context.Context, userID int64, level int) error {
+	return s.store.Set(ctx, s.key("top_level_setting", userID), strconv.Itoa(level), 0)
+}
+
+// RewardGap returns the gap edited by the admin, nil when there is no one
+func (s *State) RewardGap(ctx context.Context, userID int64) (*model.RewardsGap, error) {
+	value, err := s.store.Get(ctx, rewardGapKey(userID))
+	if err == model.ErrStateNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	gap := &model.RewardsGap{}
+	if err = json.Unmarshal([]byte(value), gap); err != nil {
+		return nil, errors.Wrap(err, "unmarshal reward gap")
+	}
+
+	return gap, nil
+}
+
+func (s *State) SetRewardGap(ctx context.Context, userID int64, gap *model.RewardsGap) error {
+	value, err := json.Marshal(gap)
+	if err != nil {
+		return errors.Wrap(err, "marshal reward gap")
+	}
+
+	return s.store.Set(ctx, rewardGapKey(userID), string(value), 0)
+}
+
+// rewardGapKey has no prefix of the bot, the key is kept as it was saved before
+func rewardGapKey(userID int64) string {
+	return "reward_counter:" + strconv.FormatInt(userID, 10)
+}
+
+// getInt returns 0 for the missing key like for the deleted message or the first opening
+func (s *State) getInt(ctx context.Context, key string) (int, error) {
+	value, err := s.store.Get(ctx, key)
+	if err == model.ErrStateNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse "+key)
+	}
+
+	return number, nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// UpdateTTL is how long the handled update is remembered, telegram keeps
+	// the updates for 24 hours, so it is not delivered again after it
+	UpdateTTL = 24 * time.Hour
+
+	updateDone = "done"
+)
+
+// Updates remembers the updates of one bot, so the update delivered twice is handled once.
+// The update taken by the run which crashed before handling it is handled again. The updates
+// are taken and released with the context of the update, so their requests share its deadline
+type Updates struct {
+	botLang string
+	store   model.StateStore
+
+	// run is the id of the process, the updates in handling are marked with it
+	run string
+}
+
+func NewUpdates(botLang string, store model.StateStore) *Updates {
+	return &Updates{
+		botLang: botLang,
+		store:   store,
+		run:     strconv.FormatInt(time.Now().UnixNano(), 10),
+	}
+}
+
+func (u *Updates) key(updateID int) string {
+	return u.botLang + ":update:" + strconv.Itoa(updateID)
+}
+
+func (u *Updates) offsetKey() string {
+	return u.botLang + ":update_offset"
+}
+
+// Begin takes the update for handling, false is returned when it is
+// already handled or is being handled by this run
+func (u *Updates) Begin(ctx context.Context, updateID int) (bool, error) {
+	key := u.key(updateID)
+
+	taken, err := u.store.SetNX(ctx, key, u.run, UpdateTTL)
+	if err != nil || taken {
+		return taken, err
+	}
+
+	owner, err := u.store.Get(ctx, key)
+	switch {
+	case err == model.ErrStateNotFound:
+	case err != nil:
+		return false, err
+	case owner == updateDone || owner == u.run:
+		return false, nil
+	}
+
+	return true, u.store.Set(ctx, key, u.run, UpdateTTL)
+}
+
+// Done marks the update as handled and saves the offset, all the updates below it are handled
+func (u *Updates) Done(ctx context.Context, updateID, offset int) error {
+	if err := u.store.Set(ctx, u.key(updateID), updateDone, UpdateTTL); err != nil {
+		return err
+	}
+
+	return u.store.Set(ctx, u.offsetKey(), strconv.Itoa(offset), 0)
+}
+
+// Offset returns the first update which is not handled yet, 0 when nothing is saved
+func (u *Updates) Offset(ctx context.Context) (int, error) {
+	value, err := u.store.Get(ctx, u.offsetKey())
+	if err == model.ErrStateNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := strconv.Atoi(value)
+	return offset, errors.Wrap(err, "parse update offset")
+}
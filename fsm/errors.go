@@ -0,0 +1,28 @@
+package fsm
+
+import "errors"
+
+var (
+	// ErrUnknownState error state is not registered in the machine.
+	ErrUnknownState = errors.New("unknown state")
+	// ErrTransitionNotAllowed error state is not in the next states of the current one.
+	ErrTransitionNotAllowed = errors.New("transition not allowed")
+)
+
+// InputError is returned by the validators, the text of the key is sent to the user
+type InputError struct {
+	Key  string
+	Args []interface{}
+}
+
+func (e *InputError) Error() string {
+	return "invalid input: " + e.Key
+}
+
+// Reject returns the InputError with the text key and its arguments
+func Reject(key string, args ...interface{}) *InputError {
+	return &InputError{
+		Key:  key,
+		Args: args,
+	}
+}
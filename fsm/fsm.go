@@ -0,0 +1,230 @@
+// Package fsm describes the dialogs of the bots as the graphs of the named states.
+// The current state is saved as the level of the user in db.State: the name of the
+// state with the parameters after "?", like "admin/edit_source?name?a1b2".
+package fsm
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/db"
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/pkg/errors"
+)
+
+const paramsSeparator = "?"
+
+// State is the name of the dialog state
+type State string
+
+// Definition is the state of the dialog with the input it waits for
+type Definition struct {
+	Name State
+	// Params are the names of the parameters in the order they are kept in the level,
+	// the last ones may be left out on Enter
+	Params []string
+	// Next are the states the user in this state may be moved to, the end
+	// of the dialog is always allowed. The state without Next doesn't restrict the transitions
+	Next []State
+	// Validate checks the input before the handler, the InputError is sent
+	// back to the user and the state is kept
+	Validate Validator
+	Handler  model.Handler
+	// Menu asks for the input of the state again, it is shown when the button
+	// of the other dialog is pressed and the transition is not allowed
+	Menu model.Handler
+	// Timeout is how long the state waits for the input, db.InputLevelTTL when it is 0
+	Timeout time.Duration
+}
+
+// allows is true for the states in Next and for the state itself, the input may be asked again
+func (d *Definition) allows(to State) bool {
+	if to == d.Name || len(d.Next) == 0 {
+		return true
+	}
+
+	for _, next := range d.Next {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Machine serves the input of the users in the registered states
+type Machine struct {
+	states map[State]*Definition
+	store  *db.State
+
+	// rest is the level the dialogs end with: main for the users and admin for the admins
+	rest string
+	// reject sends the text of the invalid input
+	reject func(s *model.Situation, inputErr *InputError) error
+	// cancel shows the menu after the dialog is canceled
+	cancel model.Handler
+}
+
+func NewMachine(store *db.State, rest string, reject func(s *model.Situation, inputErr *InputError) error, cancel model.Handler) *Machine {
+	return &Machine{
+		states: make(map[State]*Definition),
+		store:  store,
+		rest:   rest,
+		reject: reject,
+		cancel: cancel,
+	}
+}
+
+// Register adds the states, the graph is built once on the start so the mistakes panic
+func (m *Machine) Register(definitions ...*Definition) {
+	for _, definition := range definitions {
+		if _, exist := m.states[definition.Name]; exist {
+			panic("fsm: state " + string(definition.Name) + " is registered twice")
+		}
+		m.states[definition.Name] = definition
+	}
+
+	for _, definition := range definitions {
+		for _, next := range definition.Next {
+			if _, exist := m.states[next]; !exist {
+				panic("fsm: state " + string(definition.Name) + " leads to unknown state " + string(next))
+			}
+		}
+	}
+}
+
+// Enter moves the user to the state. The user in the state with Next
+// is only moved to the states in it, the level of the user is checked
+func (m *Machine) Enter(s *model.Situation, to State, params ...string) error {
+	definition, exist := m.states[to]
+	if !exist {
+		return errors.Wrap(ErrUnknownState, string(to))
+	}
+	if len(params) > len(definition.Params) {
+		return errors.Errorf("state %s takes %d params, got %d", to, len(definition.Params), len(params))
+	}
+	for _, param := range params {
+		if strings.Contains(param, paramsSeparator) {
+			return errors.Errorf("param %q of state %s contains %q", param, to, paramsSeparator)
+		}
+	}
+
+	current, _ := parseLevel(s.Params.Level)
+	if from, exist := m.states[current]; exist && !from.allows(to) {
+		return errors.Wrapf(ErrTransitionNotAllowed, "%s -> %s", from.Name, to)
+	}
+
+	timeout := definition.Timeout
+	if timeout == 0 {
+		timeout = db.InputLevelTTL
+	}
+
+	level := formatLevel(to, params)
+	if err := m.store.SetLevelFor(s.Context(), s.User.ID, level, timeout); err != nil {
+		return errors.Wrap(err, "save state")
+	}
+
+	s.Params.Level = level
+	return nil
+}
+
+// Finish ends the dialog, the user gets back to the resting level
+func (m *Machine) Finish(s *model.Situation) error {
+	if err := m.store.SetLevel(s.Context(), s.User.ID, m.rest); err != nil {
+		return errors.Wrap(err, "save state")
+	}
+
+	s.Params.Level = m.rest
+	return nil
+}
+
+// InDialog reports whether the user is in one of the states of the machine
+func (m *Machine) InDialog(s *model.Situation) bool {
+	state, _ := parseLevel(s.Params.Level)
+	_, exist := m.states[state]
+	return exist
+}
+
+// In reports whether the user is in the state
+func (m *Machine) In(s *model.Situation, state State) bool {
+	current, _ := parseLevel(s.Params.Level)
+	return current == state
+}
+
+// Handler returns the handler of the input in the state of the user with the
+// validation, nil is returned when the state is not in the machine
+func (m *Machine) Handler(s *model.Situation) model.Handler {
+	state, values := parseLevel(s.Params.Level)
+	definition, exist := m.states[state]
+	if !exist || definition.Handler == nil {
+		return nil
+	}
+
+	s.Command = string(state)
+	s.Params.State = string(state)
+	s.Params.StateParams = make(model.StateParams, len(definition.Params))
+	for i, name := range definition.Params {
+		if i < len(values) {
+			s.Params.StateParams[name] = values[i]
+		}
+	}
+
+	return func(s *model.Situation) error {
+		if definition.Validate == nil {
+			return definition.Handler(s)
+		}
+
+		err := definition.Validate(s)
+		inputErr := &InputError{}
+		if errors.As(err, &inputErr) {
+			return m.reject(s, inputErr)
+		}
+		if err != nil {
+			return err
+		}
+
+		return definition.Handler(s)
+	}
+}
+
+// Menu shows the menu of the state the user is kept in after the rejected transition,
+// false is returned when the state has no menu
+func (m *Machine) Menu(s *model.Situation) (bool, error) {
+	state, _ := parseLevel(s.Params.Level)
+	definition, exist := m.states[state]
+	if !exist || definition.Menu == nil {
+		return false, nil
+	}
+
+	return true, definition.Menu(s)
+}
+
+// Cancel ends the dialog and shows the menu, false is returned
+// when the user is not in the state of the machine
+func (m *Machine) Cancel(s *model.Situation) (bool, error) {
+	if !m.InDialog(s) {
+		return false, nil
+	}
+
+	if err := m.Finish(s); err != nil {
+		return true, err
+	}
+
+	return true, m.cancel(s)
+}
+
+func formatLevel(state State, params []string) string {
+	for len(params) != 0 && params[len(params)-1] == "" {
+		params = params[:len(params)-1]
+	}
+	if len(params) == 0 {
+		return string(state)
+	}
+
+	return string(state) + paramsSeparator + strings.Join(params, paramsSeparator)
+}
+
+func parseLevel(level string) (State, []string) {
+	partitions := strings.Split(level, paramsSeparator)
+	return State(partitions[0]), partitions[1:]
+}
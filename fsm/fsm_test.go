@@ -0,0 +1,121 @@
+package fsm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Stepan1328/miner-bot/db"
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	stateMethod  State = "withdrawal"
+	stateDetails State = "/withdrawal_req_amount"
+	stateAmount  State = "/withdrawal_exit"
+	stateFree    State = "/change_hash_to_btc"
+)
+
+func newTestMachine() (*Machine, *db.State) {
+	store := db.NewState("en", db.NewMemoryStore())
+	machine := NewMachine(store, db.MainLevel, nil, nil)
+	machine.Register(
+		&Definition{Name: stateMethod, Next: []State{stateDetails}},
+		&Definition{Name: stateDetails, Params: []string{"method"}, Next: []State{stateAmount}},
+		&Definition{Name: stateAmount},
+		&Definition{Name: stateFree},
+	)
+
+	return machine, store
+}
+
+func TestEnter(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		to      State
+		params  []string
+		wantErr error
+	}{
+		{name: "from the rest level", level: db.MainLevel, to: stateAmount},
+		{name: "to the next state", level: string(stateMethod), to: stateDetails, params: []string{"paypal"}},
+		{name: "from the state with params", level: string(stateDetails) + "?paypal", to: stateAmount},
+		{name: "to the same state", level: string(stateDetails) + "?paypal", to: stateDetails, params: []string{"card"}},
+		{name: "from the state without next", level: string(stateFree), to: stateMethod},
+		{name: "to the state not in next", level: string(stateMethod), to: stateAmount, wantErr: ErrTransitionNotAllowed},
+		{name: "back from the state with params", level: string(stateDetails) + "?paypal", to: stateMethod, wantErr: ErrTransitionNotAllowed},
+		{name: "to the unknown state", level: db.MainLevel, to: "unknown", wantErr: ErrUnknownState},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			machine, store := newTestMachine()
+			ctx := context.Background()
+			if err := store.SetLevel(ctx, 1, test.level); err != nil {
+				t.Fatalf("set level: %v", err)
+			}
+
+			s := &model.Situation{
+				User:   &model.User{ID: 1},
+				Params: &model.Parameters{Level: test.level},
+			}
+			err := machine.Enter(s, test.to, test.params...)
+
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Enter() error = %v, want %v", err, test.wantErr)
+				}
+				if level, _ := store.Level(ctx, 1); level != test.level {
+					t.Errorf("level = %q after the rejected transition, want %q", level, test.level)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Enter() error = %v", err)
+			}
+
+			want := formatLevel(test.to, test.params)
+			if s.Params.Level != want {
+				t.Errorf("situation level = %q, want %q", s.Params.Level, want)
+			}
+			if level, _ := store.Level(ctx, 1); level != want {
+				t.Errorf("stored level = %q, want %q", level, want)
+			}
+		})
+	}
+}
+
+func TestEnterChecksTheLevelWithoutTheServedState(t *testing.T) {
+	machine, _ := newTestMachine()
+
+	// the callback doesn't go through the handler of the state, only the level is known
+	s := &model.Situation{
+		User:   &model.User{ID: 1},
+		Params: &model.Parameters{Level: string(stateMethod)},
+	}
+	if err := machine.Enter(s, stateAmount); !errors.Is(err, ErrTransitionNotAllowed) {
+		t.Fatalf("Enter() error = %v, want %v", err, ErrTransitionNotAllowed)
+	}
+}
+
+func TestMenu(t *testing.T) {
+	machine, _ := newTestMachine()
+	var shown State
+	machine.states[stateMethod].Menu = func(s *model.Situation) error {
+		shown = stateMethod
+		return nil
+	}
+
+	s := &model.Situation{
+		User:   &model.User{ID: 1},
+		Params: &model.Parameters{Level: string(stateMethod)},
+	}
+	if ok, err := machine.Menu(s); !ok || err != nil || shown != stateMethod {
+		t.Fatalf("Menu() = %v, %v, shown %q, want the menu of %q", ok, err, shown, stateMethod)
+	}
+
+	s.Params.Level = string(stateDetails) + "?paypal"
+	if ok, err := machine.Menu(s); ok || err != nil {
+		t.Errorf("Menu() = %v, %v for the state without the menu", ok, err)
+	}
+}
@@ -0,0 +1,61 @@
+package fsm
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Stepan1328/miner-bot/model"
+)
+
+// Validator checks the input of the state, the InputError keeps the user in the state
+type Validator func(s *model.Situation) error
+
+// Text accepts any message with the text
+func Text(key string) Validator {
+	return func(s *model.Situation) error {
+		if s.Message == nil || strings.TrimSpace(s.Message.Text) == "" {
+			return Reject(key)
+		}
+		return nil
+	}
+}
+
+// Int accepts the whole number not less than min
+func Int(min int, key string) Validator {
+	return func(s *model.Situation) error {
+		if _, ok := IntInput(s, min); !ok {
+			return Reject(key)
+		}
+		return nil
+	}
+}
+
+// Int64 accepts the id or the other big number not less than min
+func Int64(min int64, key string) Validator {
+	return func(s *model.Situation) error {
+		if s.Message == nil {
+			return Reject(key)
+		}
+
+		number, err := strconv.ParseInt(strings.TrimSpace(s.Message.Text), 10, 64)
+		if err != nil || number < min {
+			return Reject(key)
+		}
+		return nil
+	}
+}
+
+// IntInput parses the number of the message like Int checks it,
+// the spaces between the digits are allowed: "10 000"
+func IntInput(s *model.Situation, min int) (int, bool) {
+	if s.Message == nil {
+		return 0, false
+	}
+
+	number, err := strconv.Atoi(strings.Replace(s.Message.Text, " ", "", -1))
+	if err != nil || number < min {
+		return 0, false
+	}
+
+	return number, true
+}
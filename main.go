@@ -1,38 +1,54 @@
 package main
 
 import (
+	"context"
 	"math/rand"
 	"net/http"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/roylee0704/gron"
 	"github.com/roylee0704/gron/xtime"
 
+	"github.com/Stepan1328/miner-bot/cfg"
+	"github.com/Stepan1328/miner-bot/db"
 	"github.com/Stepan1328/miner-bot/log"
 	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/repository"
 	"github.com/Stepan1328/miner-bot/services"
 	"github.com/Stepan1328/miner-bot/services/administrator"
 	"github.com/Stepan1328/miner-bot/services/auth"
+	"github.com/Stepan1328/miner-bot/services/mailing"
 	"github.com/Stepan1328/miner-bot/utils"
-	"github.com/bots-empire/base-bot/mailing"
 	"github.com/bots-empire/base-bot/msgs"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+const updateStatisticFlush = 10 * time.Second
+
 func main() {
 	rand.Seed(time.Now().Unix())
 
+	if isMigrateCommand() {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	logger := log.NewDefaultLogger().Prefix("Miner Bot")
 	log.PrintLogo("Miner Bot", []string{"FFD700"})
 
+	if err := cfg.Init(); err != nil {
+		logger.Fatal("failed load config: %s", err.Error())
+	}
+
 	model.FillBotsConfig()
 	model.UploadAdminSettings()
 	go startPrometheusHandler(logger)
 
 	srvs := startAllBot(logger)
-	model.UploadUpdateStatistic()
 
 	startHandlers(srvs, logger)
 }
@@ -43,18 +59,22 @@ func startAllBot(log log.Logger) []*services.Users {
 	for lang, globalBot := range model.Bots {
 		startBot(globalBot, log, lang)
 
-		service := msgs.NewService(globalBot, []int64{872383555, 1418862576, -1001736803459})
+		service := msgs.NewService(globalBot, cfg.App.DeveloperChats)
+		store := newStateStore(globalBot, log)
+		state := db.NewState(lang, store)
+		updates := db.NewUpdates(lang, store)
 
-		authSrv := auth.NewAuthService(globalBot, service)
-		mail := mailing.NewService(service, 100)
-		adminSrv := administrator.NewAdminService(globalBot, mail, service)
-		userSrv := services.NewUsersService(globalBot, authSrv, adminSrv, service)
+		authSrv := auth.NewAuthService(globalBot, globalBot.Repos, service)
+		mail := mailing.NewService(globalBot, service, 100)
+		adminSrv := administrator.NewAdminService(globalBot, globalBot.Repos, state, mail, service)
+		userSrv := services.NewUsersService(globalBot, globalBot.Repos, state, updates, authSrv, adminSrv, service)
 
 		globalBot.MessageHandler = NewMessagesHandler(userSrv, adminSrv)
-		globalBot.CallbackHandler = NewCallbackHandler(userSrv)
+		globalBot.CallbackHandler = NewCallbackHandler(userSrv, adminSrv)
 		globalBot.AdminMessageHandler = NewAdminMessagesHandler(adminSrv)
 		globalBot.AdminCallBackHandler = NewAdminCallbackHandler(adminSrv)
 
+		startUpdates(globalBot, updates, log)
 		srvs = append(srvs, userSrv)
 	}
 
@@ -64,27 +84,67 @@ func startAllBot(log log.Logger) []*services.Users {
 
 func startBot(b *model.GlobalBot, log log.Logger, lang string) {
 	var err error
-	b.Bot, err = tgbotapi.NewBotAPI(b.BotToken)
+	// tgbotapi has no context in its calls, the handlers are limited by the deadline of the update
+	// and its watchdog, the client only cuts off the requests which hang
+	client := &http.Client{Timeout: cfg.App.TelegramDeadline()}
+	b.Bot, err = tgbotapi.NewBotAPIWithClient(b.BotToken, tgbotapi.APIEndpoint, client)
 	if err != nil {
 		log.Fatal("error start bot: %s", err.Error())
 	}
 
-	u := tgbotapi.NewUpdate(0)
-
-	b.Chanel = b.Bot.GetUpdatesChan(u)
-
 	b.Rdb = model.StartRedis()
+	b.UpdateStatistic = model.NewUpdateStatistic(lang)
 	b.DataBase = model.UploadDataBase(lang)
+	b.Repos = repository.NewSQL(b.DataBase)
 
 	b.ParseLangMap()
 	b.ParseCommandsList()
 	b.ParseAdminMap()
 }
 
+// startUpdates starts getting the updates when the handlers are ready,
+// the polling continues from the first update which is not handled
+func startUpdates(b *model.GlobalBot, updates *db.Updates, log log.Logger) {
+	var err error
+	if cfg.App.UpdateMode == cfg.UpdateModeWebhook {
+		err = b.StartWebhook(http.DefaultServeMux, cfg.App.Webhook.URL, cfg.App.Webhook.SecretToken)
+	} else {
+		var offset int
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.App.UpdateDeadline())
+		offset, err = updates.Offset(ctx)
+		cancel()
+		if err == nil {
+			err = b.StartPolling(offset)
+		}
+	}
+
+	if err != nil {
+		log.Fatal("error start updates of %s: %s", b.BotLang, err.Error())
+	}
+}
+
+// newStateStore returns the store of the config, the memory one is used when
+// redis is not available and then the levels of the users are lost on the restart
+func newStateStore(b *model.GlobalBot, log log.Logger) model.StateStore {
+	if cfg.App.StateStore == cfg.StateStoreMemory {
+		return db.NewMemoryStore()
+	}
+
+	store := db.NewRedisStore(b.Rdb)
+	if err := store.Ping(context.Background()); err != nil {
+		log.Warn("redis is not available, the state of %s is kept in memory: %s", b.BotLang, err.Error())
+		return db.NewMemoryStore()
+	}
+
+	return store
+}
+
+// startPrometheusHandler serves the metrics, the webhooks of the bots are served by the same server
 func startPrometheusHandler(logger log.Logger) {
 	http.Handle("/metrics", promhttp.Handler())
-	logger.Ok("Metrics can be read from %s port", "7011")
-	metricErr := http.ListenAndServe(":7011", nil)
+	port := strconv.Itoa(cfg.App.MetricsPort)
+	logger.Ok("Metrics can be read from %s port", port)
+	metricErr := http.ListenAndServe(":"+port, nil)
 	if metricErr != nil {
 		logger.Fatal("metrics stoped by metricErr: %s\n", metricErr.Error())
 	}
@@ -93,27 +153,42 @@ func startPrometheusHandler(logger log.Logger) {
 func startHandlers(srvs []*services.Users, logger log.Logger) {
 	wg := new(sync.WaitGroup)
 	cron := gron.New()
-	cron.AddFunc(gron.Every(1*xtime.Day).At("20:59"), srvs[0].SendTodayUpdateMsg)
+	cron.AddFunc(gron.Every(1*xtime.Day).At("20:59"), srvs[0].SendDailyDigest)
+	for _, service := range srvs {
+		cron.AddFunc(gron.Every(1*xtime.Minute), service.RunMailingJobs)
+	}
+
+	flushDone := make(chan struct{})
+	go model.StartFlushing(updateStatisticFlush, flushDone, func(botLang string, err error) {
+		logger.Warn("failed flush update statistic of %s: %s", botLang, err.Error())
+	})
 
+	spreaders := make([]*utils.Spreader, 0, len(srvs))
 	for _, service := range srvs {
+		spreader := utils.NewSpreader(time.Minute)
+		spreaders = append(spreaders, spreader)
+
 		wg.Add(1)
 		go func(handler *services.Users, wg *sync.WaitGroup, cron *gron.Cron) {
 			defer wg.Done()
-			handler.ActionsWithUpdates(logger, utils.NewSpreader(time.Minute), cron)
+			handler.ActionsWithUpdates(logger, spreader, cron)
 		}(service, wg, cron)
 
 		service.Msgs.SendNotificationToDeveloper("Bot are restart", false)
 	}
 
-	go func() {
-		time.Sleep(5 * time.Second)
-
-		cron.Start()
-	}()
+	cronStart := time.AfterFunc(5*time.Second, cron.Start)
 
 	logger.Ok("All handlers are running")
 
-	wg.Wait()
+	sig := waitForSignal()
+	logger.Info("got %s, stopping the bots", sig.String())
+
+	cronStart.Stop()
+	cron.Stop()
+	close(flushDone)
+
+	shutdown(srvs, spreaders, wg, logger)
 }
 
 func NewMessagesHandler(userSrv *services.Users, adminSrv *administrator.Admin) *services.MessagesHandlers {
@@ -125,12 +200,12 @@ func NewMessagesHandler(userSrv *services.Users, adminSrv *administrator.Admin)
 	return &handle
 }
 
-func NewCallbackHandler(userSrv *services.Users) *services.CallBackHandlers {
+func NewCallbackHandler(userSrv *services.Users, adminSrv *administrator.Admin) *services.CallBackHandlers {
 	handle := services.CallBackHandlers{
 		Handlers: map[string]model.Handler{},
 	}
 
-	handle.Init(userSrv)
+	handle.Init(userSrv, adminSrv)
 	return &handle
 }
 
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Stepan1328/miner-bot/cfg"
+	"github.com/Stepan1328/miner-bot/log"
+	"github.com/Stepan1328/miner-bot/migrations"
+	"github.com/Stepan1328/miner-bot/model"
+)
+
+const migrateUsage = "usage: miner-bot migrate up | down [steps] | status"
+
+// runMigrate applies or rolls back the migrations of every bot database:
+//
+//	miner-bot migrate up
+//	miner-bot migrate down 2
+//	miner-bot migrate status
+func runMigrate(args []string) {
+	logger := log.NewDefaultLogger().Prefix("Migrate")
+
+	if len(args) == 0 {
+		logger.Fatal(migrateUsage)
+	}
+
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		var err error
+		if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+			logger.Fatal(migrateUsage)
+		}
+	}
+
+	if err := cfg.Init(); err != nil {
+		logger.Fatal("failed load config: %s", err.Error())
+	}
+	model.FillBotsConfig()
+
+	langs := make([]string, 0, len(model.Bots))
+	for lang := range model.Bots {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	for _, lang := range langs {
+		if err := migrateDataBase(logger.Prefix(lang), args[0], lang, steps); err != nil {
+			logger.Fatal("%s: %s", lang, err.Error())
+		}
+	}
+}
+
+func migrateDataBase(logger log.Logger, command, lang string, steps int) error {
+	dataBase, err := model.OpenDataBase(lang)
+	if err != nil {
+		return err
+	}
+	defer dataBase.Close()
+
+	switch command {
+	case "up":
+		done, err := migrations.Up(dataBase)
+		for _, migration := range done {
+			logger.Ok("applied %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			logger.Info("schema is up to date")
+		}
+	case "down":
+		done, err := migrations.Down(dataBase, steps)
+		for _, migration := range done {
+			logger.Ok("rolled back %04d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		status, err := migrations.GetStatus(dataBase)
+		if err != nil {
+			return err
+		}
+		logger.Info("version %d, latest %d", status.Current, status.Latest)
+		for _, migration := range status.Pending {
+			logger.Warn("pending %04d_%s", migration.Version, migration.Name)
+		}
+	default:
+		return fmt.Errorf("unknown command %q, %s", command, migrateUsage)
+	}
+
+	return nil
+}
+
+func isMigrateCommand() bool {
+	return len(os.Args) > 1 && os.Args[1] == "migrate"
+}
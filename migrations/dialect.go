@@ -0,0 +1,114 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+const (
+	dialectMySQL  = "mysql"
+	dialectSQLite = "sqlite"
+)
+
+// dialect is the difference of the databases in the locking and the errors
+type dialect struct {
+	name string
+
+	// lock returns the function releasing the lock with the result of the migration
+	lock func(ctx context.Context, conn *sql.Conn) (func(err error) error, error)
+	// alreadyApplied is true for the errors of the databases created before
+	// the migrations, the change is already there so the statement is skipped
+	alreadyApplied func(err error) bool
+}
+
+func dialectOf(dataBase *sql.DB) *dialect {
+	if _, ok := dataBase.Driver().(*sqlite3.SQLiteDriver); ok {
+		return sqliteDialect
+	}
+
+	return mysqlDialect
+}
+
+var mysqlDialect = &dialect{
+	name:           dialectMySQL,
+	lock:           mysqlLock,
+	alreadyApplied: mysqlAlreadyApplied,
+}
+
+// mysqlAlreadyAppliedErrors are the numbers of the mysql errors skipped by the migrations
+var mysqlAlreadyAppliedErrors = map[uint16]bool{
+	1050: true, // table already exists
+	1060: true, // duplicate column name
+	1061: true, // duplicate key name
+	1091: true, // can't drop the column or the key, it doesn't exist
+}
+
+// mysqlLock holds the named lock, it belongs to the connection
+// so everything runs on the same one
+func mysqlLock(ctx context.Context, conn *sql.Conn) (func(err error) error, error) {
+	var name string
+	if err := conn.QueryRowContext(ctx, `SELECT DATABASE();`).Scan(&name); err != nil {
+		return nil, errors.Wrap(err, "get database name")
+	}
+	lockName := "schema_migrations:" + name
+
+	var locked sql.NullInt64
+	err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?);`, lockName, lockTimeout).Scan(&locked)
+	if err != nil {
+		return nil, errors.Wrap(err, "get migration lock")
+	}
+	if locked.Int64 != 1 {
+		return nil, ErrMigrationLocked
+	}
+
+	return func(err error) error {
+		_, _ = conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?);`, lockName)
+		return err
+	}, nil
+}
+
+func mysqlAlreadyApplied(err error) bool {
+	mysqlErr := &mysql.MySQLError{}
+	return errors.As(err, &mysqlErr) && mysqlAlreadyAppliedErrors[mysqlErr.Number]
+}
+
+var sqliteDialect = &dialect{
+	name:           dialectSQLite,
+	lock:           sqliteLock,
+	alreadyApplied: sqliteAlreadyApplied,
+}
+
+// sqliteLock runs the migrations in the exclusive transaction, sqlite changes
+// the schema in transactions so the failed migration leaves nothing behind
+func sqliteLock(ctx context.Context, conn *sql.Conn) (func(err error) error, error) {
+	if _, err := conn.ExecContext(ctx, `BEGIN EXCLUSIVE;`); err != nil {
+		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.Code == sqlite3.ErrBusy {
+			return nil, ErrMigrationLocked
+		}
+		return nil, errors.Wrap(err, "begin migration transaction")
+	}
+
+	return func(err error) error {
+		if err != nil {
+			_, _ = conn.ExecContext(ctx, `ROLLBACK;`)
+			return err
+		}
+
+		if _, err = conn.ExecContext(ctx, `COMMIT;`); err != nil {
+			return errors.Wrap(err, "commit migration transaction")
+		}
+		return nil
+	}, nil
+}
+
+// sqliteAlreadyApplied matches the messages, sqlite has the same code for all of them
+func sqliteAlreadyApplied(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "already exists") ||
+		strings.Contains(message, "duplicate column name")
+}
@@ -0,0 +1,10 @@
+package migrations
+
+import "github.com/pkg/errors"
+
+var (
+	// ErrSchemaBehind error database has migrations not applied yet.
+	ErrSchemaBehind = errors.New("database schema is behind")
+	// ErrMigrationLocked error another process is migrating the database.
+	ErrMigrationLocked = errors.New("migration lock is held by another process")
+)
@@ -0,0 +1,336 @@
+// Package migrations keeps the schema of the bot databases in the numbered sql files
+// sql/NNNN_name.up.sql and sql/NNNN_name.down.sql, the applied versions are saved
+// in the schema_migrations table of every database. The file with the same name
+// in sql/sqlite replaces the mysql one for the sqlite databases.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	schemaMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version int NOT NULL,
+    name varchar(128) NOT NULL,
+    applied_at bigint NOT NULL,
+    PRIMARY KEY (version)
+);`
+
+	lockTimeout = 60 // seconds
+)
+
+//go:embed sql/*.sql sql/sqlite/*.sql
+var files embed.FS
+
+// querier is the database or the connection holding the migration lock
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is the schema version of the database against the migrations of the binary
+type Status struct {
+	Current int
+	Latest  int
+	Pending []*Migration
+}
+
+func (s *Status) Behind() bool {
+	return len(s.Pending) != 0
+}
+
+// Load reads the migrations of the database dialect ordered by the version
+func Load(dialect string) ([]*Migration, error) {
+	entries, err := files.ReadDir("sql")
+	if err != nil {
+		return nil, errors.Wrap(err, "read migrations dir")
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		direction := path.Ext(strings.TrimSuffix(name, ".sql"))
+		if direction != ".up" && direction != ".down" {
+			return nil, fmt.Errorf("migration %s is neither up nor down", name)
+		}
+
+		base := strings.TrimSuffix(name, direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("migration %s has no version", name)
+		}
+
+		data, err := readFile(dialect, name)
+		if err != nil {
+			return nil, errors.Wrap(err, "read migration "+name)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+		if direction == ".up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d has no up or down file", migration.Version)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// readFile returns the file of the dialect if there is one
+func readFile(dialect, name string) ([]byte, error) {
+	data, err := files.ReadFile(path.Join("sql", dialect, name))
+	if err == nil {
+		return data, nil
+	}
+
+	return files.ReadFile(path.Join("sql", name))
+}
+
+// GetStatus compares the applied versions with the migrations of the binary
+func GetStatus(dataBase *sql.DB) (*Status, error) {
+	return getStatus(dataBase, dialectOf(dataBase))
+}
+
+func getStatus(db querier, dialect *dialect) (*Status, error) {
+	migrations, err := Load(dialect.name)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return status(migrations, applied), nil
+}
+
+func status(migrations []*Migration, applied map[int]bool) *Status {
+	result := &Status{}
+	for _, migration := range migrations {
+		result.Latest = migration.Version
+		if applied[migration.Version] {
+			result.Current = migration.Version
+			continue
+		}
+		result.Pending = append(result.Pending, migration)
+	}
+
+	return result
+}
+
+// Check returns ErrSchemaBehind while the database has the pending migrations
+func Check(dataBase *sql.DB) error {
+	result, err := GetStatus(dataBase)
+	if err != nil {
+		return err
+	}
+
+	if result.Behind() {
+		return errors.Wrapf(ErrSchemaBehind, "version %d, latest %d, %d pending",
+			result.Current, result.Latest, len(result.Pending))
+	}
+
+	return nil
+}
+
+// Up applies the pending migrations and returns the applied ones
+func Up(dataBase *sql.DB) ([]*Migration, error) {
+	var done []*Migration
+	dialect := dialectOf(dataBase)
+
+	err := withLock(dataBase, dialect, func(conn *sql.Conn) error {
+		result, err := getStatus(conn, dialect)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range result.Pending {
+			if err = execute(conn, dialect, migration.Up); err != nil {
+				return errors.Wrapf(err, "apply migration %d_%s", migration.Version, migration.Name)
+			}
+
+			_, err = conn.ExecContext(context.Background(), `
+INSERT INTO schema_migrations
+	(version, name, applied_at)
+VALUES (?, ?, ?);`,
+				migration.Version,
+				migration.Name,
+				time.Now().Unix())
+			if err != nil {
+				return errors.Wrap(err, "save migration version")
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the last applied migrations and returns the rolled back ones
+func Down(dataBase *sql.DB, steps int) ([]*Migration, error) {
+	var done []*Migration
+	dialect := dialectOf(dataBase)
+
+	err := withLock(dataBase, dialect, func(conn *sql.Conn) error {
+		migrations, err := Load(dialect.name)
+		if err != nil {
+			return err
+		}
+
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := migrations[i]
+			if !applied[migration.Version] {
+				continue
+			}
+
+			if err = execute(conn, dialect, migration.Down); err != nil {
+				return errors.Wrapf(err, "roll back migration %d_%s", migration.Version, migration.Name)
+			}
+
+			_, err = conn.ExecContext(context.Background(), `
+DELETE FROM schema_migrations WHERE version = ?;`,
+				migration.Version)
+			if err != nil {
+				return errors.Wrap(err, "delete migration version")
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+func appliedVersions(db querier) (map[int]bool, error) {
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, schemaMigrationsTable); err != nil {
+		return nil, errors.Wrap(err, "create schema_migrations")
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations;`)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err = rows.Scan(&version); err != nil {
+			return nil, errors.Wrap(err, "scan migration version")
+		}
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// withLock runs the migrations on one connection holding the lock of the
+// database, so two processes never migrate the same database at the same time
+func withLock(dataBase *sql.DB, dialect *dialect, run func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := dataBase.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get connection")
+	}
+	defer conn.Close()
+
+	unlock, err := dialect.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return unlock(run(conn))
+}
+
+// execute runs the statements of the file one by one, the driver
+// doesn't accept several statements in one query
+func execute(conn *sql.Conn, dialect *dialect, script string) error {
+	for _, statement := range splitStatements(script) {
+		_, err := conn.ExecContext(context.Background(), statement)
+		if err == nil || dialect.alreadyApplied(err) {
+			continue
+		}
+
+		return errors.Wrap(err, statement)
+	}
+
+	return nil
+}
+
+// splitStatements splits the script by the semicolons ending the lines
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+	)
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS top;
+DROP TABLE IF EXISTS income_info;
+DROP TABLE IF EXISTS subs;
+DROP TABLE IF EXISTS links;
+DROP TABLE IF EXISTS users;
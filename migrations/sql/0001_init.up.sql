@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS users (
+    id bigint NOT NULL,
+    balance int NOT NULL DEFAULT 0,
+    balance_hash int NOT NULL DEFAULT 0,
+    balance_btc double NOT NULL DEFAULT 0,
+    mining_today int NOT NULL DEFAULT 0,
+    last_click bigint NOT NULL DEFAULT 0,
+    miner_level int NOT NULL DEFAULT 1,
+    father_id bigint NOT NULL DEFAULT 0,
+    all_referrals text NOT NULL,
+    advert_channel int NOT NULL DEFAULT 1,
+    take_bonus bool NOT NULL DEFAULT false,
+    lang varchar(16) NOT NULL,
+    register_time bigint NOT NULL DEFAULT 0,
+    min_withdrawal int NOT NULL DEFAULT 0,
+    first_withdrawal bool NOT NULL DEFAULT false,
+    status varchar(16) NOT NULL DEFAULT 'active',
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX balanceindex ON users (balance);
+
+CREATE TABLE IF NOT EXISTS links (
+    hash varchar(64) NOT NULL,
+    referral_id bigint NOT NULL DEFAULT 0,
+    source text NOT NULL,
+    PRIMARY KEY (hash)
+);
+
+CREATE TABLE IF NOT EXISTS subs (
+    id bigint NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS income_info (
+    user_id bigint NOT NULL,
+    source text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS top (
+    top int NOT NULL,
+    user_id bigint NOT NULL DEFAULT 0,
+    time_on_top int NOT NULL DEFAULT 0,
+    balance int NOT NULL DEFAULT 0,
+    PRIMARY KEY (top)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE income_info DROP COLUMN source_hash;
+
+DROP TABLE IF EXISTS source_links;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS source_links (
+    hash varchar(64) NOT NULL,
+    source text NOT NULL,
+    note text NOT NULL,
+    owner_id bigint NOT NULL DEFAULT 0,
+    created_at bigint NOT NULL DEFAULT 0,
+    expire_at bigint NOT NULL DEFAULT 0,
+    disabled bool NOT NULL DEFAULT false,
+    clicks int NOT NULL DEFAULT 0,
+    registrations int NOT NULL DEFAULT 0,
+    PRIMARY KEY (hash)
+);
+
+ALTER TABLE income_info ADD COLUMN source_hash varchar(64) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+ALTER TABLE source_links DROP COLUMN referral_reward;
+ALTER TABLE source_links DROP COLUMN bonus_hash;
+ALTER TABLE source_links DROP COLUMN welcome_media;
+ALTER TABLE source_links DROP COLUMN welcome_media_type;
+ALTER TABLE source_links DROP COLUMN welcome_text;
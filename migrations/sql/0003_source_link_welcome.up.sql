@@ -0,0 +1,5 @@
+ALTER TABLE source_links ADD COLUMN welcome_text text NOT NULL;
+ALTER TABLE source_links ADD COLUMN welcome_media_type varchar(16) NOT NULL DEFAULT '';
+ALTER TABLE source_links ADD COLUMN welcome_media text NOT NULL;
+ALTER TABLE source_links ADD COLUMN bonus_hash int NOT NULL DEFAULT 0;
+ALTER TABLE source_links ADD COLUMN referral_reward text NOT NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS mailing_jobs (
+    id bigint NOT NULL AUTO_INCREMENT,
+    channel int NOT NULL,
+    segment text NOT NULL,
+    repeat_mode varchar(16) NOT NULL,
+    next_run bigint NOT NULL,
+    created_by bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (id)
+);
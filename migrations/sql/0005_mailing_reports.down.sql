@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS mailing_clicks;
+DROP TABLE IF EXISTS mailing_reports;
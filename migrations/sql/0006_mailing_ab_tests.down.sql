@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS mailing_ab_users;
+
+ALTER TABLE mailing_reports DROP COLUMN test_report_id;
+ALTER TABLE mailing_reports DROP COLUMN variants;
@@ -0,0 +1,10 @@
+ALTER TABLE mailing_reports ADD COLUMN variants text NOT NULL;
+ALTER TABLE mailing_reports ADD COLUMN test_report_id bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS mailing_ab_users (
+    report_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    variant int NOT NULL,
+    delivered bool NOT NULL DEFAULT false,
+    PRIMARY KEY (report_id, user_id)
+);
@@ -0,0 +1 @@
+-- the backfill only copies the data, there is nothing to roll back
@@ -0,0 +1,11 @@
+-- the old databases kept the referrals in referral_count, the new ones have
+-- no such column, so the update is prepared only when the column exists
+SET @referral_count_backfill = (
+    SELECT IF(COUNT(*) = 0, 'DO 0',
+        'UPDATE users SET all_referrals = CAST(referral_count AS CHAR) WHERE referral_count != 0')
+    FROM information_schema.columns
+    WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'referral_count'
+);
+PREPARE referral_count_backfill FROM @referral_count_backfill;
+EXECUTE referral_count_backfill;
+DEALLOCATE PREPARE referral_count_backfill;
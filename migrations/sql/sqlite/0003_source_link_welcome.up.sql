@@ -0,0 +1,6 @@
+-- sqlite can't add the not null column without the default
+ALTER TABLE source_links ADD COLUMN welcome_text text NOT NULL DEFAULT '';
+ALTER TABLE source_links ADD COLUMN welcome_media_type varchar(16) NOT NULL DEFAULT '';
+ALTER TABLE source_links ADD COLUMN welcome_media text NOT NULL DEFAULT '';
+ALTER TABLE source_links ADD COLUMN bonus_hash int NOT NULL DEFAULT 0;
+ALTER TABLE source_links ADD COLUMN referral_reward text NOT NULL DEFAULT '';
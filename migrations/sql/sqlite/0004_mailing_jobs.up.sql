@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS mailing_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel int NOT NULL,
+    segment text NOT NULL,
+    repeat_mode varchar(16) NOT NULL,
+    next_run bigint NOT NULL,
+    created_by bigint NOT NULL DEFAULT 0
+);
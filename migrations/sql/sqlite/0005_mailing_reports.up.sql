@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS mailing_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_by bigint NOT NULL DEFAULT 0,
+    started_at bigint NOT NULL,
+    finished_at bigint NOT NULL DEFAULT 0,
+    status varchar(16) NOT NULL,
+    with_button bool NOT NULL DEFAULT false,
+    total int NOT NULL DEFAULT 0,
+    sent int NOT NULL DEFAULT 0,
+    blocked int NOT NULL DEFAULT 0,
+    failed int NOT NULL DEFAULT 0,
+    errors text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS mailing_clicks (
+    report_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    PRIMARY KEY (report_id, user_id)
+);
@@ -0,0 +1 @@
+-- the sqlite databases were created without referral_count, there is nothing to copy
@@ -1,24 +1,27 @@
 package model
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
+	"time"
 
 	"github.com/Stepan1328/miner-bot/cfg"
-	"github.com/go-redis/redis"
+	"github.com/Stepan1328/miner-bot/migrations"
+	"github.com/go-redis/redis/v8"
 	_ "github.com/go-sql-driver/mysql"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
-	tokensPath       = "./cfg/tokens.json"
-	dbDriver         = "mysql"
-	redisDefaultAddr = "127.0.0.1:6379"
+	mysqlDriver  = "mysql"
+	sqliteDriver = "sqlite3"
+	sqliteParams = "?_busy_timeout=10000&_journal_mode=WAL"
 
 	statusDeleted = "deleted"
 )
@@ -30,8 +33,12 @@ type GlobalBot struct {
 
 	Bot      *tgbotapi.BotAPI
 	Chanel   tgbotapi.UpdatesChannel
+	webhook  *webhook
 	Rdb      *redis.Client
 	DataBase *sql.DB
+	Repos    *Repositories
+
+	UpdateStatistic *UpdateStatistic
 
 	MessageHandler  GlobalHandlers
 	CallbackHandler GlobalHandlers
@@ -43,9 +50,13 @@ type GlobalBot struct {
 	Language     map[string]map[string]string
 	AdminLibrary map[string]map[string]string
 
-	BotToken      string   `json:"bot_token"`
-	BotLink       string   `json:"bot_link"`
-	LanguageInBot []string `json:"language_in_bot"`
+	BotToken      string
+	BotLink       string
+	LanguageInBot []string
+	Timezone      string
+	DBName        string
+
+	location *time.Location
 
 	MaintenanceMode bool
 }
@@ -56,164 +67,127 @@ type GlobalHandlers interface {
 
 type Handler func(situation *Situation) error
 
-func UploadDataBase(dbLang string) *sql.DB {
-	dataBase, err := sql.Open(dbDriver, cfg.DBCfg.User+cfg.DBCfg.Password+"@/") //TODO: refactor
+// OpenDataBase creates the database of the bot if it is missing and connects to it
+func OpenDataBase(dbLang string) (*sql.DB, error) {
+	dbName := Bots[dbLang].DBName
+
+	var (
+		dataBase *sql.DB
+		err      error
+	)
+	if cfg.App.DB.Driver == cfg.DriverSQLite {
+		dataBase, err = openSQLite(dbName)
+	} else {
+		dataBase, err = openMySQL(dbName)
+	}
 	if err != nil {
-		log.Fatalf("Failed open database: %s\n", err.Error())
+		return nil, err
 	}
 
-	dataBase.Exec("CREATE DATABASE IF NOT EXISTS " + cfg.DBCfg.Names[dbLang] + ";")
-	dataBase.Exec("USE " + cfg.DBCfg.Names[dbLang] + ";")
-	dataBase.Exec("CREATE TABLE IF NOT EXISTS users (" + cfg.UserTable + ");")
-	dataBase.Exec("CREATE TABLE IF NOT EXISTS links (" + cfg.Links + ");")
-	dataBase.Exec("CREATE TABLE IF NOT EXISTS subs (" + cfg.Subs + ");")
-	dataBase.Exec("CREATE TABLE IF NOT EXISTS income_info (" + cfg.IncomeInfo + ");")
-	dataBase.Exec("CREATE TABLE IF NOT EXISTS top (" + cfg.Top + ");")
-	dataBase.Exec("CREATE INDEX balanceindex ON users (balance);")
+	if err = dataBase.Ping(); err != nil {
+		return nil, errors.Wrap(err, "ping database")
+	}
 
-	dataBase.Close()
+	return dataBase, nil
+}
 
-	dataBase, err = sql.Open(dbDriver, cfg.DBCfg.User+cfg.DBCfg.Password+"@/"+cfg.DBCfg.Names[dbLang]) //TODO: refactor
+func openMySQL(dbName string) (*sql.DB, error) {
+	server, err := sql.Open(mysqlDriver, cfg.App.DB.DataSourceName(""))
 	if err != nil {
-		log.Fatalf("Failed open database: %s\n", err.Error())
+		return nil, errors.Wrap(err, "open database server")
 	}
+	defer server.Close()
 
-	dataBase, err = sql.Open(dbDriver, cfg.DBCfg.User+cfg.DBCfg.Password+"@/"+cfg.DBCfg.Names[dbLang]) //TODO: refactor
-	if err != nil {
-		log.Fatalf("Failed open database: %s\n", err.Error())
+	if _, err = server.Exec("CREATE DATABASE IF NOT EXISTS " + dbName + ";"); err != nil {
+		return nil, errors.Wrap(err, "create database")
 	}
 
-	//_, err = dataBase.Exec("ALTER TABLE users ADD COLUMN father_id bigint NOT NULL AFTER miner_level;")
-	//if err != nil && err.Error() != "Error 1060: Duplicate column name 'father_id'" {
-	//	log.Fatalln(err)
-	//}
-	//
-	//_, err = dataBase.Exec("ALTER TABLE users ADD COLUMN all_referrals text NOT NULL AFTER father_id;")
-	//if err != nil && err.Error() != "Error 1060: Duplicate column name 'all_referrals'" {
-	//	log.Fatalln(err)
-	//}
-
-	migrateReferralFriends(dataBase)
-
-	//_, err = dataBase.Exec("ALTER TABLE users DROP COLUMN referral_count;")
-	//if err != nil && err.Error() != "Error 1091: Can't DROP COLUMN `referral_count`; check that it exists" {
-	//	log.Fatalln(err)
-	//}
-
-	err = dataBase.Ping()
+	dataBase, err := sql.Open(mysqlDriver, cfg.App.DB.DataSourceName(dbName))
 	if err != nil {
-		log.Fatalf("Failed upload database: %s\n", err.Error())
+		return nil, errors.Wrap(err, "open database")
 	}
 
-	return dataBase
+	return dataBase, nil
 }
 
-func migrateReferralFriends(dataBase *sql.DB) {
-	rows, err := dataBase.Query(`SELECT * FROM users WHERE referral_count != 0;`)
-	if err != nil && err.Error() != "Error 1054: Unknown column 'referral_count' in 'where clause'" {
-		log.Fatalln(err)
+// openSQLite keeps the database of the bot in one file, the writers
+// wait for each other instead of failing with "database is locked"
+func openSQLite(dbName string) (*sql.DB, error) {
+	if err := os.MkdirAll(cfg.App.DB.Path, 0700); err != nil {
+		return nil, errors.Wrap(err, "create database dir")
 	}
 
-	if rows == nil {
-		return
+	dataBase, err := sql.Open(sqliteDriver, "file:"+cfg.App.DB.SQLiteFile(dbName)+sqliteParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "open database")
 	}
 
-	users, err := readCustomUser(rows)
+	return dataBase, nil
+}
+
+// UploadDataBase connects to the database of the bot and refuses
+// to start while the schema is behind the migrations
+func UploadDataBase(dbLang string) *sql.DB {
+	dataBase, err := OpenDataBase(dbLang)
 	if err != nil {
-		log.Fatalln(err)
+		log.Fatalf("Failed upload database: %s\n", err.Error())
 	}
 
-	go func() {
-		for i, user := range users {
-			_, err = dataBase.Exec(`UPDATE users SET all_referrals = ? WHERE id = ?;`, strconv.Itoa(user.ReferralCount), user.ID)
-			if err != nil {
-				log.Fatalln(err)
-			}
-			fmt.Println(user.ID, "    UPDATED NUMBER = ", i)
-		}
-	}()
-}
+	if err = migrations.Check(dataBase); err != nil {
+		log.Fatalf("Database %s is not ready: %s, run \"migrate up\"\n", Bots[dbLang].DBName, err.Error())
+	}
 
-type customUser struct {
-	ID              int64   `json:"id"`
-	Balance         int     `json:"balance"`
-	BalanceHash     int     `json:"balance_hash"`
-	BalanceBTC      float64 `json:"balance_btc"`
-	MiningToday     int     `json:"mining_today"`
-	LastClick       int64   `json:"last_click"`
-	MinerLevel      int8    `json:"miner_level"`
-	FatherID        int64   `json:"father_id"`
-	AllReferrals    string  `json:"all_referrals"` // 10/20/30/40
-	ReferralCount   int     `json:"referral_count"`
-	AdvertChannel   int     `json:"advert_channel"`
-	TakeBonus       bool    `json:"take_bonus"`
-	Language        string  `json:"language"`
-	RegisterTime    int64   `json:"register_time"`
-	MinWithdrawal   int     `json:"min_withdrawal"`
-	FirstWithdrawal bool    `json:"first_withdrawal"`
-	Status          string  `json:"status"`
+	return dataBase
 }
 
-func readCustomUser(rows *sql.Rows) ([]*customUser, error) {
-	defer rows.Close()
-
-	var users []*customUser
-
-	for rows.Next() {
-		user := &customUser{}
-
-		if err := rows.Scan(
-			&user.ID,
-			&user.Balance,
-			&user.BalanceHash,
-			&user.BalanceBTC,
-			&user.MiningToday,
-			&user.LastClick,
-			&user.MinerLevel,
-			&user.FatherID,
-			&user.AllReferrals,
-			&user.ReferralCount,
-			&user.AdvertChannel,
-			&user.TakeBonus,
-			&user.Language,
-			&user.RegisterTime,
-			&user.MinWithdrawal,
-			&user.FirstWithdrawal,
-			&user.Status); err != nil {
-			log.Fatalln(err)
-		}
-
-		users = append(users, user)
+// Close closes the database and redis of the bot, it is called when all the updates are handled
+func (b *GlobalBot) Close() error {
+	if err := b.DataBase.Close(); err != nil {
+		return errors.Wrap(err, "close database")
 	}
 
-	return users, nil
+	return errors.Wrap(b.Rdb.Close(), "close redis")
 }
 
 func StartRedis() *redis.Client {
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     redisDefaultAddr,
-		Password: "", // no password set
-		DB:       0,  // use default DB
+		Addr:     cfg.App.Redis.Addr,
+		Password: cfg.App.Redis.Password,
+		DB:       cfg.App.Redis.DB,
 	})
 	return rdb
 }
 
+// FillBotsConfig creates the bots of the config, cfg.Init must be called before
 func FillBotsConfig() {
-	bytes, err := os.ReadFile(tokensPath)
-	if err != nil {
-		log.Fatal(err)
-	}
+	for lang, config := range cfg.App.Bots {
+		bot := &GlobalBot{
+			BotLang:       lang,
+			BotToken:      config.Token,
+			BotLink:       config.Link,
+			LanguageInBot: config.Languages,
+			Timezone:      config.Timezone,
+			DBName:        config.DBName,
+			location:      time.Local,
+		}
 
-	err = json.Unmarshal(bytes, &Bots)
-	if err != nil {
-		log.Fatal(err)
-	}
+		if bot.Timezone != "" {
+			var err error
+			bot.location, err = time.LoadLocation(bot.Timezone)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
 
-	for lang, bot := range Bots {
-		bot.BotLang = lang
+		Bots[lang] = bot
 	}
 }
 
+// Location returns the timezone of the bot, the local one if it is not set in the config
+func (b *GlobalBot) Location() *time.Location {
+	return b.location
+}
+
 func (b *GlobalBot) GetBotLang() string {
 	return b.BotLang
 }
@@ -275,6 +249,10 @@ func (b *GlobalBot) GetAdvertisingVideo(lang string, channel int) string {
 	return AdminSettings.GlobalParameters[lang].AdvertisingVideo[channel]
 }
 
+func (b *GlobalBot) GetAdvertisingPost(channel int) *Post {
+	return AdminSettings.GlobalParameters[b.BotLang].AdvertisingPost[channel]
+}
+
 func (b *GlobalBot) ButtonUnderAdvert() bool {
 	return AdminSettings.GlobalParameters[b.BotLang].Parameters.ButtonUnderAdvert
 }
@@ -284,14 +262,7 @@ func (b *GlobalBot) AdvertisingChoice(channel int) string {
 }
 
 func (b *GlobalBot) BlockUser(userID int64) error {
-	_, err := b.GetDataBase().Exec(`
-UPDATE users
-	SET status = ?
-WHERE id = ?`,
-		statusDeleted,
-		userID)
-
-	return err
+	return b.Repos.Users.Block(context.Background(), userID)
 }
 
 func (b *GlobalBot) GetMetrics(metricKey string) *prometheus.CounterVec {
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CohortDay  = "day"
+	CohortWeek = "week"
+)
+
+// Cohort is the group of users registered in the same period.
+// Retained[k] is the number of users who clicked in the k-th period after
+// the registration or later, Retained[0] counts the users who ever clicked.
+type Cohort struct {
+	Start    time.Time
+	Size     int
+	Retained []int
+}
+
+// Percent returns the share of the retained users in the k-th period
+func (c *Cohort) Percent(k int) float64 {
+	if c.Size == 0 {
+		return 0
+	}
+
+	return float64(c.Retained[k]) * 100 / float64(c.Size)
+}
+
+// CohortStart returns the beginning of the day or the week (from Monday) of t
+func CohortStart(t time.Time, period string) time.Time {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if period != CohortWeek {
+		return start
+	}
+
+	weekday := (int(start.Weekday()) + 6) % 7
+	return start.AddDate(0, 0, -weekday)
+}
+
+func cohortStep(t time.Time, period string, k int) time.Time {
+	if period == CohortWeek {
+		return t.AddDate(0, 0, 7*k)
+	}
+
+	return t.AddDate(0, 0, k)
+}
+
+// GetCohorts builds the last count cohorts of the bot users,
+// the empty source means all users
+func GetCohorts(dataBase *sql.DB, period, source string, count int, now time.Time) ([]*Cohort, error) {
+	cohorts := make([]*Cohort, count)
+	first := cohortStep(CohortStart(now, period), period, -(count - 1))
+	for i := range cohorts {
+		start := cohortStep(first, period, i)
+
+		var periods int
+		for cohortStep(start, period, periods).Before(now) {
+			periods++
+		}
+
+		cohorts[i] = &Cohort{
+			Start:    start,
+			Retained: make([]int, periods),
+		}
+	}
+
+	query := `
+SELECT users.register_time, users.last_click
+	FROM users
+WHERE users.register_time >= ?;`
+	args := []interface{}{first.Unix()}
+	if source != "" {
+		query = `
+SELECT users.register_time, users.last_click
+	FROM users
+	JOIN income_info ON income_info.user_id = users.id
+WHERE users.register_time >= ? AND income_info.source = ?;`
+		args = append(args, source)
+	}
+
+	rows, err := dataBase.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var registerTime, lastClick int64
+		if err = rows.Scan(&registerTime, &lastClick); err != nil {
+			return nil, errors.Wrap(err, "failed scan row")
+		}
+
+		cohort := findCohort(cohorts, period, time.Unix(registerTime, 0))
+		if cohort == nil {
+			continue
+		}
+
+		cohort.Size++
+		if lastClick == 0 {
+			continue
+		}
+
+		for k := range cohort.Retained {
+			if k != 0 && lastClick < cohortStep(cohort.Start, period, k).Unix() {
+				break
+			}
+			cohort.Retained[k]++
+		}
+	}
+
+	return cohorts, errors.Wrap(rows.Err(), "iterate rows")
+}
+
+func findCohort(cohorts []*Cohort, period string, registered time.Time) *Cohort {
+	start := CohortStart(registered, period)
+	for _, cohort := range cohorts {
+		if cohort.Start.Equal(start) {
+			return cohort
+		}
+	}
+
+	return nil
+}
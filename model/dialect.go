@@ -0,0 +1,33 @@
+package model
+
+import (
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// the queries are written for both mysql and sqlite,
+// these helpers return the parts which differ
+
+func isSQLite(dataBase *sql.DB) bool {
+	_, ok := dataBase.Driver().(*sqlite3.SQLiteDriver)
+	return ok
+}
+
+// insertIgnore starts the insert skipping the rows with the taken key
+func insertIgnore(dataBase *sql.DB) string {
+	if isSQLite(dataBase) {
+		return "INSERT OR IGNORE"
+	}
+
+	return "INSERT IGNORE"
+}
+
+// randomPercent is the random number from 0 to 99 for every row
+func randomPercent(dataBase *sql.DB) string {
+	if isSQLite(dataBase) {
+		return "ABS(RANDOM() % 100)"
+	}
+
+	return "FLOOR(RAND() * 100)"
+}
@@ -0,0 +1,227 @@
+package model
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	botNameLabel   = "bot_name"
+	commandLabel   = "command"
+	errorKeyPrefix = "error:"
+
+	// errorsAnomalyPercent of updates ended with an error marks the bot in the digest
+	errorsAnomalyPercent = 1
+)
+
+// Digest is the daily summary of the bot, the counters are
+// the increase since the previous digest or the bot start
+type Digest struct {
+	BotLang string
+	BotLink string
+
+	Updates          int
+	NewUsers         int
+	BlockedUsers     int
+	Panics           int
+	Errors           map[string]int
+	MailingSent      int
+	MailingBlocked   int
+	Withdrawals      int
+	WithdrawalAmount int
+
+	TotalUsers   int
+	DeletedUsers int
+	Balance      int64
+	BalanceHash  int64
+	BalanceBTC   float64
+}
+
+// Anomalies returns the reasons to highlight the bot in the digest
+func (d *Digest) Anomalies() []string {
+	var anomalies []string
+
+	if d.Updates == 0 {
+		anomalies = append(anomalies, "no updates")
+	}
+	if d.Panics != 0 {
+		anomalies = append(anomalies, "panics caught")
+	}
+	if d.Updates != 0 && d.TotalErrors()*100 >= d.Updates*errorsAnomalyPercent {
+		anomalies = append(anomalies, "high error rate")
+	}
+	if d.NewUsers != 0 && d.BlockedUsers > d.NewUsers {
+		anomalies = append(anomalies, "more blocked than new users")
+	}
+
+	return anomalies
+}
+
+func (d *Digest) TotalErrors() int {
+	var total int
+	for _, count := range d.Errors {
+		total += count
+	}
+
+	return total
+}
+
+var (
+	digestSnapshots   = make(map[string]map[string]float64)
+	digestSnapshotsMu sync.Mutex
+)
+
+// CollectDigest reads the bot counters and totals
+// and remembers the counters for the next digest
+func CollectDigest(bot *GlobalBot) (*Digest, error) {
+	digestSnapshotsMu.Lock()
+	defer digestSnapshotsMu.Unlock()
+
+	current, err := botCounters(bot.BotLang)
+	if err != nil {
+		return nil, errors.Wrap(err, "read counters")
+	}
+
+	previous := digestSnapshots[bot.BotLang]
+	delta := func(key string) int {
+		return int(current[key] - previous[key])
+	}
+
+	digest := &Digest{
+		BotLang:          bot.BotLang,
+		BotLink:          bot.BotLink,
+		Updates:          delta("updates"),
+		NewUsers:         delta("income"),
+		Panics:           delta("panics"),
+		Errors:           make(map[string]int),
+		MailingSent:      delta("mailing_sent"),
+		MailingBlocked:   delta("mailing_blocked"),
+		Withdrawals:      delta("withdrawals"),
+		WithdrawalAmount: delta("withdrawal_amount"),
+	}
+
+	for key, value := range current {
+		if !strings.HasPrefix(key, errorKeyPrefix) {
+			continue
+		}
+
+		if count := int(value - previous[key]); count != 0 {
+			digest.Errors[strings.TrimPrefix(key, errorKeyPrefix)] = count
+		}
+	}
+
+	if err = fillDigestTotals(bot, digest, previous); err != nil {
+		return nil, err
+	}
+	current["deleted_users"] = float64(digest.DeletedUsers)
+
+	digestSnapshots[bot.BotLang] = current
+	return digest, nil
+}
+
+func fillDigestTotals(bot *GlobalBot, digest *Digest, previous map[string]float64) error {
+	err := bot.GetDataBase().QueryRow(`
+SELECT COUNT(*),
+	COALESCE(SUM(status = ?), 0),
+	COALESCE(SUM(balance), 0),
+	COALESCE(SUM(balance_hash), 0),
+	COALESCE(SUM(balance_btc), 0)
+FROM users;`,
+		statusDeleted).
+		Scan(&digest.TotalUsers,
+			&digest.DeletedUsers,
+			&digest.Balance,
+			&digest.BalanceHash,
+			&digest.BalanceBTC)
+	if err != nil {
+		return errors.Wrap(err, "query users totals")
+	}
+
+	if lastDeleted, ok := previous["deleted_users"]; ok {
+		digest.BlockedUsers = digest.DeletedUsers - int(lastDeleted)
+	} else {
+		digest.NewUsers, err = countRegisteredSince(bot, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func countRegisteredSince(bot *GlobalBot, since time.Time) (int, error) {
+	var count int
+	err := bot.GetDataBase().QueryRow(`
+SELECT COUNT(*) FROM users WHERE register_time >= ?;`,
+		since.Unix()).
+		Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "query registered users")
+	}
+
+	return count, nil
+}
+
+func botCounters(botLang string) (map[string]float64, error) {
+	counters := make(map[string]float64)
+	vectors := map[string]*prometheus.CounterVec{
+		"updates":           HandleUpdates,
+		"income":            TotalIncome,
+		"panics":            CaughtPanics,
+		"mailing_sent":      MailToUser,
+		"mailing_blocked":   BlockUser,
+		"withdrawals":       WithdrawalRequests,
+		"withdrawal_amount": WithdrawalAmount,
+	}
+
+	for key, vector := range vectors {
+		err := readCounters(vector, botLang, func(labels map[string]string, value float64) {
+			counters[key] += value
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := readCounters(HandlerErrors, botLang, func(labels map[string]string, value float64) {
+		counters[errorKeyPrefix+labels[commandLabel]] += value
+	})
+	return counters, err
+}
+
+// readCounters passes every counter of the bot from the vector to handle
+func readCounters(vector *prometheus.CounterVec, botLang string, handle func(labels map[string]string, value float64)) error {
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		vector.Collect(metrics)
+		close(metrics)
+	}()
+
+	var err error
+	for metric := range metrics {
+		if err != nil {
+			continue
+		}
+
+		data := &dto.Metric{}
+		if err = metric.Write(data); err != nil {
+			continue
+		}
+
+		labels := make(map[string]string)
+		for _, pair := range data.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+
+		if labels[botNameLabel] == botLang {
+			handle(labels, data.GetCounter().GetValue())
+		}
+	}
+
+	return errors.Wrap(err, "write metric")
+}
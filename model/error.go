@@ -17,8 +17,36 @@ const (
 	// ErrScanSqlRow error scan sql row.
 	ErrScanSqlRow = Error("failed scan sql row")
 
-	// ErrRedisNil error redis: nil.
-	ErrRedisNil = Error("redis: nil")
+	// ErrStateNotFound error conversation state is not saved or already expired.
+	ErrStateNotFound = Error("state not found")
+	// ErrInvalidSourceSlug error custom slug contains forbidden symbols.
+	ErrInvalidSourceSlug = Error("invalid source slug")
+	// ErrSourceSlugTaken error custom slug already used by another link.
+	ErrSourceSlugTaken = Error("source slug already taken")
+	// ErrInvalidRewardsText error rewards matrix text can't be parsed.
+	ErrInvalidRewardsText = Error("invalid rewards text")
+	// ErrInvalidSchedule error mailing schedule can't be parsed.
+	ErrInvalidSchedule = Error("invalid schedule")
+	// ErrScheduleInPast error one-time mailing is scheduled for the past.
+	ErrScheduleInPast = Error("schedule in past")
+	// ErrMailingInProgress error another mailing is not finished yet.
+	ErrMailingInProgress = Error("mailing in progress")
+	// ErrNoMailingUsers error no active user matches the mailing audience.
+	ErrNoMailingUsers = Error("no users for mailing")
+	// ErrWinnerAlreadySent error winner of the A/B test was already sent to the holdout.
+	ErrWinnerAlreadySent = Error("winner already sent")
+	// ErrPostEmpty error post has neither text nor media.
+	ErrPostEmpty = Error("post is empty")
+	// ErrPostTextTooLong error post text doesn't fit the message or the caption.
+	ErrPostTextTooLong = Error("post text too long")
+	// ErrPostButtonsWithoutText error album buttons need the text to be sent with.
+	ErrPostButtonsWithoutText = Error("post buttons without text")
+	// ErrPostNoMedia error message has no photo or video.
+	ErrPostNoMedia = Error("no media in message")
+	// ErrPostAlbumFull error album already has the maximum of media.
+	ErrPostAlbumFull = Error("post album full")
+	// ErrInvalidPostButtons error post buttons text can't be parsed.
+	ErrInvalidPostButtons = Error("invalid post buttons")
 )
 
 type Error string
@@ -0,0 +1,157 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ExportFilter limits the exported rows, empty fields are not applied
+type ExportFilter struct {
+	From   int64
+	To     int64
+	Lang   string
+	Status string
+}
+
+func (f *ExportFilter) Empty() bool {
+	return f.From == 0 && f.To == 0 && f.Lang == "" && f.Status == ""
+}
+
+// ExportTable describes how the table is read for the CSV export,
+// the filter columns left empty are not supported by the table
+type ExportTable struct {
+	Name         string
+	query        string
+	dateColumn   string
+	langColumn   string
+	statusColumn string
+}
+
+// ExportTables lists the tables available for the export in the menu order,
+// a new table only needs a new entry here
+var ExportTables = []*ExportTable{
+	{
+		Name:         "users",
+		query:        "SELECT users.* FROM users",
+		dateColumn:   "users.register_time",
+		langColumn:   "users.lang",
+		statusColumn: "users.status",
+	},
+	{
+		Name: "income_info",
+		query: `SELECT income_info.*, users.register_time, users.lang, users.status
+	FROM income_info
+	LEFT JOIN users ON users.id = income_info.user_id`,
+		dateColumn:   "users.register_time",
+		langColumn:   "users.lang",
+		statusColumn: "users.status",
+	},
+	{
+		Name:  "links",
+		query: "SELECT links.* FROM links",
+	},
+	{
+		Name:       "source_links",
+		query:      "SELECT " + SourceLinkColumns + " FROM source_links",
+		dateColumn: "source_links.created_at",
+	},
+}
+
+func GetExportTable(name string) *ExportTable {
+	for _, table := range ExportTables {
+		if table.Name == name {
+			return table
+		}
+	}
+
+	return nil
+}
+
+func (t *ExportTable) SupportsFilter() bool {
+	return t.dateColumn != "" || t.langColumn != "" || t.statusColumn != ""
+}
+
+func (t *ExportTable) buildQuery(filter *ExportFilter) (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if t.dateColumn != "" && filter.From != 0 {
+		conditions = append(conditions, t.dateColumn+" >= ?")
+		args = append(args, filter.From)
+	}
+	if t.dateColumn != "" && filter.To != 0 {
+		conditions = append(conditions, t.dateColumn+" < ?")
+		args = append(args, filter.To)
+	}
+	if t.langColumn != "" && filter.Lang != "" {
+		conditions = append(conditions, t.langColumn+" = ?")
+		args = append(args, filter.Lang)
+	}
+	if t.statusColumn != "" && filter.Status != "" {
+		conditions = append(conditions, t.statusColumn+" = ?")
+		args = append(args, filter.Status)
+	}
+
+	if len(conditions) == 0 {
+		return t.query + ";", args
+	}
+
+	return t.query + "\nWHERE " + strings.Join(conditions, " AND ") + ";", args
+}
+
+// ExportCSV writes the filtered table to w row by row, so the table
+// is never loaded into memory. Returns the number of written rows.
+func ExportCSV(dataBase *sql.DB, table *ExportTable, filter *ExportFilter, w io.Writer) (int, error) {
+	query, args := table.buildQuery(filter)
+	rows, err := dataBase.Query(query, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "execute query")
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, errors.Wrap(err, "get columns")
+	}
+
+	writer := csv.NewWriter(w)
+	if err = writer.Write(columns); err != nil {
+		return 0, errors.Wrap(err, "write header")
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+
+	var count int
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return count, errors.Wrap(err, "failed scan row")
+		}
+
+		for i, value := range values {
+			record[i] = string(value)
+		}
+
+		if err = writer.Write(record); err != nil {
+			return count, errors.Wrap(err, "write record")
+		}
+		count++
+	}
+
+	if err = rows.Err(); err != nil {
+		return count, errors.Wrap(err, "iterate rows")
+	}
+
+	writer.Flush()
+	return count, errors.Wrap(writer.Error(), "flush writer")
+}
@@ -1,6 +1,7 @@
 package model
 
 type IncomeInfo struct {
-	UserID int64  `json:"user_id,omitempty"`
-	Source string `json:"source,omitempty"`
+	UserID     int64  `json:"user_id,omitempty"`
+	Source     string `json:"source,omitempty"`
+	SourceHash string `json:"source_hash,omitempty"`
 }
@@ -3,9 +3,8 @@ package model
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
-	"strconv"
+	"sort"
 	"sync"
 )
 
@@ -18,7 +17,14 @@ const (
 )
 
 type Admin struct {
+	// mu guards the lists edited in the admin panel while the handlers read them:
+	// the partners, the banned users and the segments
+	mu sync.RWMutex
+
 	AdminID          map[int64]*AdminUser         `json:"admin_id"`
+	PartnerID        map[int64]*Partner           `json:"partner_id"`
+	BannedID         map[int64]bool               `json:"banned_id"`
+	Segments         map[string]*Segment          `json:"segments"`
 	GlobalParameters map[string]*GlobalParameters `json:"global_parameters"`
 }
 
@@ -30,6 +36,7 @@ type GlobalParameters struct {
 	AdvertisingPhoto  map[int]string
 	AdvertisingVideo  map[int]string
 	AdvertisingChoice map[int]string
+	AdvertisingPost   map[int]*Post `json:"advertising_post"`
 }
 
 type AdminUser struct {
@@ -38,6 +45,13 @@ type AdminUser struct {
 	SpecialPossibility bool   `json:"special_possibility"`
 }
 
+// Partner can only see the statistics of the source links he owns
+type Partner struct {
+	Language  string  `json:"language"`
+	FirstName string  `json:"first_name"`
+	Payout    float64 `json:"payout"` // owed for every activated user
+}
+
 type Params struct {
 	BonusAmount         int           `json:"bonus_amount"`
 	MinWithdrawalAmount int           `json:"min_withdrawal_amount"`
@@ -345,6 +359,18 @@ func UploadAdminSettings() {
 }
 
 func validateSettings(settings *Admin, lang string) {
+	if settings.PartnerID == nil {
+		settings.PartnerID = make(map[int64]*Partner)
+	}
+
+	if settings.BannedID == nil {
+		settings.BannedID = make(map[int64]bool)
+	}
+
+	if settings.Segments == nil {
+		settings.Segments = make(map[string]*Segment)
+	}
+
 	if settings.GlobalParameters == nil {
 		settings.GlobalParameters = make(map[string]*GlobalParameters)
 	}
@@ -418,10 +444,15 @@ func validateSettings(settings *Admin, lang string) {
 	if settings.GlobalParameters[lang].AdvertisingVideo == nil {
 		settings.GlobalParameters[lang].AdvertisingVideo = make(map[int]string)
 	}
+	if settings.GlobalParameters[lang].AdvertisingPost == nil {
+		settings.GlobalParameters[lang].AdvertisingPost = make(map[int]*Post)
+	}
 }
 
 func SaveAdminSettings() {
+	AdminSettings.mu.RLock()
 	data, err := json.MarshalIndent(AdminSettings, "", "  ")
+	AdminSettings.mu.RUnlock()
 	if err != nil {
 		panic(err)
 	}
@@ -431,6 +462,42 @@ func SaveAdminSettings() {
 	}
 }
 
+// IsBanned reports whether the updates of the user are ignored, the list is edited in the admin panel
+func (a *Admin) IsBanned(userID int64) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.BannedID[userID]
+}
+
+func (a *Admin) Ban(userID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.BannedID[userID] = true
+}
+
+func (a *Admin) Unban(userID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.BannedID, userID)
+}
+
+// BannedIDs returns the banned users in the ascending order
+func (a *Admin) BannedIDs() []int64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	ids := make([]int64, 0, len(a.BannedID))
+	for id := range a.BannedID {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
 func (a *Admin) GetCurrency(lang string) string {
 	return a.GlobalParameters[lang].Parameters.Currency
 }
@@ -459,6 +526,10 @@ func (a *Admin) UpdateAdvertVideo(lang string, channel int, value string) {
 	a.GlobalParameters[lang].AdvertisingVideo[channel] = value
 }
 
+func (a *Admin) UpdateAdvertPost(lang string, channel int, post *Post) {
+	a.GlobalParameters[lang].AdvertisingPost[channel] = post
+}
+
 func (a *Admin) UpdateAdvertChoice(lang string, channel int, value string) {
 	a.GlobalParameters[lang].AdvertisingChoice[channel] = value
 }
@@ -573,36 +644,3 @@ func (a *Admin) DeleteMinerLevel(lang string, level int) {
 func remove(slice []int, s int) []int {
 	return append(slice[:s], slice[s+1:]...)
 }
-
-// ----------------------------------------------------
-//
-// Update Statistic
-//
-// ----------------------------------------------------
-
-type UpdateInfo struct {
-	Mu      *sync.Mutex
-	Counter int
-}
-
-var UpdateStatistic *UpdateInfo
-
-func UploadUpdateStatistic() {
-	info := &UpdateInfo{}
-	info.Mu = new(sync.Mutex)
-	strStatistic, err := Bots["it"].Rdb.Get("update_statistic").Result()
-	if err != nil {
-		UpdateStatistic = info
-		return
-	}
-
-	info.Counter, _ = strconv.Atoi(strStatistic)
-	UpdateStatistic = info
-}
-
-func SaveUpdateStatistic() {
-	_, err := Bots["it"].Rdb.Set("update_statistic", strconv.Itoa(UpdateStatistic.Counter), 0).Result()
-	if err != nil {
-		log.Println(err)
-	}
-}
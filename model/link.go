@@ -1,7 +1,7 @@
 package model
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"math/rand"
 
@@ -21,15 +21,23 @@ type ReferralLinkInfo struct {
 	Source     string
 }
 
-// EncodeLink generates a link and saves user data to the database
-func EncodeLink(database *sql.DB, botLink string, link *ReferralLinkInfo) (string, error) {
-	link.HashKey = getHash()
+// EncodeLink generates a link and saves user data to the repository.
+// A preset HashKey is kept as is, otherwise a random one is generated.
+func EncodeLink(ctx context.Context, links LinkRepo, botLink string, link *ReferralLinkInfo) (string, error) {
+	if link.HashKey == "" {
+		link.HashKey = getHash()
+	}
 
-	if err := saveLinkInDataBase(database, link); err != nil {
-		return "", errors.Wrap(err, "save link in database")
+	if err := links.Save(ctx, link); err != nil {
+		return "", errors.Wrap(err, "save link")
 	}
 
-	return fmt.Sprintf(botLinkBase, botLink, link.HashKey), nil
+	return MakeBotLink(botLink, link.HashKey), nil
+}
+
+// MakeBotLink returns the start link of the bot with the given hash key
+func MakeBotLink(botLink, hashKey string) string {
+	return fmt.Sprintf(botLinkBase, botLink, hashKey)
 }
 
 func getHash() string {
@@ -43,50 +51,3 @@ func getHash() string {
 	}
 	return key
 }
-
-func saveLinkInDataBase(dataBase *sql.DB, link *ReferralLinkInfo) error {
-	_, err := dataBase.Exec("INSERT INTO links VALUES (?, ?, ?)",
-		link.HashKey,
-		link.ReferralID,
-		link.Source)
-	if err != nil {
-		return errors.Wrap(err, "make exec in database")
-	}
-
-	return nil
-}
-
-// DecodeLink using a hash from the database returns information about the link
-func DecodeLink(dataBase *sql.DB, hashKey string) (*ReferralLinkInfo, error) {
-	rows, err := dataBase.Query("SELECT * FROM links WHERE hash = ?",
-		hashKey)
-	if err != nil {
-		return nil, errors.Wrap(err, "execute query")
-	}
-	defer rows.Close()
-
-	linkInfo, err := scanLinkFromRows(rows)
-	if err != nil {
-		return nil, errors.Wrap(err, "scan link info")
-	}
-
-	return linkInfo, nil
-}
-
-func scanLinkFromRows(rows *sql.Rows) (*ReferralLinkInfo, error) {
-	for rows.Next() {
-		link := &ReferralLinkInfo{}
-
-		err := rows.Scan(
-			&link.HashKey,
-			&link.ReferralID,
-			&link.Source)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed scan row")
-		}
-
-		return link, nil
-	}
-
-	return nil, nil
-}
@@ -0,0 +1,260 @@
+package model
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// HoldoutVariant is the user of the test audience left for the winner
+	HoldoutVariant = -1
+
+	MaxMailingVariants = 5
+
+	// holdoutUsersUpdate moves the holdout users of the test from one status to another
+	holdoutUsersUpdate = `
+UPDATE users
+	SET status = ?
+WHERE status = ?
+	AND id IN (SELECT user_id FROM mailing_ab_users WHERE report_id = ? AND variant = ?);`
+)
+
+// MailingVariant is the post of the A/B test sent to the percent of the audience
+type MailingVariant struct {
+	Post    *Post `json:"post"`
+	Percent int   `json:"percent"`
+}
+
+// VariantStatistic is the delivery and the clicks of the variant of the test
+type VariantStatistic struct {
+	Variant   int
+	Users     int
+	Delivered int
+	Clicks    int
+}
+
+func (v *VariantStatistic) CTR() float64 {
+	if v.Delivered == 0 {
+		return 0
+	}
+	return float64(v.Clicks) * 100 / float64(v.Delivered)
+}
+
+// VariantName is the letter of the variant shown to the admin
+func VariantName(variant int) string {
+	return string(rune('A' + variant))
+}
+
+// EqualPercents splits the whole audience between the variants
+func EqualPercents(count int) []int {
+	if count == 0 {
+		return nil
+	}
+
+	percents := make([]int, count)
+	for i := range percents {
+		percents[i] = 100 / count
+	}
+	percents[count-1] += 100 % count
+
+	return percents
+}
+
+// ParseVariantPercents accepts the percent of every variant like "20 20",
+// the rest of the audience waits for the winner. 0 splits the whole audience equally
+func ParseVariantPercents(text string, count int) ([]int, bool) {
+	if count < 2 {
+		return nil, false
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "0" {
+		return EqualPercents(count), true
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) != count {
+		return nil, false
+	}
+
+	var sum int
+	percents := make([]int, count)
+	for i, field := range fields {
+		percent, err := strconv.Atoi(strings.TrimSuffix(field, "%"))
+		if err != nil || percent <= 0 {
+			return nil, false
+		}
+		percents[i] = percent
+		sum += percent
+	}
+
+	return percents, sum <= 100
+}
+
+// SplitMailingVariants spreads the users marked for the mailing between the variants
+// by the random bucket from 0 to 99, the users out of the variant percents are
+// returned to the active ones as the holdout. It returns the size of the holdout
+func SplitMailingVariants(dataBase *sql.DB, reportID int64, variants []*MailingVariant) (int64, error) {
+	_, err := dataBase.Exec(`
+INSERT INTO mailing_ab_users
+	(report_id, user_id, variant)
+SELECT ?, id, `+randomPercent(dataBase)+`
+	FROM users
+WHERE status = ?;`,
+		reportID,
+		statusMailing)
+	if err != nil {
+		return 0, errors.Wrap(err, "insert test users")
+	}
+
+	var (
+		cases  []string
+		args   []interface{}
+		border int
+	)
+	for i, variant := range variants {
+		border += variant.Percent
+		cases = append(cases, "WHEN variant < ? THEN ?")
+		args = append(args, border, i)
+	}
+	args = append(args, HoldoutVariant, reportID)
+
+	_, err = dataBase.Exec(`
+UPDATE mailing_ab_users
+	SET variant = CASE `+strings.Join(cases, " ")+` ELSE ? END
+WHERE report_id = ?;`,
+		args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "split test users")
+	}
+
+	result, err := dataBase.Exec(holdoutUsersUpdate,
+		statusActive,
+		statusMailing,
+		reportID,
+		HoldoutVariant)
+	if err != nil {
+		return 0, errors.Wrap(err, "return holdout users")
+	}
+
+	return result.RowsAffected()
+}
+
+func MarkVariantDelivered(dataBase *sql.DB, reportID, userID int64) error {
+	_, err := dataBase.Exec(`
+UPDATE mailing_ab_users
+	SET delivered = true
+WHERE report_id = ?
+	AND user_id = ?;`,
+		reportID,
+		userID)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+// GetUserVariant returns the variant the user got in the test, 0 if the user was not in it
+func GetUserVariant(dataBase *sql.DB, reportID, userID int64) (int, error) {
+	var variant int
+	err := dataBase.QueryRow(`
+SELECT variant FROM mailing_ab_users WHERE report_id = ? AND user_id = ?;`,
+		reportID,
+		userID).
+		Scan(&variant)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "get user variant")
+	}
+
+	return variant, nil
+}
+
+// GetVariantStatistics returns the statistics of every variant of the test and the holdout size
+func GetVariantStatistics(dataBase *sql.DB, reportID int64) ([]*VariantStatistic, int, error) {
+	rows, err := dataBase.Query(`
+SELECT a.variant, COUNT(*), COALESCE(SUM(a.delivered), 0), COUNT(c.user_id)
+	FROM mailing_ab_users a
+	LEFT JOIN mailing_clicks c ON c.report_id = a.report_id AND c.user_id = a.user_id
+WHERE a.report_id = ?
+GROUP BY a.variant
+ORDER BY a.variant;`,
+		reportID)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "execute query")
+	}
+	defer rows.Close()
+
+	var (
+		statistics []*VariantStatistic
+		holdout    int
+	)
+	for rows.Next() {
+		statistic := &VariantStatistic{}
+		if err = rows.Scan(
+			&statistic.Variant,
+			&statistic.Users,
+			&statistic.Delivered,
+			&statistic.Clicks); err != nil {
+			return nil, 0, errors.Wrap(err, ErrScanSqlRow.Error())
+		}
+
+		if statistic.Variant == HoldoutVariant {
+			holdout = statistic.Users
+			continue
+		}
+		statistics = append(statistics, statistic)
+	}
+
+	return statistics, holdout, nil
+}
+
+// Winner returns the variant with the best click-through or -1 while nobody got the test
+func Winner(statistics []*VariantStatistic) int {
+	winner := -1
+	var best float64
+	for _, statistic := range statistics {
+		if statistic.Delivered == 0 {
+			continue
+		}
+		if ctr := statistic.CTR(); winner == -1 || ctr > best {
+			winner, best = statistic.Variant, ctr
+		}
+	}
+
+	return winner
+}
+
+// MarkHoldoutMailing marks the active users of the test holdout for the mailing of the winner
+func MarkHoldoutMailing(dataBase *sql.DB, testReportID int64) (int64, error) {
+	result, err := dataBase.Exec(holdoutUsersUpdate,
+		statusMailing,
+		statusActive,
+		testReportID,
+		HoldoutVariant)
+	if err != nil {
+		return 0, errors.Wrap(err, "mark holdout users")
+	}
+
+	return result.RowsAffected()
+}
+
+// WinnerSent reports whether the winner of the test was already sent to the holdout
+func WinnerSent(dataBase *sql.DB, testReportID int64) (bool, error) {
+	var count int
+	err := dataBase.QueryRow(`
+SELECT COUNT(*) FROM mailing_reports WHERE test_report_id = ?;`,
+		testReportID).
+		Scan(&count)
+	if err != nil {
+		return false, errors.Wrap(err, "count winner mailings")
+	}
+
+	return count != 0, nil
+}
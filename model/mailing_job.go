@@ -0,0 +1,257 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	RepeatOnce   = "once"
+	RepeatDaily  = "daily"
+	RepeatWeekly = "weekly"
+
+	mailingJobColumns = "id, channel, segment, repeat_mode, next_run, created_by"
+
+	scheduleDateLayout  = "02.01.2006 15:04"
+	scheduleClockLayout = "15:04"
+)
+
+var scheduleWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// MailingJob is the mailing started by the scheduler at NextRun,
+// the recurring jobs move NextRun forward after every start
+type MailingJob struct {
+	ID        int64
+	Channel   int
+	Segment   *Segment // nil sends to every user of the channel
+	Repeat    string
+	NextRun   int64
+	CreatedBy int64
+}
+
+// ParseMailingSchedule reads the schedule in the location of the bot:
+// "25.10.2022 18:00" runs once, "daily 18:00" every day and "mon 18:00" every week
+func ParseMailingSchedule(value string, loc *time.Location, now time.Time) (string, time.Time, error) {
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) != 2 {
+		return "", time.Time{}, ErrInvalidSchedule
+	}
+
+	if date, err := time.ParseInLocation(scheduleDateLayout, fields[0]+" "+fields[1], loc); err == nil {
+		if !date.After(now) {
+			return "", time.Time{}, ErrScheduleInPast
+		}
+		return RepeatOnce, date, nil
+	}
+
+	clock, err := time.Parse(scheduleClockLayout, fields[1])
+	if err != nil {
+		return "", time.Time{}, ErrInvalidSchedule
+	}
+
+	local := now.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+
+	if fields[0] == RepeatDaily {
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return RepeatDaily, next, nil
+	}
+
+	for weekday, name := range scheduleWeekdays {
+		if fields[0] != name {
+			continue
+		}
+
+		next = next.AddDate(0, 0, (weekday-int(next.Weekday())+7)%7)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return RepeatWeekly, next, nil
+	}
+
+	return "", time.Time{}, ErrInvalidSchedule
+}
+
+// Schedule formats the job schedule in the same way ParseMailingSchedule reads it
+func (j *MailingJob) Schedule(loc *time.Location) string {
+	next := time.Unix(j.NextRun, 0).In(loc)
+
+	switch j.Repeat {
+	case RepeatDaily:
+		return RepeatDaily + " " + next.Format(scheduleClockLayout)
+	case RepeatWeekly:
+		return scheduleWeekdays[next.Weekday()] + " " + next.Format(scheduleClockLayout)
+	default:
+		return next.Format(scheduleDateLayout)
+	}
+}
+
+// Advance moves the recurring job to the next run after now,
+// the missed runs are skipped. It returns false for the finished job
+func (j *MailingJob) Advance(loc *time.Location, now time.Time) bool {
+	days := 1
+	switch j.Repeat {
+	case RepeatDaily:
+	case RepeatWeekly:
+		days = 7
+	default:
+		return false
+	}
+
+	next := time.Unix(j.NextRun, 0).In(loc)
+	for !next.After(now) {
+		next = next.AddDate(0, 0, days)
+	}
+
+	j.NextRun = next.Unix()
+	return true
+}
+
+func CreateMailingJob(dataBase *sql.DB, job *MailingJob) error {
+	segment, err := marshalJobSegment(job.Segment)
+	if err != nil {
+		return err
+	}
+
+	result, err := dataBase.Exec(`
+INSERT INTO mailing_jobs
+	(channel, segment, repeat_mode, next_run, created_by)
+VALUES (?, ?, ?, ?, ?);`,
+		job.Channel,
+		segment,
+		job.Repeat,
+		job.NextRun,
+		job.CreatedBy)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	job.ID, err = result.LastInsertId()
+	return errors.Wrap(err, "get job id")
+}
+
+// GetMailingJobs returns the upcoming jobs from the nearest one
+func GetMailingJobs(dataBase *sql.DB) ([]*MailingJob, error) {
+	rows, err := dataBase.Query(`
+SELECT ` + mailingJobColumns + `
+	FROM mailing_jobs
+ORDER BY next_run;`)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	return readMailingJobs(rows)
+}
+
+// GetDueMailingJobs returns the jobs which have to be started by now
+func GetDueMailingJobs(dataBase *sql.DB, now time.Time) ([]*MailingJob, error) {
+	rows, err := dataBase.Query(`
+SELECT `+mailingJobColumns+`
+	FROM mailing_jobs
+WHERE next_run <= ?
+ORDER BY next_run;`,
+		now.Unix())
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	return readMailingJobs(rows)
+}
+
+// GetMailingJob returns the job by its id or nil if it is not found
+func GetMailingJob(dataBase *sql.DB, id int64) (*MailingJob, error) {
+	rows, err := dataBase.Query(`
+SELECT `+mailingJobColumns+`
+	FROM mailing_jobs
+WHERE id = ?;`,
+		id)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	jobs, err := readMailingJobs(rows)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+
+	return jobs[0], nil
+}
+
+func UpdateMailingJobSchedule(dataBase *sql.DB, job *MailingJob) error {
+	_, err := dataBase.Exec(`
+UPDATE mailing_jobs
+	SET repeat_mode = ?,
+	    next_run = ?
+WHERE id = ?;`,
+		job.Repeat,
+		job.NextRun,
+		job.ID)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+func DeleteMailingJob(dataBase *sql.DB, id int64) error {
+	_, err := dataBase.Exec(`
+DELETE FROM mailing_jobs
+WHERE id = ?;`,
+		id)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+func readMailingJobs(rows *sql.Rows) ([]*MailingJob, error) {
+	defer rows.Close()
+
+	var jobs []*MailingJob
+
+	for rows.Next() {
+		job := &MailingJob{}
+		var segment string
+
+		if err := rows.Scan(
+			&job.ID,
+			&job.Channel,
+			&segment,
+			&job.Repeat,
+			&job.NextRun,
+			&job.CreatedBy); err != nil {
+			return nil, errors.Wrap(err, ErrScanSqlRow.Error())
+		}
+
+		if segment != "" {
+			job.Segment = &Segment{}
+			if err := json.Unmarshal([]byte(segment), job.Segment); err != nil {
+				return nil, errors.Wrap(err, "unmarshal job segment")
+			}
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func marshalJobSegment(segment *Segment) (string, error) {
+	if segment == nil {
+		return "", nil
+	}
+
+	data, err := json.Marshal(segment)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal job segment")
+	}
+
+	return string(data), nil
+}
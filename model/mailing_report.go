@@ -0,0 +1,271 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MailingRunning  = "running"
+	MailingFinished = "finished"
+	MailingCanceled = "canceled"
+	// MailingFailed is the mailing which failed to start, its users are returned to the active ones
+	MailingFailed = "failed"
+
+	mailingReportColumns = "id, started_by, started_at, finished_at, status, with_button, total, sent, blocked, failed, errors, variants, test_report_id"
+)
+
+// MailingReport is the delivery statistics of one mailing,
+// it is saved while the mailing runs so it survives restarts
+type MailingReport struct {
+	ID         int64
+	StartedBy  int64 // 0 for the mailing started by the scheduler
+	StartedAt  int64
+	FinishedAt int64
+	Status     string
+	WithButton bool
+
+	Total   int
+	Sent    int
+	Blocked int
+	Failed  int
+	Errors  map[string]int // failed deliveries by the error type
+
+	// the posts of the A/B test or the winner, empty for the channel advertisement
+	Variants     []*MailingVariant
+	TestReportID int64 // the A/B test which winner is sent
+
+	Clicks int // unique users pressed the button, filled by GetMailingReport
+}
+
+func (r *MailingReport) Processed() int {
+	return r.Sent + r.Blocked + r.Failed
+}
+
+func (r *MailingReport) Remaining() int {
+	if remaining := r.Total - r.Processed(); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// ETA estimates the time left by the average speed since the start
+func (r *MailingReport) ETA(now time.Time) time.Duration {
+	processed := r.Processed()
+	if processed == 0 {
+		return 0
+	}
+
+	elapsed := now.Sub(time.Unix(r.StartedAt, 0))
+	return elapsed / time.Duration(processed) * time.Duration(r.Remaining())
+}
+
+// ABTest is true when the audience is split between the variants
+func (r *MailingReport) ABTest() bool {
+	return len(r.Variants) > 1
+}
+
+// CTR is the percent of the delivered messages which button was pressed
+func (r *MailingReport) CTR() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+	return float64(r.Clicks) * 100 / float64(r.Sent)
+}
+
+func CreateMailingReport(dataBase *sql.DB, report *MailingReport) error {
+	variants, err := json.Marshal(report.Variants)
+	if err != nil {
+		return errors.Wrap(err, "marshal variants")
+	}
+
+	result, err := dataBase.Exec(`
+INSERT INTO mailing_reports
+	(started_by, started_at, status, with_button, total, errors, variants, test_report_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);`,
+		report.StartedBy,
+		report.StartedAt,
+		report.Status,
+		report.WithButton,
+		report.Total,
+		"{}",
+		string(variants),
+		report.TestReportID)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	report.ID, err = result.LastInsertId()
+	return errors.Wrap(err, "get report id")
+}
+
+func UpdateMailingReport(dataBase *sql.DB, report *MailingReport) error {
+	reportErrors, err := json.Marshal(report.Errors)
+	if err != nil {
+		return errors.Wrap(err, "marshal errors")
+	}
+
+	_, err = dataBase.Exec(`
+UPDATE mailing_reports
+	SET finished_at = ?,
+	    status = ?,
+	    total = ?,
+	    sent = ?,
+	    blocked = ?,
+	    failed = ?,
+	    errors = ?
+WHERE id = ?;`,
+		report.FinishedAt,
+		report.Status,
+		report.Total,
+		report.Sent,
+		report.Blocked,
+		report.Failed,
+		string(reportErrors),
+		report.ID)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+// GetMailingReport returns the report with its clicks or nil if it is not found
+func GetMailingReport(ctx context.Context, dataBase *sql.DB, id int64) (*MailingReport, error) {
+	rows, err := dataBase.QueryContext(ctx, `
+SELECT `+mailingReportColumns+`
+	FROM mailing_reports
+WHERE id = ?;`,
+		id)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	reports, err := readMailingReports(rows)
+	if err != nil || len(reports) == 0 {
+		return nil, err
+	}
+
+	report := reports[0]
+	err = dataBase.QueryRowContext(ctx, `
+SELECT COUNT(*) FROM mailing_clicks WHERE report_id = ?;`,
+		id).
+		Scan(&report.Clicks)
+	if err != nil {
+		return nil, errors.Wrap(err, "count clicks")
+	}
+
+	return report, nil
+}
+
+// GetLastMailingReports returns the latest reports from the newest one
+func GetLastMailingReports(dataBase *sql.DB, limit int) ([]*MailingReport, error) {
+	rows, err := dataBase.Query(`
+SELECT `+mailingReportColumns+`
+	FROM mailing_reports
+ORDER BY id DESC
+	LIMIT ?;`,
+		limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	return readMailingReports(rows)
+}
+
+// GetRunningMailingReport returns the report of the mailing interrupted by the restart
+func GetRunningMailingReport(dataBase *sql.DB) (*MailingReport, error) {
+	rows, err := dataBase.Query(`
+SELECT `+mailingReportColumns+`
+	FROM mailing_reports
+WHERE status = ?
+ORDER BY id DESC
+	LIMIT 1;`,
+		MailingRunning)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	reports, err := readMailingReports(rows)
+	if err != nil || len(reports) == 0 {
+		return nil, err
+	}
+
+	return reports[0], nil
+}
+
+// SaveMailingClick remembers the user pressed the button of the mailing,
+// the repeated clicks are not counted
+func SaveMailingClick(ctx context.Context, dataBase *sql.DB, reportID, userID int64) error {
+	_, err := dataBase.ExecContext(ctx, insertIgnore(dataBase)+` INTO mailing_clicks
+	(report_id, user_id)
+VALUES (?, ?);`,
+		reportID,
+		userID)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+func readMailingReports(rows *sql.Rows) ([]*MailingReport, error) {
+	defer rows.Close()
+
+	var reports []*MailingReport
+
+	for rows.Next() {
+		report := &MailingReport{}
+		var reportErrors, variants string
+
+		if err := rows.Scan(
+			&report.ID,
+			&report.StartedBy,
+			&report.StartedAt,
+			&report.FinishedAt,
+			&report.Status,
+			&report.WithButton,
+			&report.Total,
+			&report.Sent,
+			&report.Blocked,
+			&report.Failed,
+			&reportErrors,
+			&variants,
+			&report.TestReportID); err != nil {
+			return nil, errors.Wrap(err, ErrScanSqlRow.Error())
+		}
+
+		report.Errors = make(map[string]int)
+		if err := json.Unmarshal([]byte(reportErrors), &report.Errors); err != nil {
+			return nil, errors.Wrap(err, "unmarshal errors")
+		}
+		if variants != "" {
+			if err := json.Unmarshal([]byte(variants), &report.Variants); err != nil {
+				return nil, errors.Wrap(err, "unmarshal variants")
+			}
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// CancelMailingUsers returns the users still waiting for the mailing to the active ones
+func CancelMailingUsers(dataBase *sql.DB) error {
+	_, err := dataBase.Exec(`
+UPDATE users
+	SET status = ?
+WHERE status = ?;`,
+		statusActive,
+		statusMailing)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
@@ -32,6 +32,29 @@ var (
 		[]string{"bot_link", "bot_name"},
 	)
 
+	// errors
+	HandlerErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "total_handler_errors",
+			Help: "Total errors returned by handlers",
+		},
+		[]string{"bot_link", "bot_name", "command"},
+	)
+	CaughtPanics = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "total_caught_panics",
+			Help: "Total panics caught while handling updates",
+		},
+		[]string{"bot_link", "bot_name"},
+	)
+	HandlerOverruns = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "total_handler_overruns",
+			Help: "Total handlers running longer than the update deadline",
+		},
+		[]string{"bot_link", "bot_name", "command"},
+	)
+
 	// clicks
 	MoreMoneyButtonClick = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -48,6 +71,22 @@ var (
 		[]string{"bot_link", "bot_name", "advert_link", "source"},
 	)
 
+	// withdrawals
+	WithdrawalRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "total_withdrawal_requests",
+			Help: "Total accepted withdrawal requests",
+		},
+		[]string{"bot_link", "bot_name"},
+	)
+	WithdrawalAmount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "total_withdrawal_amount",
+			Help: "Total amount of accepted withdrawal requests",
+		},
+		[]string{"bot_link", "bot_name"},
+	)
+
 	// mailing
 	MailToUser = promauto.NewCounterVec(
 		prometheus.CounterOpts{
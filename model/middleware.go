@@ -0,0 +1,14 @@
+package model
+
+// Middleware wraps the handler with the work done for every update:
+// logging, metrics, checks of the user and reporting of the errors
+type Middleware func(next Handler) Handler
+
+// Chain wraps the handler with the middlewares, the first one is the outermost
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
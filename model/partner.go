@@ -0,0 +1,63 @@
+package model
+
+import "sort"
+
+func PartnerLang(userID int64) string {
+	partner, exist := AdminSettings.Partner(userID)
+	if exist {
+		return partner.Language
+	}
+	return ""
+}
+
+// Partner returns the copy of the partner, it is changed by SetPartner
+func (a *Admin) Partner(userID int64) (Partner, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	partner, exist := a.PartnerID[userID]
+	if !exist {
+		return Partner{}, false
+	}
+	return *partner, true
+}
+
+// AddPartner adds the new partner, false is returned when the user is already the partner
+func (a *Admin) AddPartner(userID int64, partner Partner) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exist := a.PartnerID[userID]; exist {
+		return false
+	}
+	a.PartnerID[userID] = &partner
+	return true
+}
+
+func (a *Admin) SetPartner(userID int64, partner Partner) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.PartnerID[userID] = &partner
+}
+
+func (a *Admin) DeletePartner(userID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.PartnerID, userID)
+}
+
+// PartnerIDs returns the partners in the ascending order
+func (a *Admin) PartnerIDs() []int64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	ids := make([]int64, 0, len(a.PartnerID))
+	for id := range a.PartnerID {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
@@ -0,0 +1,278 @@
+package model
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// AdvertPost is the advertising choice of the channel sending the built post
+	AdvertPost = "post"
+
+	PostMediaPhoto = "photo"
+	PostMediaVideo = "video"
+
+	postMaxMedia         = 10
+	postMaxRows          = 8
+	postMaxButtonsInRow  = 4
+	postMaxTextLength    = 4096
+	postMaxCaptionLength = 1024
+	postMaxCallbackData  = 64
+
+	postButtonsSeparator = "|"
+	postButtonSeparator  = " - "
+)
+
+// Post is the advertisement built by the admin: the text keeps the formatting
+// of the source message, several photos or videos are sent as the album
+type Post struct {
+	Text     string                   `json:"text"`
+	Entities []tgbotapi.MessageEntity `json:"entities,omitempty"`
+	Media    []*PostMedia             `json:"media,omitempty"`
+	Buttons  [][]*PostButton          `json:"buttons,omitempty"`
+
+	// the album of the cloned message comes in several updates
+	CloneGroupID string `json:"-"`
+}
+
+type PostMedia struct {
+	Type   string `json:"type"`
+	FileID string `json:"file_id"`
+}
+
+// PostButton opens the url or sends the callback data to the bot
+type PostButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url,omitempty"`
+	Data string `json:"data,omitempty"`
+}
+
+// Copy returns the post which can be changed without touching this one
+func (p *Post) Copy() *Post {
+	post := &Post{
+		Text:     p.Text,
+		Entities: append([]tgbotapi.MessageEntity(nil), p.Entities...),
+	}
+
+	for _, media := range p.Media {
+		copied := *media
+		post.Media = append(post.Media, &copied)
+	}
+
+	for _, row := range p.Buttons {
+		copiedRow := make([]*PostButton, len(row))
+		for i, button := range row {
+			copied := *button
+			copiedRow[i] = &copied
+		}
+		post.Buttons = append(post.Buttons, copiedRow)
+	}
+
+	return post
+}
+
+func (p *Post) Empty() bool {
+	return p.Text == "" && len(p.Media) == 0
+}
+
+// Album is true when the media are sent as the media group
+func (p *Post) Album() bool {
+	return len(p.Media) > 1
+}
+
+// Validate checks the post can be sent by telegram
+func (p *Post) Validate() error {
+	if p.Empty() {
+		return ErrPostEmpty
+	}
+
+	length := len(utf16.Encode([]rune(p.Text)))
+	switch {
+	case length > postMaxTextLength:
+		return ErrPostTextTooLong
+	case p.Album() && len(p.Buttons) != 0:
+		// the album can't have buttons, so they go with the text in the next message
+		if p.Text == "" {
+			return ErrPostButtonsWithoutText
+		}
+	case len(p.Media) != 0 && length > postMaxCaptionLength:
+		return ErrPostTextTooLong
+	}
+
+	return nil
+}
+
+// SetText takes the text with its formatting from the text or the caption of the message
+func (p *Post) SetText(message *tgbotapi.Message) bool {
+	switch {
+	case message.Text != "":
+		p.Text, p.Entities = message.Text, message.Entities
+	case message.Caption != "":
+		p.Text, p.Entities = message.Caption, message.CaptionEntities
+	default:
+		return false
+	}
+
+	return true
+}
+
+// AddMedia adds the photo or the video of the message to the album
+func (p *Post) AddMedia(message *tgbotapi.Message) error {
+	var media *PostMedia
+	switch {
+	case len(message.Photo) != 0:
+		media = &PostMedia{Type: PostMediaPhoto, FileID: message.Photo[len(message.Photo)-1].FileID}
+	case message.Video != nil:
+		media = &PostMedia{Type: PostMediaVideo, FileID: message.Video.FileID}
+	default:
+		return ErrPostNoMedia
+	}
+
+	if len(p.Media) >= postMaxMedia {
+		return ErrPostAlbumFull
+	}
+
+	p.Media = append(p.Media, media)
+	return nil
+}
+
+// Clone fills the post from the forwarded message, the next parts
+// of the same album are added to the post instead of replacing it
+func (p *Post) Clone(message *tgbotapi.Message) {
+	if message.MediaGroupID == "" || message.MediaGroupID != p.CloneGroupID {
+		*p = Post{CloneGroupID: message.MediaGroupID}
+	}
+
+	p.SetText(message)
+	_ = p.AddMedia(message)
+
+	if message.ReplyMarkup != nil && len(p.Buttons) == 0 {
+		p.Buttons = buttonsFromMarkUp(message.ReplyMarkup)
+	}
+}
+
+// buttonsFromMarkUp keeps only the url buttons, the callback data of another bot is useless here
+func buttonsFromMarkUp(markUp *tgbotapi.InlineKeyboardMarkup) [][]*PostButton {
+	var rows [][]*PostButton
+	for _, row := range markUp.InlineKeyboard {
+		var buttons []*PostButton
+		for _, button := range row {
+			if button.URL == nil {
+				continue
+			}
+			buttons = append(buttons, &PostButton{Text: button.Text, URL: *button.URL})
+		}
+
+		if len(buttons) != 0 {
+			rows = append(rows, buttons)
+		}
+	}
+
+	return rows
+}
+
+// ParsePostButtons reads one row per line, the buttons of the row are separated by "|":
+//
+//	Site - https://example.com | Earn - /make_money
+//
+// the value starting with "/" is sent back to the bot as the callback
+func ParsePostButtons(text string) ([][]*PostButton, error) {
+	var rows [][]*PostButton
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var row []*PostButton
+		for _, part := range strings.Split(line, postButtonsSeparator) {
+			button, err := parsePostButton(part)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, button)
+		}
+
+		if len(row) > postMaxButtonsInRow {
+			return nil, ErrInvalidPostButtons
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) > postMaxRows {
+		return nil, ErrInvalidPostButtons
+	}
+
+	return rows, nil
+}
+
+func parsePostButton(text string) (*PostButton, error) {
+	index := strings.LastIndex(text, postButtonSeparator)
+	if index == -1 {
+		return nil, ErrInvalidPostButtons
+	}
+
+	button := &PostButton{Text: strings.TrimSpace(text[:index])}
+	value := strings.TrimSpace(text[index+len(postButtonSeparator):])
+	if button.Text == "" || value == "" {
+		return nil, ErrInvalidPostButtons
+	}
+
+	switch {
+	case strings.HasPrefix(value, "/"):
+		if len(value) > postMaxCallbackData {
+			return nil, ErrInvalidPostButtons
+		}
+		button.Data = value
+	case strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "http://") ||
+		strings.HasPrefix(value, "tg://"):
+		button.URL = value
+	default:
+		return nil, ErrInvalidPostButtons
+	}
+
+	return button, nil
+}
+
+// ButtonsText writes the buttons back in the format of ParsePostButtons
+func (p *Post) ButtonsText() string {
+	lines := make([]string, len(p.Buttons))
+	for i, row := range p.Buttons {
+		buttons := make([]string, len(row))
+		for j, button := range row {
+			value := button.URL
+			if value == "" {
+				value = button.Data
+			}
+			buttons[j] = button.Text + postButtonSeparator + value
+		}
+		lines[i] = strings.Join(buttons, " "+postButtonsSeparator+" ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// MarkUp returns nil when the post has no buttons
+func (p *Post) MarkUp() *tgbotapi.InlineKeyboardMarkup {
+	if len(p.Buttons) == 0 {
+		return nil
+	}
+
+	markUp := tgbotapi.InlineKeyboardMarkup{}
+	for _, row := range p.Buttons {
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, button := range row {
+			if button.URL != "" {
+				buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonURL(button.Text, button.URL))
+				continue
+			}
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(button.Text, button.Data))
+		}
+		markUp.InlineKeyboard = append(markUp.InlineKeyboard, buttons)
+	}
+
+	return &markUp
+}
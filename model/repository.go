@@ -0,0 +1,84 @@
+package model
+
+import "context"
+
+// Repositories are the storages of the bot the services are built on,
+// the mysql and the in-memory implementations are in the repository package
+type Repositories struct {
+	Users  UserRepo
+	Links  LinkRepo
+	Top    TopRepo
+	Subs   SubsRepo
+	Income IncomeRepo
+}
+
+// UserRepo keeps the users and their balances
+type UserRepo interface {
+	// Get returns ErrUserNotFound when there is no such user
+	Get(ctx context.Context, id int64) (*User, error)
+	Create(ctx context.Context, user *User) error
+
+	SetLanguage(ctx context.Context, id int64, lang string) error
+	SetBalance(ctx context.Context, id int64, balance int) error
+	TakeBonus(ctx context.Context, id int64, balance int) error
+	Block(ctx context.Context, id int64) error
+
+	ResetMiningToday(ctx context.Context, id, lastClick int64) error
+	AddClick(ctx context.Context, id int64, hash int, lastClick int64) error
+	ExchangeHashToBTC(ctx context.Context, id int64, hash int, btc float64) error
+	ExchangeBTCToCurrency(ctx context.Context, id int64, btc float64, amount int) error
+	UpgradeMiner(ctx context.Context, id int64, cost int) error
+	AddReferralReward(ctx context.Context, id int64, reward int, allReferrals string) error
+
+	// TopByBalance returns the richest users with only the id and the balance
+	TopByBalance(ctx context.Context, limit int) ([]*User, error)
+	Count(ctx context.Context) (int, error)
+	CountBlocked(ctx context.Context) (int, error)
+}
+
+// LinkRepo keeps the start links of the bot and the management
+// information of the source links created by admins
+type LinkRepo interface {
+	Save(ctx context.Context, link *ReferralLinkInfo) error
+	// Get returns nil when there is no such link
+	Get(ctx context.Context, hashKey string) (*ReferralLinkInfo, error)
+
+	SaveSource(ctx context.Context, link *SourceLink) error
+	// GetSource returns nil when there is no such source link
+	GetSource(ctx context.Context, hashKey string) (*SourceLink, error)
+	// GetSources returns the page of source links sorted from newest to oldest
+	GetSources(ctx context.Context, offset, limit int) ([]*SourceLink, error)
+	CountSources(ctx context.Context) (int, error)
+	GetOwnerSources(ctx context.Context, ownerID int64) ([]*SourceLink, error)
+	// UpdateSource saves the editable fields of the source link
+	UpdateSource(ctx context.Context, link *SourceLink) error
+	IncreaseClicks(ctx context.Context, hashKey string) error
+	IncreaseRegistrations(ctx context.Context, hashKey string) error
+}
+
+// TopRepo keeps the three places of the top
+type TopRepo interface {
+	Create(ctx context.Context, place int) error
+	// Get returns the empty place when it isn't created yet
+	Get(ctx context.Context, place int) (*Top, error)
+	// GetAll returns nil while the top isn't created
+	GetAll(ctx context.Context) ([]*Top, error)
+	Update(ctx context.Context, top *Top) error
+}
+
+// SubsRepo keeps the users subscribed to the advertising channel
+type SubsRepo interface {
+	// Add does nothing for the already saved user
+	Add(ctx context.Context, userID int64) error
+	Count(ctx context.Context) (int, error)
+}
+
+// IncomeRepo keeps the source every user came from
+type IncomeRepo interface {
+	Save(ctx context.Context, info *IncomeInfo) error
+	// Get returns nil when the source of the user is unknown
+	Get(ctx context.Context, userID int64) (*IncomeInfo, error)
+	// CountActivated returns the number of users came by the source
+	// link who made at least one click
+	CountActivated(ctx context.Context, sourceHash string) (int, error)
+}
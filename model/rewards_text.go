@@ -0,0 +1,83 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseRewardsMatrix reads the matrix written one level per line
+// in the same format as String returns it:
+//
+//	1: 1-10=5, 11-100=3
+//	2: 1-100=1
+func ParseRewardsMatrix(text string) (RewardsMatrix, error) {
+	var matrix RewardsMatrix
+
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, ErrInvalidRewardsText
+		}
+
+		lvl, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || lvl != len(matrix)+1 {
+			return nil, ErrInvalidRewardsText
+		}
+
+		rewardsLvl, err := parseRewardsLvl(lvl, parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		matrix = append(matrix, rewardsLvl)
+	}
+
+	return matrix, nil
+}
+
+func parseRewardsLvl(lvl int, text string) (RewardsLvl, error) {
+	var rewardsLvl RewardsLvl
+
+	for _, rawGap := range strings.Split(text, ",") {
+		var left, right, amount int
+		_, err := fmt.Sscanf(strings.Replace(rawGap, " ", "", -1), "%d-%d=%d", &left, &right, &amount)
+		if err != nil {
+			return nil, ErrInvalidRewardsText
+		}
+
+		expectedLeft := 1
+		if len(rewardsLvl) != 0 {
+			expectedLeft = rewardsLvl[len(rewardsLvl)-1].RightBorder + 1
+		}
+
+		if left != expectedLeft || right < left || amount < 0 {
+			return nil, ErrInvalidRewardsText
+		}
+
+		rewardsLvl = append(rewardsLvl, &RewardsGap{
+			LeftBorder:  left,
+			RightBorder: right,
+			Amount:      amount,
+			Level:       lvl,
+			Index:       len(rewardsLvl) + 1,
+		})
+	}
+
+	return rewardsLvl, nil
+}
+
+func (r RewardsMatrix) String() string {
+	lines := make([]string, 0, len(r))
+
+	for i, lvl := range r {
+		gaps := make([]string, 0, len(lvl))
+		for _, gap := range lvl {
+			gaps = append(gaps, fmt.Sprintf("%d-%d=%d", gap.LeftBorder, gap.RightBorder, gap.Amount))
+		}
+
+		lines = append(lines, fmt.Sprintf("%d: %s", i+1, strings.Join(gaps, ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
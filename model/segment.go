@@ -0,0 +1,244 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	statusActive  = "active"
+	statusMailing = "mailing"
+
+	secondsInDay = 24 * 60 * 60
+)
+
+// Range is the inclusive range of the filter, the nil bound is not applied
+type Range struct {
+	From *int64 `json:"from,omitempty"`
+	To   *int64 `json:"to,omitempty"`
+}
+
+func (r Range) Empty() bool {
+	return r.From == nil && r.To == nil
+}
+
+// ParseRange reads the "from - to" range, any side can be omitted,
+// the single number sets both sides and the empty value removes the range
+func ParseRange(value string) (Range, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Range{}, true
+	}
+
+	bounds := strings.Split(value, "-")
+	if len(bounds) == 1 {
+		bounds = append(bounds, bounds[0])
+	}
+	if len(bounds) != 2 {
+		return Range{}, false
+	}
+
+	var r Range
+	for i, bound := range bounds {
+		bound = strings.TrimSpace(bound)
+		if bound == "" {
+			continue
+		}
+
+		number, err := strconv.ParseInt(bound, 10, 64)
+		if err != nil || number < 0 {
+			return Range{}, false
+		}
+
+		if i == 0 {
+			r.From = &number
+		} else {
+			r.To = &number
+		}
+	}
+
+	if r.From != nil && r.To != nil && *r.From > *r.To {
+		return Range{}, false
+	}
+
+	return r, true
+}
+
+func (r Range) String() string {
+	var from, to string
+	if r.From != nil {
+		from = strconv.FormatInt(*r.From, 10)
+	}
+	if r.To != nil {
+		to = strconv.FormatInt(*r.To, 10)
+	}
+
+	if from == to {
+		return from
+	}
+	return from + " - " + to
+}
+
+// Segment is the mailing audience, empty fields are not applied.
+// The blocked users never get into the mailing
+type Segment struct {
+	Name string `json:"name"`
+
+	Lang         string `json:"lang,omitempty"`
+	MinerLevel   Range  `json:"miner_level"`
+	Balance      Range  `json:"balance"`
+	RegisterFrom int64  `json:"register_from,omitempty"`
+	RegisterTo   int64  `json:"register_to,omitempty"`
+	ClickDaysAgo Range  `json:"click_days_ago"` // days since the last click
+	Source       string `json:"source,omitempty"`
+	Subscribed   *bool  `json:"subscribed,omitempty"`
+}
+
+// Segment returns the copy of the saved segment
+func (a *Admin) Segment(name string) (Segment, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	segment, exist := a.Segments[name]
+	if !exist {
+		return Segment{}, false
+	}
+	return *segment, true
+}
+
+// SaveSegment saves the segment under its name, the segment with the same name is replaced
+func (a *Admin) SaveSegment(segment Segment) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.Segments[segment.Name] = &segment
+}
+
+func (a *Admin) DeleteSegment(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.Segments, name)
+}
+
+// SegmentNames returns the names of the saved segments in the alphabetical order
+func (a *Admin) SegmentNames() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	names := make([]string, 0, len(a.Segments))
+	for name := range a.Segments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (s *Segment) Empty() bool {
+	return s.Lang == "" && s.MinerLevel.Empty() && s.Balance.Empty() &&
+		s.RegisterFrom == 0 && s.RegisterTo == 0 && s.ClickDaysAgo.Empty() &&
+		s.Source == "" && s.Subscribed == nil
+}
+
+func (s *Segment) conditions(channels []int, now time.Time) ([]string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	add := func(condition string, arg ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, arg...)
+	}
+
+	placeholders := make([]string, len(channels))
+	for i, channel := range channels {
+		placeholders[i] = "?"
+		args = append(args, channel)
+	}
+	conditions = append(conditions, "advert_channel IN ("+strings.Join(placeholders, ", ")+")")
+
+	if s.Lang != "" {
+		add("lang = ?", s.Lang)
+	}
+	if s.MinerLevel.From != nil {
+		add("miner_level >= ?", *s.MinerLevel.From)
+	}
+	if s.MinerLevel.To != nil {
+		add("miner_level <= ?", *s.MinerLevel.To)
+	}
+	if s.Balance.From != nil {
+		add("balance >= ?", *s.Balance.From)
+	}
+	if s.Balance.To != nil {
+		add("balance <= ?", *s.Balance.To)
+	}
+	if s.RegisterFrom != 0 {
+		add("register_time >= ?", s.RegisterFrom)
+	}
+	if s.RegisterTo != 0 {
+		add("register_time < ?", s.RegisterTo)
+	}
+	if s.ClickDaysAgo.From != nil {
+		add("last_click <= ?", now.Unix()-*s.ClickDaysAgo.From*secondsInDay)
+	}
+	if s.ClickDaysAgo.To != nil {
+		add("last_click > ?", now.Unix()-(*s.ClickDaysAgo.To+1)*secondsInDay)
+	}
+	if s.Source != "" {
+		add("id IN (SELECT user_id FROM income_info WHERE source = ?)", s.Source)
+	}
+	if s.Subscribed != nil {
+		operator := "IN"
+		if !*s.Subscribed {
+			operator = "NOT IN"
+		}
+		add("id " + operator + " (SELECT id FROM subs)")
+	}
+
+	return conditions, args
+}
+
+// CountSegment returns the number of the users matching the segment
+// and how many of them have blocked the bot
+func CountSegment(ctx context.Context, dataBase *sql.DB, segment *Segment, channels []int, now time.Time) (int, int, error) {
+	conditions, args := segment.conditions(channels, now)
+
+	var total, blocked int
+	err := dataBase.QueryRowContext(ctx, `
+SELECT COUNT(*), COALESCE(SUM(status = ?), 0)
+	FROM users
+WHERE `+strings.Join(conditions, " AND ")+`;`,
+		append([]interface{}{statusDeleted}, args...)...).
+		Scan(&total, &blocked)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "query segment count")
+	}
+
+	return total, blocked, nil
+}
+
+// MarkSegmentMailing marks the active users of the segment for the mailing service
+// and returns how many users will receive the mailing
+func MarkSegmentMailing(dataBase *sql.DB, segment *Segment, channels []int, now time.Time) (int64, error) {
+	conditions, args := segment.conditions(channels, now)
+
+	result, err := dataBase.Exec(`
+UPDATE users
+	SET status = ?
+WHERE status = ?
+	AND `+strings.Join(conditions, " AND ")+`;`,
+		append([]interface{}{statusMailing, statusActive}, args...)...)
+	if err != nil {
+		return 0, errors.Wrap(err, "mark segment users")
+	}
+
+	return result.RowsAffected()
+}
@@ -1,10 +1,18 @@
 package model
 
 import (
+	"context"
+	"strconv"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
 )
 
 type Situation struct {
+	ctx context.Context
+
+	// UpdateID is the telegram update the situation is created from
+	UpdateID      int
 	Message       *tgbotapi.Message
 	CallbackQuery *tgbotapi.CallbackQuery
 	BotLang       string
@@ -14,11 +22,45 @@ type Situation struct {
 	Err           error
 }
 
+// Context returns the context of the update, it is done when the deadline of the handler is reached
+func (s *Situation) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+
+	return s.ctx
+}
+
+func (s *Situation) SetContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
 type Parameters struct {
 	ReplyText string
 	Level     string
 	Partition string
 	Link      *LinkInfo
+
+	// State is the dialog state whose input is served, set by the fsm with its params
+	State       string
+	StateParams StateParams
+}
+
+// StateParams are the named parameters of the dialog state
+type StateParams map[string]string
+
+func (p StateParams) String(name string) string {
+	return p[name]
+}
+
+func (p StateParams) Int(name string) (int, error) {
+	value, err := strconv.Atoi(p[name])
+	return value, errors.Wrap(err, "parse state param "+name)
+}
+
+func (p StateParams) Int64(name string) (int64, error) {
+	value, err := strconv.ParseInt(p[name], 10, 64)
+	return value, errors.Wrap(err, "parse state param "+name)
 }
 
 type LinkInfo struct {
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	SourceSlugMaxLength = 32
+
+	WelcomeMediaPhoto = "photo"
+	WelcomeMediaVideo = "video"
+
+	// SourceLinkColumns are the columns of the source_links table in the order they are read
+	SourceLinkColumns = `hash, source, note, owner_id, created_at, expire_at, disabled, clicks, registrations,
+	welcome_text, welcome_media_type, welcome_media, bonus_hash, referral_reward`
+)
+
+var sourceSlugRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// SourceLink is an advertising link created by admins
+// together with its management information and counters
+type SourceLink struct {
+	HashKey       string
+	Source        string
+	Note          string
+	OwnerID       int64
+	CreatedAt     int64
+	ExpireAt      int64
+	Disabled      bool
+	Clicks        int
+	Registrations int
+
+	// overrides for users who came by the link
+	WelcomeText      string
+	WelcomeMediaType string
+	WelcomeMedia     string
+	BonusHash        int
+	ReferralReward   RewardsMatrix
+}
+
+// Active reports whether the link still brings attributed users
+func (l *SourceLink) Active() bool {
+	return !l.Disabled && !l.Expired()
+}
+
+func (l *SourceLink) Expired() bool {
+	return l.ExpireAt != 0 && l.ExpireAt <= time.Now().Unix()
+}
+
+func (l *SourceLink) HasWelcome() bool {
+	return l.WelcomeText != "" || l.WelcomeMedia != ""
+}
+
+// WelcomeMessage returns the custom welcome message of the source for the chat,
+// the text is sent as the admin typed it without the parse mode
+func (l *SourceLink) WelcomeMessage(chatID int64) tgbotapi.Chattable {
+	switch l.WelcomeMediaType {
+	case WelcomeMediaPhoto:
+		msg := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(l.WelcomeMedia))
+		msg.Caption = l.WelcomeText
+		return msg
+	case WelcomeMediaVideo:
+		msg := tgbotapi.NewVideo(chatID, tgbotapi.FileID(l.WelcomeMedia))
+		msg.Caption = l.WelcomeText
+		return msg
+	default:
+		return tgbotapi.NewMessage(chatID, l.WelcomeText)
+	}
+}
+
+// ValidateSourceSlug checks that the custom slug can be used as a telegram start parameter
+func ValidateSourceSlug(slug string) error {
+	if len(slug) == 0 || len(slug) > SourceSlugMaxLength {
+		return ErrInvalidSourceSlug
+	}
+
+	if !sourceSlugRegexp.MatchString(slug) || strings.Contains(slug, "new_admin") {
+		return ErrInvalidSourceSlug
+	}
+
+	return nil
+}
+
+// CreateSourceLink saves the link and its management information, an empty
+// HashKey is replaced with a random one
+func CreateSourceLink(ctx context.Context, links LinkRepo, botLink string, link *SourceLink) (string, error) {
+	if link.HashKey != "" {
+		if err := ValidateSourceSlug(link.HashKey); err != nil {
+			return "", err
+		}
+
+		exist, err := links.Get(ctx, link.HashKey)
+		if err != nil {
+			return "", errors.Wrap(err, "check slug")
+		}
+		if exist != nil {
+			return "", ErrSourceSlugTaken
+		}
+	}
+
+	referralLink := &ReferralLinkInfo{
+		HashKey: link.HashKey,
+		Source:  link.Source,
+	}
+
+	fullLink, err := EncodeLink(ctx, links, botLink, referralLink)
+	if err != nil {
+		return "", errors.Wrap(err, "encode link")
+	}
+
+	link.HashKey = referralLink.HashKey
+	link.CreatedAt = time.Now().Unix()
+
+	if err := links.SaveSource(ctx, link); err != nil {
+		return "", errors.Wrap(err, "save source link")
+	}
+
+	return fullLink, nil
+}
+
+// AdoptSourceLink creates management information for a source link
+// which was made before the source manager appeared
+func AdoptSourceLink(ctx context.Context, links LinkRepo, info *ReferralLinkInfo) (*SourceLink, error) {
+	link := &SourceLink{
+		HashKey:   info.HashKey,
+		Source:    info.Source,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	if err := links.SaveSource(ctx, link); err != nil {
+		return nil, errors.Wrap(err, "save source link")
+	}
+
+	return link, nil
+}
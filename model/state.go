@@ -0,0 +1,19 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// StateStore keeps the conversation state of the users: the levels, the ids
+// of the messages, the cursors of the settings and the handled updates. The redis and the
+// in-memory implementations are in the db package
+type StateStore interface {
+	// Get returns ErrStateNotFound when there is no such key or it is expired
+	Get(ctx context.Context, key string) (string, error)
+	// Set saves the value, the value never expires when ttl is 0
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX saves the value only when there is no such key and reports whether it was saved
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
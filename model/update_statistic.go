@@ -0,0 +1,208 @@
+package model
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	UpdateTotal    = "total"
+	UpdateMessage  = "message"
+	UpdateCallback = "callback"
+	UpdateOther    = "other"
+
+	updateCommandPrefix = "cmd:"
+	updateHourLayout    = "2006010215"
+
+	// UpdateStatisticTTL is how long the hourly buckets are kept in redis
+	UpdateStatisticTTL = 14 * 24 * time.Hour
+)
+
+// UpdateStatistic counts the updates of one bot by hour, type and command.
+// The counters are kept in memory and written to redis by Flush.
+type UpdateStatistic struct {
+	botLang string
+
+	mu      sync.Mutex
+	pending map[string]map[string]int64 // hour -> field -> count
+	total   int64
+}
+
+func NewUpdateStatistic(botLang string) *UpdateStatistic {
+	return &UpdateStatistic{
+		botLang: botLang,
+		pending: make(map[string]map[string]int64),
+	}
+}
+
+// CountUpdate counts the update of the kind and returns the number
+// of the updates handled by the bot since the start
+func (u *UpdateStatistic) CountUpdate(kind string) int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.add(UpdateTotal)
+	u.add(kind)
+	u.total++
+
+	return u.total
+}
+
+// Total returns the number of the updates handled by the bot since the start
+func (u *UpdateStatistic) Total() int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.total
+}
+
+func (u *UpdateStatistic) CountCommand(command string) {
+	if command == "" {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.add(updateCommandPrefix + command)
+}
+
+func (u *UpdateStatistic) add(field string) {
+	hour := time.Now().Format(updateHourLayout)
+	if u.pending[hour] == nil {
+		u.pending[hour] = make(map[string]int64)
+	}
+
+	u.pending[hour][field]++
+}
+
+func updateStatisticKey(botLang, hour string) string {
+	return botLang + ":updates:" + hour
+}
+
+// Flush writes the collected counters to redis in one pipeline
+func (u *UpdateStatistic) Flush(ctx context.Context) error {
+	u.mu.Lock()
+	pending := u.pending
+	u.pending = make(map[string]map[string]int64)
+	u.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	pipe := Bots[u.botLang].Rdb.Pipeline()
+	defer pipe.Close()
+
+	for hour, fields := range pending {
+		key := updateStatisticKey(u.botLang, hour)
+		for field, count := range fields {
+			pipe.HIncrBy(ctx, key, field, count)
+		}
+		pipe.Expire(ctx, key, UpdateStatisticTTL)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		// the counters are kept for the next flush, so the failed one loses nothing
+		u.restore(pending)
+		return errors.Wrap(err, "exec pipeline")
+	}
+
+	return nil
+}
+
+// restore merges the counters which weren't written back into the pending ones
+func (u *UpdateStatistic) restore(pending map[string]map[string]int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for hour, fields := range pending {
+		if u.pending[hour] == nil {
+			u.pending[hour] = make(map[string]int64)
+		}
+		for field, count := range fields {
+			u.pending[hour][field] += count
+		}
+	}
+}
+
+// StartFlushing flushes the counters of every bot with the interval until done is closed
+func StartFlushing(interval time.Duration, done <-chan struct{}, onError func(botLang string, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			FlushUpdateStatistics(onError)
+		case <-done:
+			return
+		}
+	}
+}
+
+// FlushUpdateStatistics flushes the counters of every bot
+func FlushUpdateStatistics(onError func(botLang string, err error)) {
+	for botLang, bot := range Bots {
+		if err := bot.UpdateStatistic.Flush(context.Background()); err != nil {
+			onError(botLang, err)
+		}
+	}
+}
+
+// HourStatistic is the bucket of the updates handled during the hour
+type HourStatistic struct {
+	Hour     time.Time
+	Counters map[string]int64
+}
+
+func (h *HourStatistic) Commands() map[string]int64 {
+	commands := make(map[string]int64)
+	for field, count := range h.Counters {
+		if strings.HasPrefix(field, updateCommandPrefix) {
+			commands[strings.TrimPrefix(field, updateCommandPrefix)] = count
+		}
+	}
+
+	return commands
+}
+
+// GetHourStatistics reads the last hours of the bot from the oldest to the current one
+func GetHourStatistics(ctx context.Context, botLang string, hours int, now time.Time) ([]*HourStatistic, error) {
+	pipe := Bots[botLang].Rdb.Pipeline()
+	defer pipe.Close()
+
+	current := now.Truncate(time.Hour)
+	statistics := make([]*HourStatistic, hours)
+	commands := make([]*redis.StringStringMapCmd, hours)
+	for i := range statistics {
+		hour := current.Add(-time.Duration(hours-1-i) * time.Hour)
+		statistics[i] = &HourStatistic{
+			Hour:     hour,
+			Counters: make(map[string]int64),
+		}
+		commands[i] = pipe.HGetAll(ctx, updateStatisticKey(botLang, hour.Format(updateHourLayout)))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, errors.Wrap(err, "exec pipeline")
+	}
+
+	for i, command := range commands {
+		for field, value := range command.Val() {
+			count, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.Wrap(err, "parse counter")
+			}
+			statistics[i].Counters[field] = count
+		}
+	}
+
+	return statistics, nil
+}
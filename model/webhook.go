@@ -0,0 +1,110 @@
+package model
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	webhookPath       = "/webhook/"
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+// WebhookPath returns the path on which the bot gets the updates
+func WebhookPath(botLang string) string {
+	return webhookPath + botLang
+}
+
+// webhook passes the updates of the http server to the channel of the bot
+type webhook struct {
+	bot    *tgbotapi.BotAPI
+	secret string
+
+	mu      sync.RWMutex
+	stopped bool
+	updates chan tgbotapi.Update
+}
+
+// StartPolling gets the updates from the offset by the long polling, the webhook is removed
+// because telegram does not give the updates by getUpdates while it is set
+func (b *GlobalBot) StartPolling(offset int) error {
+	if _, err := b.Bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return errors.Wrap(err, "delete webhook")
+	}
+
+	b.Chanel = b.Bot.GetUpdatesChan(tgbotapi.NewUpdate(offset))
+	return nil
+}
+
+// StartWebhook serves the updates of the bot on mux and registers the webhook in telegram.
+// The handler is added first, so the updates sent right after the registration are not lost
+func (b *GlobalBot) StartWebhook(mux *http.ServeMux, baseURL, secret string) error {
+	b.webhook = &webhook{
+		bot:     b.Bot,
+		secret:  secret,
+		updates: make(chan tgbotapi.Update, b.Bot.Buffer),
+	}
+	mux.Handle(WebhookPath(b.BotLang), b.webhook)
+	b.Chanel = b.webhook.updates
+
+	params := make(tgbotapi.Params)
+	params["url"] = strings.TrimRight(baseURL, "/") + WebhookPath(b.BotLang)
+	params["secret_token"] = secret
+
+	if _, err := b.Bot.MakeRequest("setWebhook", params); err != nil {
+		return errors.Wrap(err, "set webhook")
+	}
+
+	return nil
+}
+
+// StopUpdates stops getting the updates, the channel of the bot is closed
+// after the updates already received are passed to it
+func (b *GlobalBot) StopUpdates() {
+	if b.webhook != nil {
+		b.webhook.stop()
+		return
+	}
+
+	b.Bot.StopReceivingUpdates()
+}
+
+func (w *webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(w.secret)) != 1 {
+		http.Error(rw, "invalid secret token", http.StatusForbidden)
+		return
+	}
+
+	update, err := w.bot.HandleUpdate(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	// telegram retries the update later, it is handled after the restart
+	if w.stopped {
+		http.Error(rw, "bot is stopping", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.updates <- *update
+}
+
+func (w *webhook) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.stopped {
+		w.stopped = true
+		close(w.updates)
+	}
+}
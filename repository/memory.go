@@ -0,0 +1,477 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/Stepan1328/miner-bot/model"
+)
+
+// the memory repositories return the copies, so the changes of the
+// services reach the storage only through the methods like with the database
+
+type userMemory struct {
+	mu    sync.Mutex
+	users map[int64]*model.User
+}
+
+func newUserMemory() *userMemory {
+	return &userMemory{users: make(map[int64]*model.User)}
+}
+
+func (r *userMemory) Get(_ context.Context, id int64) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, model.ErrUserNotFound
+	}
+
+	copied := *user
+	return &copied, nil
+}
+
+func (r *userMemory) Create(_ context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; ok {
+		return model.ErrFoundTwoUsers
+	}
+
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+// update changes the user if it exists, like the update query does
+func (r *userMemory) update(id int64, change func(user *model.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[id]; ok {
+		change(user)
+	}
+
+	return nil
+}
+
+func (r *userMemory) SetLanguage(_ context.Context, id int64, lang string) error {
+	return r.update(id, func(user *model.User) {
+		user.Language = lang
+	})
+}
+
+func (r *userMemory) SetBalance(_ context.Context, id int64, balance int) error {
+	return r.update(id, func(user *model.User) {
+		user.Balance = balance
+	})
+}
+
+func (r *userMemory) TakeBonus(_ context.Context, id int64, balance int) error {
+	return r.update(id, func(user *model.User) {
+		user.Balance, user.TakeBonus = balance, true
+	})
+}
+
+func (r *userMemory) Block(_ context.Context, id int64) error {
+	return r.update(id, func(user *model.User) {
+		user.Status = statusDeleted
+	})
+}
+
+func (r *userMemory) ResetMiningToday(_ context.Context, id, lastClick int64) error {
+	return r.update(id, func(user *model.User) {
+		user.MiningToday, user.LastClick = 0, lastClick
+	})
+}
+
+func (r *userMemory) AddClick(_ context.Context, id int64, hash int, lastClick int64) error {
+	return r.update(id, func(user *model.User) {
+		user.BalanceHash += hash
+		user.MiningToday++
+		user.LastClick = lastClick
+	})
+}
+
+func (r *userMemory) ExchangeHashToBTC(_ context.Context, id int64, hash int, btc float64) error {
+	return r.update(id, func(user *model.User) {
+		user.BalanceHash -= hash
+		user.BalanceBTC += btc
+	})
+}
+
+func (r *userMemory) ExchangeBTCToCurrency(_ context.Context, id int64, btc float64, amount int) error {
+	return r.update(id, func(user *model.User) {
+		user.BalanceBTC -= btc
+		user.Balance += amount
+	})
+}
+
+func (r *userMemory) UpgradeMiner(_ context.Context, id int64, cost int) error {
+	return r.update(id, func(user *model.User) {
+		user.BalanceHash -= cost
+		user.MinerLevel++
+	})
+}
+
+func (r *userMemory) AddReferralReward(_ context.Context, id int64, reward int, allReferrals string) error {
+	return r.update(id, func(user *model.User) {
+		user.Balance += reward
+		user.AllReferrals = allReferrals
+	})
+}
+
+func (r *userMemory) TopByBalance(_ context.Context, limit int) ([]*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := make([]*model.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, &model.User{ID: user.ID, Balance: user.Balance})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Balance == users[j].Balance {
+			return users[i].ID < users[j].ID
+		}
+		return users[i].Balance > users[j].Balance
+	})
+
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	return users, nil
+}
+
+func (r *userMemory) Count(_ context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.users), nil
+}
+
+func (r *userMemory) CountBlocked(_ context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var blocked int
+	for _, user := range r.users {
+		if user.Status == statusDeleted {
+			blocked++
+		}
+	}
+
+	return blocked, nil
+}
+
+type linkMemory struct {
+	mu      sync.Mutex
+	links   map[string]model.ReferralLinkInfo
+	sources map[string]model.SourceLink
+}
+
+func newLinkMemory() *linkMemory {
+	return &linkMemory{
+		links:   make(map[string]model.ReferralLinkInfo),
+		sources: make(map[string]model.SourceLink),
+	}
+}
+
+func (r *linkMemory) Save(_ context.Context, link *model.ReferralLinkInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.links[link.HashKey]; ok {
+		return model.ErrSourceSlugTaken
+	}
+
+	r.links[link.HashKey] = *link
+	return nil
+}
+
+func (r *linkMemory) Get(_ context.Context, hashKey string) (*model.ReferralLinkInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link, ok := r.links[hashKey]
+	if !ok {
+		return nil, nil
+	}
+
+	return &link, nil
+}
+
+func (r *linkMemory) SaveSource(_ context.Context, link *model.SourceLink) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sources[link.HashKey]; ok {
+		return model.ErrSourceSlugTaken
+	}
+
+	r.sources[link.HashKey] = copySourceLink(link)
+	return nil
+}
+
+func (r *linkMemory) GetSource(_ context.Context, hashKey string) (*model.SourceLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link, ok := r.sources[hashKey]
+	if !ok {
+		return nil, nil
+	}
+
+	copied := copySourceLink(&link)
+	return &copied, nil
+}
+
+func (r *linkMemory) GetSources(_ context.Context, offset, limit int) ([]*model.SourceLink, error) {
+	links := r.sortedSources(func(*model.SourceLink) bool { return true })
+	if offset >= len(links) {
+		return nil, nil
+	}
+
+	links = links[offset:]
+	if len(links) > limit {
+		links = links[:limit]
+	}
+
+	return links, nil
+}
+
+func (r *linkMemory) CountSources(_ context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.sources), nil
+}
+
+func (r *linkMemory) GetOwnerSources(_ context.Context, ownerID int64) ([]*model.SourceLink, error) {
+	return r.sortedSources(func(link *model.SourceLink) bool {
+		return link.OwnerID == ownerID
+	}), nil
+}
+
+// sortedSources returns the copies of the matching source links from newest to oldest
+func (r *linkMemory) sortedSources(match func(link *model.SourceLink) bool) []*model.SourceLink {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var links []*model.SourceLink
+	for _, link := range r.sources {
+		if !match(&link) {
+			continue
+		}
+
+		copied := copySourceLink(&link)
+		links = append(links, &copied)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].CreatedAt == links[j].CreatedAt {
+			return links[i].HashKey < links[j].HashKey
+		}
+		return links[i].CreatedAt > links[j].CreatedAt
+	})
+
+	return links
+}
+
+func (r *linkMemory) UpdateSource(_ context.Context, link *model.SourceLink) error {
+	return r.updateSource(link.HashKey, func(saved *model.SourceLink) {
+		updated := copySourceLink(link)
+		updated.CreatedAt, updated.Clicks, updated.Registrations = saved.CreatedAt, saved.Clicks, saved.Registrations
+		*saved = updated
+	})
+}
+
+func (r *linkMemory) IncreaseClicks(_ context.Context, hashKey string) error {
+	return r.updateSource(hashKey, func(link *model.SourceLink) {
+		link.Clicks++
+	})
+}
+
+func (r *linkMemory) IncreaseRegistrations(_ context.Context, hashKey string) error {
+	return r.updateSource(hashKey, func(link *model.SourceLink) {
+		link.Registrations++
+	})
+}
+
+// updateSource changes the source link if it exists, like the update query does
+func (r *linkMemory) updateSource(hashKey string, change func(link *model.SourceLink)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link, ok := r.sources[hashKey]
+	if !ok {
+		return nil
+	}
+
+	change(&link)
+	r.sources[hashKey] = link
+	return nil
+}
+
+// copySourceLink copies the gaps of the referral reward too, they are kept by the pointers
+func copySourceLink(link *model.SourceLink) model.SourceLink {
+	copied := *link
+	if link.ReferralReward == nil {
+		return copied
+	}
+
+	copied.ReferralReward = make(model.RewardsMatrix, 0, len(link.ReferralReward))
+	for _, lvl := range link.ReferralReward {
+		copiedLvl := make(model.RewardsLvl, 0, len(lvl))
+		for _, gap := range lvl {
+			copiedGap := *gap
+			copiedLvl = append(copiedLvl, &copiedGap)
+		}
+		copied.ReferralReward = append(copied.ReferralReward, copiedLvl)
+	}
+
+	return copied
+}
+
+type topMemory struct {
+	mu   sync.Mutex
+	tops map[int]model.Top
+}
+
+func newTopMemory() *topMemory {
+	return &topMemory{tops: make(map[int]model.Top)}
+}
+
+func (r *topMemory) Create(_ context.Context, place int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tops[place] = model.Top{Top: place}
+	return nil
+}
+
+func (r *topMemory) Get(_ context.Context, place int) (*model.Top, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	top, ok := r.tops[place]
+	if !ok {
+		return &model.Top{Top: place}, nil
+	}
+
+	return &top, nil
+}
+
+func (r *topMemory) GetAll(_ context.Context) ([]*model.Top, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var tops []*model.Top
+	for _, top := range r.tops {
+		copied := top
+		tops = append(tops, &copied)
+	}
+	sort.Slice(tops, func(i, j int) bool {
+		return tops[i].Top < tops[j].Top
+	})
+
+	return tops, nil
+}
+
+func (r *topMemory) Update(_ context.Context, top *model.Top) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tops[top.Top]; ok {
+		r.tops[top.Top] = *top
+	}
+
+	return nil
+}
+
+type subsMemory struct {
+	mu   sync.Mutex
+	subs map[int64]bool
+}
+
+func newSubsMemory() *subsMemory {
+	return &subsMemory{subs: make(map[int64]bool)}
+}
+
+func (r *subsMemory) Add(_ context.Context, userID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subs[userID] = true
+	return nil
+}
+
+func (r *subsMemory) Count(_ context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.subs), nil
+}
+
+type incomeMemory struct {
+	mu    sync.Mutex
+	infos map[int64]model.IncomeInfo
+	users *userMemory // for the clicks of the users came by the source
+}
+
+func newIncomeMemory(users *userMemory) *incomeMemory {
+	return &incomeMemory{
+		infos: make(map[int64]model.IncomeInfo),
+		users: users,
+	}
+}
+
+func (r *incomeMemory) Save(_ context.Context, info *model.IncomeInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the first source is kept like the first row is read from the database
+	if _, ok := r.infos[info.UserID]; !ok {
+		r.infos[info.UserID] = *info
+	}
+
+	return nil
+}
+
+func (r *incomeMemory) Get(_ context.Context, userID int64) (*model.IncomeInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.infos[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &info, nil
+}
+
+func (r *incomeMemory) CountActivated(ctx context.Context, sourceHash string) (int, error) {
+	r.mu.Lock()
+	var userIDs []int64
+	for _, info := range r.infos {
+		if info.SourceHash == sourceHash {
+			userIDs = append(userIDs, info.UserID)
+		}
+	}
+	r.mu.Unlock()
+
+	var activated int
+	for _, id := range userIDs {
+		user, err := r.users.Get(ctx, id)
+		if err == nil && user.LastClick > 0 {
+			activated++
+		}
+	}
+
+	return activated, nil
+}
@@ -0,0 +1,34 @@
+// Package repository implements the storages of model.Repositories
+// on the sql database for the bots and in memory for the tests
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/Stepan1328/miner-bot/model"
+)
+
+const statusDeleted = "deleted"
+
+// NewSQL returns the repositories working with the mysql or sqlite database of the bot
+func NewSQL(dataBase *sql.DB) *model.Repositories {
+	return &model.Repositories{
+		Users:  &userSQL{db: dataBase},
+		Links:  &linkSQL{db: dataBase},
+		Top:    &topSQL{db: dataBase},
+		Subs:   &subsSQL{db: dataBase},
+		Income: &incomeSQL{db: dataBase},
+	}
+}
+
+// NewMemory returns the empty repositories keeping everything in memory
+func NewMemory() *model.Repositories {
+	users := newUserMemory()
+	return &model.Repositories{
+		Users:  users,
+		Links:  newLinkMemory(),
+		Top:    newTopMemory(),
+		Subs:   newSubsMemory(),
+		Income: newIncomeMemory(users),
+	}
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/pkg/errors"
+)
+
+func (r *linkSQL) SaveSource(ctx context.Context, link *model.SourceLink) error {
+	referralReward, err := marshalReferralReward(link.ReferralReward)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+INSERT INTO source_links
+	(`+model.SourceLinkColumns+`)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		link.HashKey,
+		link.Source,
+		link.Note,
+		link.OwnerID,
+		link.CreatedAt,
+		link.ExpireAt,
+		link.Disabled,
+		link.Clicks,
+		link.Registrations,
+		link.WelcomeText,
+		link.WelcomeMediaType,
+		link.WelcomeMedia,
+		link.BonusHash,
+		referralReward)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+func (r *linkSQL) GetSource(ctx context.Context, hashKey string) (*model.SourceLink, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT `+model.SourceLinkColumns+`
+	FROM source_links
+WHERE hash = ?;`,
+		hashKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	links, err := readSourceLinks(rows)
+	if err != nil || len(links) == 0 {
+		return nil, err
+	}
+
+	return links[0], nil
+}
+
+func (r *linkSQL) GetSources(ctx context.Context, offset, limit int) ([]*model.SourceLink, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT `+model.SourceLinkColumns+`
+	FROM source_links
+ORDER BY created_at DESC
+	LIMIT ? OFFSET ?;`,
+		limit,
+		offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	return readSourceLinks(rows)
+}
+
+func (r *linkSQL) CountSources(ctx context.Context) (int, error) {
+	return count(ctx, r.db, `SELECT COUNT(*) FROM source_links;`)
+}
+
+func (r *linkSQL) GetOwnerSources(ctx context.Context, ownerID int64) ([]*model.SourceLink, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT `+model.SourceLinkColumns+`
+	FROM source_links
+WHERE owner_id = ?
+ORDER BY created_at DESC;`,
+		ownerID)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+
+	return readSourceLinks(rows)
+}
+
+func (r *linkSQL) UpdateSource(ctx context.Context, link *model.SourceLink) error {
+	referralReward, err := marshalReferralReward(link.ReferralReward)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+UPDATE source_links
+	SET source = ?,
+	    note = ?,
+	    owner_id = ?,
+	    expire_at = ?,
+	    disabled = ?,
+	    welcome_text = ?,
+	    welcome_media_type = ?,
+	    welcome_media = ?,
+	    bonus_hash = ?,
+	    referral_reward = ?
+WHERE hash = ?;`,
+		link.Source,
+		link.Note,
+		link.OwnerID,
+		link.ExpireAt,
+		link.Disabled,
+		link.WelcomeText,
+		link.WelcomeMediaType,
+		link.WelcomeMedia,
+		link.BonusHash,
+		referralReward,
+		link.HashKey)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+func (r *linkSQL) IncreaseClicks(ctx context.Context, hashKey string) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE source_links
+	SET clicks = clicks + 1
+WHERE hash = ?;`,
+		hashKey)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+func (r *linkSQL) IncreaseRegistrations(ctx context.Context, hashKey string) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE source_links
+	SET registrations = registrations + 1
+WHERE hash = ?;`,
+		hashKey)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+func readSourceLinks(rows *sql.Rows) ([]*model.SourceLink, error) {
+	defer rows.Close()
+
+	var links []*model.SourceLink
+
+	for rows.Next() {
+		link := &model.SourceLink{}
+		var referralReward string
+
+		if err := rows.Scan(
+			&link.HashKey,
+			&link.Source,
+			&link.Note,
+			&link.OwnerID,
+			&link.CreatedAt,
+			&link.ExpireAt,
+			&link.Disabled,
+			&link.Clicks,
+			&link.Registrations,
+			&link.WelcomeText,
+			&link.WelcomeMediaType,
+			&link.WelcomeMedia,
+			&link.BonusHash,
+			&referralReward); err != nil {
+			return nil, errors.Wrap(err, "failed scan row")
+		}
+
+		if referralReward != "" {
+			if err := json.Unmarshal([]byte(referralReward), &link.ReferralReward); err != nil {
+				return nil, errors.Wrap(err, "failed unmarshal referral reward")
+			}
+		}
+
+		links = append(links, link)
+	}
+
+	return links, errors.Wrap(rows.Err(), "iterate rows")
+}
+
+func marshalReferralReward(reward model.RewardsMatrix) (string, error) {
+	if reward == nil {
+		return "", nil
+	}
+
+	data, err := json.Marshal(reward)
+	if err != nil {
+		return "", errors.Wrap(err, "failed marshal referral reward")
+	}
+
+	return string(data), nil
+}
+
+// CountActivated returns the number of users registered through the
+// source link who made at least one click
+func (r *incomeSQL) CountActivated(ctx context.Context, sourceHash string) (int, error) {
+	return count(ctx, r.db, `
+SELECT COUNT(*)
+	FROM income_info
+	JOIN users ON users.id = income_info.user_id
+WHERE income_info.source_hash = ? AND users.last_click > 0;`,
+		sourceHash)
+}
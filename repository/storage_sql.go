@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/pkg/errors"
+)
+
+type linkSQL struct {
+	db *sql.DB
+}
+
+func (r *linkSQL) Save(ctx context.Context, link *model.ReferralLinkInfo) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO links VALUES (?, ?, ?);",
+		link.HashKey,
+		link.ReferralID,
+		link.Source)
+	if err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+func (r *linkSQL) Get(ctx context.Context, hashKey string) (*model.ReferralLinkInfo, error) {
+	link := &model.ReferralLinkInfo{}
+	err := r.db.QueryRowContext(ctx, "SELECT * FROM links WHERE hash = ?;",
+		hashKey).
+		Scan(&link.HashKey, &link.ReferralID, &link.Source)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "get link")
+	}
+
+	return link, nil
+}
+
+type topSQL struct {
+	db *sql.DB
+}
+
+func (r *topSQL) Create(ctx context.Context, place int) error {
+	if _, err := r.db.ExecContext(ctx, `INSERT INTO top VALUES (?, ?, ?, ?);`, place, 0, 0, 0); err != nil {
+		return errors.Wrap(err, "create top")
+	}
+
+	return nil
+}
+
+func (r *topSQL) Get(ctx context.Context, place int) (*model.Top, error) {
+	top := &model.Top{
+		Top: place,
+	}
+
+	err := r.db.QueryRowContext(ctx, `SELECT user_id, time_on_top, balance FROM top WHERE top = ?;`,
+		place).
+		Scan(&top.UserID, &top.TimeOnTop, &top.Balance)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "get top")
+	}
+
+	return top, nil
+}
+
+func (r *topSQL) GetAll(ctx context.Context) ([]*model.Top, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT * FROM top ORDER BY top;`)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+	defer rows.Close()
+
+	var tops []*model.Top
+	for rows.Next() {
+		top := &model.Top{}
+		if err = rows.Scan(&top.Top, &top.UserID, &top.TimeOnTop, &top.Balance); err != nil {
+			return nil, errors.Wrap(err, model.ErrScanSqlRow.Error())
+		}
+
+		tops = append(tops, top)
+	}
+
+	return tops, nil
+}
+
+func (r *topSQL) Update(ctx context.Context, top *model.Top) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE top SET user_id = ?, time_on_top = ?, balance = ? WHERE top = ?;`,
+		top.UserID,
+		top.TimeOnTop,
+		top.Balance,
+		top.Top)
+	if err != nil {
+		return errors.Wrap(err, "update top")
+	}
+
+	return nil
+}
+
+type subsSQL struct {
+	db *sql.DB
+}
+
+func (r *subsSQL) Add(ctx context.Context, userID int64) error {
+	exist, err := count(ctx, r.db, `SELECT COUNT(*) FROM subs WHERE id = ?;`, userID)
+	if err != nil || exist != 0 {
+		return err
+	}
+
+	if _, err = r.db.ExecContext(ctx, `INSERT INTO subs VALUES(?);`, userID); err != nil {
+		return errors.Wrap(err, "insert sub")
+	}
+
+	return nil
+}
+
+func (r *subsSQL) Count(ctx context.Context) (int, error) {
+	return count(ctx, r.db, `SELECT COUNT(DISTINCT id) FROM subs;`)
+}
+
+type incomeSQL struct {
+	db *sql.DB
+}
+
+func (r *incomeSQL) Save(ctx context.Context, info *model.IncomeInfo) error {
+	_, err := r.db.ExecContext(ctx, `
+INSERT INTO
+	income_info(user_id, source, source_hash)
+VALUES(?, ?, ?);`,
+		info.UserID,
+		info.Source,
+		info.SourceHash)
+	if err != nil {
+		return errors.Wrap(err, "failed insert income info")
+	}
+
+	return nil
+}
+
+func (r *incomeSQL) Get(ctx context.Context, userID int64) (*model.IncomeInfo, error) {
+	info := &model.IncomeInfo{UserID: userID}
+	err := r.db.QueryRowContext(ctx, `
+SELECT source, source_hash
+	FROM income_info
+WHERE user_id = ?;`,
+		userID).
+		Scan(&info.Source, &info.SourceHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed query row")
+	}
+
+	return info, nil
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/pkg/errors"
+)
+
+type userSQL struct {
+	db *sql.DB
+}
+
+func (r *userSQL) Get(ctx context.Context, id int64) (*model.User, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT * FROM users
+	WHERE id = ?;`,
+		id)
+	if err != nil {
+		return nil, errors.Wrap(err, "get user")
+	}
+
+	users, err := readUsers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(users) {
+	case 0:
+		return nil, model.ErrUserNotFound
+	case 1:
+		return users[0], nil
+	default:
+		return nil, model.ErrFoundTwoUsers
+	}
+}
+
+func readUsers(rows *sql.Rows) ([]*model.User, error) {
+	defer rows.Close()
+
+	var users []*model.User
+
+	for rows.Next() {
+		user := &model.User{}
+
+		if err := rows.Scan(&user.ID,
+			&user.Balance,
+			&user.BalanceHash,
+			&user.BalanceBTC,
+			&user.MiningToday,
+			&user.LastClick,
+			&user.MinerLevel,
+			&user.FatherID,
+			&user.AllReferrals,
+			&user.AdvertChannel,
+			&user.TakeBonus,
+			&user.Language,
+			&user.RegisterTime,
+			&user.MinWithdrawal,
+			&user.FirstWithdrawal,
+			&user.Status); err != nil {
+			return nil, errors.Wrap(err, model.ErrScanSqlRow.Error())
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (r *userSQL) Create(ctx context.Context, user *model.User) error {
+	_, err := r.db.ExecContext(ctx, `
+INSERT INTO users
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		user.ID,
+		user.Balance,
+		user.BalanceHash,
+		user.BalanceBTC,
+		user.MiningToday,
+		user.LastClick,
+		user.MinerLevel,
+		user.FatherID,
+		user.AllReferrals,
+		user.AdvertChannel,
+		user.TakeBonus,
+		user.Language,
+		user.RegisterTime,
+		user.MinWithdrawal,
+		user.FirstWithdrawal,
+		user.Status)
+	if err != nil {
+		return errors.Wrap(err, "insert user")
+	}
+
+	return nil
+}
+
+func (r *userSQL) SetLanguage(ctx context.Context, id int64, lang string) error {
+	return r.exec(ctx, "UPDATE users SET lang = ? WHERE id = ?;", lang, id)
+}
+
+func (r *userSQL) SetBalance(ctx context.Context, id int64, balance int) error {
+	return r.exec(ctx, `
+UPDATE users
+	SET balance = ?
+WHERE id = ?;`,
+		balance,
+		id)
+}
+
+func (r *userSQL) TakeBonus(ctx context.Context, id int64, balance int) error {
+	return r.exec(ctx, `
+UPDATE users
+	SET balance = ?,
+	    take_bonus = ?
+WHERE id = ?;`,
+		balance,
+		true,
+		id)
+}
+
+func (r *userSQL) Block(ctx context.Context, id int64) error {
+	return r.exec(ctx, `
+UPDATE users
+	SET status = ?
+WHERE id = ?;`,
+		statusDeleted,
+		id)
+}
+
+func (r *userSQL) ResetMiningToday(ctx context.Context, id, lastClick int64) error {
+	return r.exec(ctx, `
+UPDATE users SET
+	mining_today = 0,
+	last_click = ?
+WHERE id = ?;`,
+		lastClick,
+		id)
+}
+
+func (r *userSQL) AddClick(ctx context.Context, id int64, hash int, lastClick int64) error {
+	return r.exec(ctx, `
+UPDATE users
+	SET balance_hash = balance_hash + ?,
+	    mining_today = mining_today + 1,
+	    last_click = ?
+WHERE id = ?;`,
+		hash,
+		lastClick,
+		id)
+}
+
+func (r *userSQL) ExchangeHashToBTC(ctx context.Context, id int64, hash int, btc float64) error {
+	return r.exec(ctx, `
+UPDATE users
+	SET balance_hash = balance_hash - ?,
+	    balance_btc = balance_btc + ?
+WHERE id = ?;`,
+		hash,
+		btc,
+		id)
+}
+
+func (r *userSQL) ExchangeBTCToCurrency(ctx context.Context, id int64, btc float64, amount int) error {
+	return r.exec(ctx, `
+UPDATE users
+	SET balance_btc = balance_btc - ?,
+	    balance = balance + ?
+WHERE id = ?;`,
+		btc,
+		amount,
+		id)
+}
+
+func (r *userSQL) UpgradeMiner(ctx context.Context, id int64, cost int) error {
+	return r.exec(ctx, `
+UPDATE users
+	SET balance_hash = balance_hash - ?,
+	    miner_level = miner_level + 1
+WHERE id = ?;`,
+		cost,
+		id)
+}
+
+func (r *userSQL) AddReferralReward(ctx context.Context, id int64, reward int, allReferrals string) error {
+	return r.exec(ctx, `
+UPDATE users SET
+	balance = balance + ?,
+	all_referrals = ?
+WHERE id = ?;`,
+		reward,
+		allReferrals,
+		id)
+}
+
+func (r *userSQL) TopByBalance(ctx context.Context, limit int) ([]*model.User, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT id, balance FROM users ORDER BY balance DESC LIMIT ?;`,
+		limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "execute query")
+	}
+	defer rows.Close()
+
+	var users []*model.User
+	for rows.Next() {
+		user := &model.User{}
+		if err = rows.Scan(&user.ID, &user.Balance); err != nil {
+			return nil, model.ErrScanSqlRow
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (r *userSQL) Count(ctx context.Context) (int, error) {
+	return count(ctx, r.db, `SELECT COUNT(*) FROM users;`)
+}
+
+func (r *userSQL) CountBlocked(ctx context.Context) (int, error) {
+	return count(ctx, r.db, `SELECT COUNT(DISTINCT id) FROM users WHERE status = ?;`, statusDeleted)
+}
+
+func (r *userSQL) exec(ctx context.Context, query string, args ...interface{}) error {
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return errors.Wrap(err, "make exec in database")
+	}
+
+	return nil
+}
+
+func count(ctx context.Context, dataBase *sql.DB, query string, args ...interface{}) (int, error) {
+	var result int
+	if err := dataBase.QueryRowContext(ctx, query, args...).Scan(&result); err != nil {
+		return 0, errors.Wrap(err, "count rows")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,169 @@
+// Package route matches the data of the callbacks with the declared routes and parses
+// their typed parameters, like "/withdrawal_money/{amount:int}". The parameters of the
+// old buttons are separated with "?" and "&", such data is matched by the same routes.
+package route
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	separator     = "/"
+	oldSeparators = "?&"
+
+	kindString = "string"
+	kindInt    = "int"
+	kindInt64  = "int64"
+)
+
+// Params are the parsed parameters of the route, they are checked before the handler
+// so the getters return the zero value only for the names which are not in the route
+type Params map[string]interface{}
+
+func (p Params) String(name string) string {
+	value, _ := p[name].(string)
+	return value
+}
+
+func (p Params) Int(name string) int {
+	value, _ := p[name].(int)
+	return value
+}
+
+func (p Params) Int64(name string) int64 {
+	value, _ := p[name].(int64)
+	return value
+}
+
+// segment is the part of the pattern between the separators: the literal or the {name:kind} parameter
+type segment struct {
+	literal string
+	name    string
+	kind    string
+}
+
+func (s segment) isParam() bool {
+	return s.name != ""
+}
+
+func (s segment) parse(value string) (interface{}, error) {
+	switch s.kind {
+	case kindInt:
+		parsed, err := strconv.Atoi(value)
+		return parsed, errors.Wrap(err, "parse param "+s.name)
+	case kindInt64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		return parsed, errors.Wrap(err, "parse param "+s.name)
+	}
+
+	if value == "" {
+		return nil, errors.New("empty param " + s.name)
+	}
+	return value, nil
+}
+
+type route struct {
+	pattern string
+	// prefix are the literals before the first parameter, the data starting with them belongs to the route
+	prefix   []string
+	segments []segment
+	handler  Handler
+}
+
+func parsePattern(pattern string) (*route, error) {
+	r := &route{pattern: pattern}
+	names := make(map[string]bool)
+
+	for _, part := range strings.Split(pattern, separator) {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			if strings.ContainsAny(part, oldSeparators+"{}") {
+				return nil, errors.Errorf("invalid literal %q", part)
+			}
+			r.segments = append(r.segments, segment{literal: part})
+			continue
+		}
+
+		param := segment{kind: kindString}
+		param.name = strings.TrimSuffix(strings.TrimPrefix(part, "{"), "}")
+		if i := strings.Index(param.name, ":"); i != -1 {
+			param.name, param.kind = param.name[:i], param.name[i+1:]
+		}
+
+		switch {
+		case param.name == "":
+			return nil, errors.Errorf("param %q without name", part)
+		case names[param.name]:
+			return nil, errors.Errorf("param %q is declared twice", param.name)
+		case param.kind != kindString && param.kind != kindInt && param.kind != kindInt64:
+			return nil, errors.Errorf("param %q has unknown kind %q", param.name, param.kind)
+		}
+
+		names[param.name] = true
+		r.segments = append(r.segments, param)
+	}
+
+	for _, s := range r.segments {
+		if s.isParam() {
+			break
+		}
+		r.prefix = append(r.prefix, s.literal)
+	}
+	if strings.Join(r.prefix, "") == "" {
+		return nil, errors.New("pattern starts with param")
+	}
+
+	return r, nil
+}
+
+// command is the prefix of the route, it is the command of the situation in the logs and the metrics
+func (r *route) command() string {
+	return strings.Join(r.prefix, separator)
+}
+
+func (r *route) owns(parts []string) bool {
+	if len(parts) < len(r.prefix) {
+		return false
+	}
+
+	for i, literal := range r.prefix {
+		if parts[i] != literal {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *route) match(parts []string) (Params, error) {
+	if len(parts) != len(r.segments) {
+		return nil, errors.Errorf("route %s takes %d parts, got %d", r.pattern, len(r.segments), len(parts))
+	}
+
+	params := make(Params)
+	for i, s := range r.segments {
+		if !s.isParam() {
+			if parts[i] != s.literal {
+				return nil, errors.Errorf("route %s expects %q, got %q", r.pattern, s.literal, parts[i])
+			}
+			continue
+		}
+
+		value, err := s.parse(parts[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "route "+r.pattern)
+		}
+		params[s.name] = value
+	}
+
+	return params, nil
+}
+
+// oldSeparatorsReplacer turns the data of the old buttons into the data of the routes
+var oldSeparatorsReplacer = strings.NewReplacer("?", separator, "&", separator)
+
+func splitData(data string) []string {
+	return strings.Split(oldSeparatorsReplacer.Replace(data), separator)
+}
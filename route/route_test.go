@@ -0,0 +1,208 @@
+package route
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/Stepan1328/miner-bot/model"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		command string
+		wantErr bool
+	}{
+		{pattern: "/language/{lang}", command: "/language"},
+		{pattern: "admin/turn/{kind}/{channel:int}", command: "admin/turn"},
+		{pattern: "admin/mailing_report/{report:int64}", command: "admin/mailing_report"},
+		{pattern: "admin/send_menu", command: "admin/send_menu"},
+		{pattern: "{lang}/language", wantErr: true},
+		{pattern: "/language/{}", wantErr: true},
+		{pattern: "/language/{:int}", wantErr: true},
+		{pattern: "/language/{lang:float}", wantErr: true},
+		{pattern: "/language/{lang}/{lang}", wantErr: true},
+		{pattern: "/language?{lang}", wantErr: true},
+		{pattern: "/click/inc&{value:int}", wantErr: true},
+		{pattern: "/language/{lang", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			r, err := parsePattern(test.pattern)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("parsePattern() = %+v, want error", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePattern() error = %v", err)
+			}
+			if r.command() != test.command {
+				t.Errorf("command() = %q, want %q", r.command(), test.command)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		data    string
+		want    Params
+		wantErr bool
+	}{
+		{
+			name:    "string param",
+			pattern: "/language/{lang}",
+			data:    "/language/en",
+			want:    Params{"lang": "en"},
+		},
+		{
+			name:    "int params",
+			pattern: "admin/change_click_amount/{operation}/{value:int}",
+			data:    "admin/change_click_amount/inc/5",
+			want:    Params{"operation": "inc", "value": 5},
+		},
+		{
+			name:    "int64 param",
+			pattern: "admin/mailing_report/{report:int64}",
+			data:    "admin/mailing_report/9000000000",
+			want:    Params{"report": int64(9000000000)},
+		},
+		{
+			name:    "old question marks",
+			pattern: "/mailing_click/{report:int64}/{variant:int}/{row:int}/{column:int}",
+			data:    "/mailing_click?12?1?0?2",
+			want:    Params{"report": int64(12), "variant": 1, "row": 0, "column": 2},
+		},
+		{
+			name:    "old ampersand",
+			pattern: "admin/change_click_amount/{operation}/{value:int}",
+			data:    "admin/change_click_amount?dec&5",
+			want:    Params{"operation": "dec", "value": 5},
+		},
+		{
+			name:    "not a number",
+			pattern: "/withdrawal_money/{amount:int}",
+			data:    "/withdrawal_money/ten",
+			wantErr: true,
+		},
+		{
+			name:    "empty string param",
+			pattern: "/language/{lang}",
+			data:    "/language/",
+			wantErr: true,
+		},
+		{
+			name:    "too few parts",
+			pattern: "admin/turn/{kind}/{channel:int}",
+			data:    "admin/turn/photo",
+			wantErr: true,
+		},
+		{
+			name:    "too many parts",
+			pattern: "/language/{lang}",
+			data:    "/language/en/de",
+			wantErr: true,
+		},
+		{
+			name:    "other literal",
+			pattern: "admin/turn/{kind}/{channel:int}",
+			data:    "admin/burn/photo/1",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := parsePattern(test.pattern)
+			if err != nil {
+				t.Fatalf("parsePattern() error = %v", err)
+			}
+
+			params, err := r.match(splitData(test.data))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("match() = %v, want error", params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("match() error = %v", err)
+			}
+			if !reflect.DeepEqual(params, test.want) {
+				t.Errorf("match() = %v, want %v", params, test.want)
+			}
+		})
+	}
+}
+
+func TestRouterHandler(t *testing.T) {
+	var (
+		served  string
+		invalid bool
+	)
+
+	router := NewRouter(func(s *model.Situation, err error) error {
+		invalid = true
+		return nil
+	})
+	router.Handle("admin/change_click_amount/{operation}", func(s *model.Situation, params Params) error {
+		served = params.String("operation")
+		return nil
+	})
+	router.Handle("admin/change_click_amount/{operation}/{value:int}", func(s *model.Situation, params Params) error {
+		served = params.String("operation") + "/" + strconv.Itoa(params.Int("value"))
+		return nil
+	})
+
+	tests := []struct {
+		data    string
+		command string
+		served  string
+		invalid bool
+		notOwn  bool
+	}{
+		{data: "admin/change_click_amount/set_hash", command: "admin/change_click_amount", served: "set_hash"},
+		{data: "admin/change_click_amount?set_hash", command: "admin/change_click_amount", served: "set_hash"},
+		{data: "admin/change_click_amount/inc/5", command: "admin/change_click_amount", served: "inc/5"},
+		{data: "admin/change_click_amount?inc&5", command: "admin/change_click_amount", served: "inc/5"},
+		{data: "admin/change_click_amount/inc/ten", command: "admin/change_click_amount", invalid: true},
+		{data: "admin/change_click_amount", command: "admin/change_click_amount", invalid: true},
+		{data: "admin/change_upgrade_amount/inc/5", notOwn: true},
+		{data: "/change_click_amount/inc/5", notOwn: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.data, func(t *testing.T) {
+			served, invalid = "", false
+			s := &model.Situation{CallbackQuery: &tgbotapi.CallbackQuery{Data: test.data}}
+
+			handler := router.Handler(s)
+			if test.notOwn {
+				if handler != nil {
+					t.Fatal("Handler() is not nil for the data of no route")
+				}
+				return
+			}
+			if handler == nil {
+				t.Fatal("Handler() = nil")
+			}
+			if err := handler(s); err != nil {
+				t.Fatalf("handler() error = %v", err)
+			}
+
+			if s.Command != test.command {
+				t.Errorf("Command = %q, want %q", s.Command, test.command)
+			}
+			if served != test.served || invalid != test.invalid {
+				t.Errorf("served %q invalid %v, want %q %v", served, invalid, test.served, test.invalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package route
+
+import (
+	"github.com/Stepan1328/miner-bot/model"
+)
+
+// Handler is the handler of the callback with the parameters of its route
+type Handler func(s *model.Situation, params Params) error
+
+// Router serves the callbacks by the declared routes
+type Router struct {
+	routes []*route
+
+	// invalid answers the callback whose data doesn't fit the routes of its command,
+	// usually the button of the old message or the data changed by the client
+	invalid func(s *model.Situation, err error) error
+}
+
+func NewRouter(invalid func(s *model.Situation, err error) error) *Router {
+	return &Router{
+		invalid: invalid,
+	}
+}
+
+// Handle adds the route, the routes are declared once on the start so the mistakes panic
+func (r *Router) Handle(pattern string, handler Handler) {
+	route, err := parsePattern(pattern)
+	if err != nil {
+		panic("route: " + pattern + ": " + err.Error())
+	}
+
+	for _, exist := range r.routes {
+		if exist.pattern == pattern {
+			panic("route: " + pattern + " is declared twice")
+		}
+	}
+
+	route.handler = handler
+	r.routes = append(r.routes, route)
+}
+
+// Handler returns the handler of the callback of the situation, nil is returned
+// when no route starts like its data. The data which starts like the routes but
+// doesn't fit any of them is served by the invalid handler
+func (r *Router) Handler(s *model.Situation) model.Handler {
+	if s.CallbackQuery == nil {
+		return nil
+	}
+
+	var (
+		parts = splitData(s.CallbackQuery.Data)
+		owner *route
+		err   error
+	)
+	for _, route := range r.routes {
+		if !route.owns(parts) {
+			continue
+		}
+
+		var params Params
+		if params, err = route.match(parts); err == nil {
+			s.Command = route.command()
+			return bind(route.handler, params)
+		}
+		owner = route
+	}
+
+	if owner == nil {
+		return nil
+	}
+
+	s.Command = owner.command()
+	return func(s *model.Situation) error {
+		return r.invalid(s, err)
+	}
+}
+
+func bind(handler Handler, params Params) model.Handler {
+	return func(s *model.Situation) error {
+		return handler(s, params)
+	}
+}
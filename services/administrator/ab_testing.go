@@ -0,0 +1,399 @@
+package administrator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+// abTestDraft is the A/B test the admin is preparing for the mailing of the channel
+type abTestDraft struct {
+	Channel  int
+	Variants []*model.Post
+	Percents []int // nil splits the whole audience equally
+}
+
+var (
+	abTestDrafts   = make(map[int64]*abTestDraft)
+	abTestDraftsMu sync.Mutex
+)
+
+// editABTestDraft changes the draft under the lock
+func editABTestDraft(userID int64, edit func(draft *abTestDraft)) {
+	abTestDraftsMu.Lock()
+	defer abTestDraftsMu.Unlock()
+
+	draft, ok := abTestDrafts[userID]
+	if !ok {
+		draft = &abTestDraft{Channel: 1}
+		abTestDrafts[userID] = draft
+	}
+
+	edit(draft)
+}
+
+func getABTestDraft(userID int64) abTestDraft {
+	var copied abTestDraft
+	editABTestDraft(userID, func(draft *abTestDraft) {
+		copied = abTestDraft{
+			Channel:  draft.Channel,
+			Variants: append([]*model.Post(nil), draft.Variants...),
+			Percents: append([]int(nil), draft.Percents...),
+		}
+	})
+
+	return copied
+}
+
+func (d *abTestDraft) percents() []int {
+	if len(d.Percents) != len(d.Variants) {
+		return model.EqualPercents(len(d.Variants))
+	}
+
+	return d.Percents
+}
+
+// audience is the segment of the admin when it is built for the same channel
+func (a *Admin) abTestAudience(userID int64) *model.Segment {
+	segmentDraft := getSegmentDraft(userID)
+	if segmentDraft.Channel != getABTestDraft(userID).Channel {
+		return nil
+	}
+
+	segment := segmentDraft.Segment
+	return &segment
+}
+
+func (a *Admin) abTestAudienceCount(ctx context.Context, userID int64) (int, error) {
+	segment := a.abTestAudience(userID)
+	if segment == nil {
+		segment = &model.Segment{}
+	}
+
+	total, blocked, err := model.CountSegment(ctx, a.bot.GetDataBase(), segment,
+		channelsFromNum(getABTestDraft(userID).Channel), time.Now())
+	if err != nil {
+		return 0, errors.Wrap(err, "count test audience")
+	}
+
+	return total - blocked, nil
+}
+
+func (a *Admin) abTestMarkUpAndText(ctx context.Context, userID int64) (*tgbotapi.InlineKeyboardMarkup, string, error) {
+	lang := model.AdminLang(userID)
+	draft := getABTestDraft(userID)
+	channel := strconv.Itoa(draft.Channel)
+
+	audience, err := a.abTestAudienceCount(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	markUp := &msgs.InlineMarkUp{}
+	for i := range draft.Variants {
+		index := strconv.Itoa(i)
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton("\U0001F441 "+model.VariantName(i), "admin/ab_preview/"+index),
+			msgs.NewIlCustomButton("❌ "+model.VariantName(i), "admin/delete_ab_variant/"+index),
+		))
+	}
+	markUp.Rows = append(markUp.Rows,
+		msgs.NewIlRow(msgs.NewIlAdminButton("ab_add_variant_button", "admin/post/"+channel)),
+		msgs.NewIlRow(msgs.NewIlAdminButton("ab_percents_button", "admin/ab_percents")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("ab_start_button", "admin/confirm_ab_test")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_mailing", "admin/mailing_menu/"+channel)),
+	)
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "ab_test_text",
+		draft.Channel,
+		a.abTestAudienceText(lang, userID),
+		audience,
+		a.abTestVariantsText(lang, draft))
+
+	return &builtMarkUp, text, nil
+}
+
+func (a *Admin) abTestAudienceText(lang string, userID int64) string {
+	segment := a.abTestAudience(userID)
+	switch {
+	case segment == nil:
+		return a.bot.AdminText(lang, "ab_audience_all")
+	case segment.Name != "":
+		return segment.Name
+	default:
+		return a.bot.AdminText(lang, "ab_audience_segment")
+	}
+}
+
+func (a *Admin) abTestVariantsText(lang string, draft abTestDraft) string {
+	if len(draft.Variants) == 0 {
+		return a.bot.AdminText(lang, "source_no_value")
+	}
+
+	percents := draft.percents()
+	var holdout = 100
+	lines := make([]string, len(draft.Variants))
+	for i, post := range draft.Variants {
+		holdout -= percents[i]
+		lines[i] = fmt.Sprintf("%s · %d%% · %s", model.VariantName(i), percents[i], postTextPreview(firstLine(post.Text)))
+	}
+	if holdout > 0 {
+		lines = append(lines, a.adminFormatText(lang, "ab_holdout_line", holdout))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func firstLine(text string) string {
+	return strings.SplitN(text, "\n", 2)[0]
+}
+
+func (a *Admin) sendABTestMenu(s *model.Situation) error {
+	markUp, text, err := a.abTestMarkUpAndText(s.Context(), s.User.ID)
+	if err != nil {
+		return err
+	}
+
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// ABTestCommand opens the A/B test of the channel mailing from the mailing menu
+func (a *Admin) ABTestCommand(s *model.Situation, params route.Params) error {
+	channel := params.Int("channel")
+	editABTestDraft(s.User.ID, func(draft *abTestDraft) {
+		draft.Channel = channel
+	})
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendABTestMenu(s)
+}
+
+// ABTestMsgCommand returns the admin from the percents input back to the test
+func (a *Admin) ABTestMsgCommand(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	return a.sendABTestMenu(s)
+}
+
+// AddPostVariantCommand adds the post of the builder to the A/B test
+func (a *Admin) AddPostVariantCommand(s *model.Situation) error {
+	post := getPostDraft(s.User.ID).Post
+	if err := post.Validate(); err != nil {
+		return a.sendPostError(s, err)
+	}
+
+	var full bool
+	editABTestDraft(s.User.ID, func(draft *abTestDraft) {
+		if full = len(draft.Variants) >= model.MaxMailingVariants; full {
+			return
+		}
+		draft.Variants = append(draft.Variants, post)
+		draft.Percents = nil
+	})
+	if full {
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "ab_variants_full")
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "ab_variant_added")
+	return a.sendABTestMenu(s)
+}
+
+func (a *Admin) DeleteABVariantCommand(s *model.Situation, params route.Params) error {
+	index := params.Int("index")
+
+	var deleted bool
+	editABTestDraft(s.User.ID, func(draft *abTestDraft) {
+		if deleted = index >= 0 && index < len(draft.Variants); !deleted {
+			return
+		}
+		draft.Variants = append(draft.Variants[:index], draft.Variants[index+1:]...)
+		draft.Percents = nil
+	})
+	if !deleted {
+		return a.invalidCallback(s, errors.Errorf("unknown variant %d", index))
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendABTestMenu(s)
+}
+
+// ABPreviewCommand sends the variant to the admin with the test menu under it
+func (a *Admin) ABPreviewCommand(s *model.Situation, params route.Params) error {
+	index := params.Int("index")
+	draft := getABTestDraft(s.User.ID)
+	if index < 0 || index >= len(draft.Variants) {
+		return a.invalidCallback(s, errors.Errorf("unknown variant %d", index))
+	}
+
+	if err := a.mailing.SendPost(s.User.ID, draft.Variants[index]); err != nil {
+		return errors.Wrap(err, "send variant preview")
+	}
+
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendABTestMenu(s)
+}
+
+func (a *Admin) ABPercentsCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+	draft := getABTestDraft(s.User.ID)
+	if len(draft.Variants) < 2 {
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "ab_need_variants")
+	}
+
+	if err := a.fsm.Enter(s, stateABPercents); err != nil {
+		return err
+	}
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_ab_test")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.msgs.NewParseMarkUpMessage(s.User.ID, markUp, a.adminFormatText(lang, "ab_percents_input", len(draft.Variants)))
+}
+
+func (a *Admin) SetABPercentsCommand(s *model.Situation) error {
+	// the variants are lost on the restart or deleted while the percents are asked
+	if len(getABTestDraft(s.User.ID).Variants) < 2 {
+		if err := a.sendErrorInChangeParameter(s.User.ID, "ab_need_variants"); err != nil {
+			return err
+		}
+		return a.ABTestMsgCommand(s)
+	}
+
+	var ok bool
+	editABTestDraft(s.User.ID, func(draft *abTestDraft) {
+		var percents []int
+		if percents, ok = model.ParseVariantPercents(s.Message.Text, len(draft.Variants)); ok {
+			draft.Percents = percents
+		}
+	})
+	if !ok {
+		return a.sendErrorInChangeParameter(s.User.ID, "incorrect_ab_percents")
+	}
+
+	if err := a.setAdminBackButton(s.User.ID, "operation_completed"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	return a.sendABTestMenu(s)
+}
+
+// ConfirmABTestCommand shows the audience of the test and asks to confirm the sending
+func (a *Admin) ConfirmABTestCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+	draft := getABTestDraft(s.User.ID)
+	if len(draft.Variants) < 2 {
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "ab_need_variants")
+	}
+
+	audience, err := a.abTestAudienceCount(s.Context(), s.User.ID)
+	if err != nil {
+		return err
+	}
+
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(msgs.NewIlAdminButton("confirm_mailing_button", "admin/start_ab_test")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_ab_test", "admin/ab_test/"+strconv.Itoa(draft.Channel))),
+	).Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "ab_confirm_text", audience, a.abTestVariantsText(lang, draft))
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, &markUp, text)
+}
+
+func (a *Admin) StartABTestCommand(s *model.Situation) error {
+	draft := getABTestDraft(s.User.ID)
+	if len(draft.Variants) < 2 {
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "ab_need_variants")
+	}
+
+	percents := draft.percents()
+	variants := make([]*model.MailingVariant, len(draft.Variants))
+	for i, post := range draft.Variants {
+		variants[i] = &model.MailingVariant{Post: post, Percent: percents[i]}
+	}
+
+	report, err := a.mailing.StartABTest(s.User.ID, a.abTestAudience(s.User.ID), channelsFromNum(draft.Channel), variants)
+	if err != nil {
+		return a.sendMailingError(s, err)
+	}
+	a.trackMailing(s.User.ID, report)
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_successful")
+	return a.resendAdvertisementMenuLevel(s, draft.Channel)
+}
+
+// SendABWinnerCommand sends the best variant of the finished test to the rest of its audience
+func (a *Admin) SendABWinnerCommand(s *model.Situation, params route.Params) error {
+	report, err := a.mailing.StartWinner(s.User.ID, params.Int64("report"))
+	if err != nil {
+		return a.sendMailingError(s, err)
+	}
+	a.trackMailing(s.User.ID, report)
+
+	return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_successful")
+}
+
+// abTestReportText adds the variants statistics to the report of the test
+// and tells whether the winner can be sent to the holdout
+func (a *Admin) abTestReportText(lang string, report *model.MailingReport) (string, bool) {
+	if report.TestReportID != 0 {
+		return "\n" + a.adminFormatText(lang, "ab_winner_report_text", report.TestReportID), false
+	}
+	if !report.ABTest() {
+		return "", false
+	}
+
+	statistics, holdout, err := model.GetVariantStatistics(a.bot.GetDataBase(), report.ID)
+	if err != nil {
+		a.msgs.SendNotificationToDeveloper(a.bot.BotLang+" // failed get variant statistics: "+err.Error(), false)
+		return "", false
+	}
+
+	winner := model.Winner(statistics)
+	lines := make([]string, len(statistics))
+	for i, statistic := range statistics {
+		lines[i] = a.adminFormatText(lang, "ab_variant_line",
+			model.VariantName(statistic.Variant),
+			statistic.Delivered,
+			statistic.Users,
+			statistic.Clicks,
+			statistic.CTR())
+		if statistic.Variant == winner {
+			lines[i] += " \U0001F3C6"
+		}
+	}
+
+	text := "\n" + a.adminFormatText(lang, "ab_report_text", strings.Join(lines, "\n"), holdout)
+	if holdout == 0 || winner == -1 || report.Status == model.MailingRunning {
+		return text, false
+	}
+
+	sent, err := model.WinnerSent(a.bot.GetDataBase(), report.ID)
+	if err != nil {
+		a.msgs.SendNotificationToDeveloper(a.bot.BotLang+" // failed check winner mailing: "+err.Error(), false)
+		return text, false
+	}
+
+	return text, !sent
+}
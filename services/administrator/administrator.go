@@ -6,17 +6,17 @@ import (
 	"strings"
 	"time"
 
-	"github.com/Stepan1328/miner-bot/db"
+	"github.com/Stepan1328/miner-bot/cfg"
 	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
 	"github.com/bots-empire/base-bot/msgs"
 	"github.com/pkg/errors"
 )
 
 const (
-	availableSymbolInKey       = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789abcdefghijklmnopqrstuvwxyz"
-	adminKeyLength             = 24
-	linkLifeTime               = 180
-	godUserID            int64 = 872383555
+	availableSymbolInKey = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789abcdefghijklmnopqrstuvwxyz"
+	adminKeyLength       = 24
+	linkLifeTime         = 180
 )
 
 var availableKeys = make(map[string]string)
@@ -26,17 +26,20 @@ func (a *Admin) ChangeLangCommand(s *model.Situation) error {
 	text := a.bot.AdminText(lang, "admin_set_lang_text")
 
 	markUp := msgs.NewIlMarkUp(
-		msgs.NewIlRow(msgs.NewIlAdminButton("set_lang_en", "admin/set_language?en"),
-			msgs.NewIlAdminButton("set_lang_ru", "admin/set_language?ru")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("set_lang_en", "admin/set_language/en"),
+			msgs.NewIlAdminButton("set_lang_ru", "admin/set_language/ru")),
 		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_admin_settings", "admin/admin_setting")),
 	).Build(a.bot.AdminLibrary[lang])
 
 	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
-	return a.msgs.NewEditMarkUpMessage(s.User.ID, db.RdbGetAdminMsgID(s.BotLang, s.User.ID), &markUp, text)
+	return a.msgs.NewEditMarkUpMessage(s.User.ID, a.adminMsgID(s.Context(), s.User.ID), &markUp, text)
 }
 
-func (a *Admin) SetNewLangCommand(s *model.Situation) error {
-	lang := strings.Split(s.CallbackQuery.Data, "?")[1]
+func (a *Admin) SetNewLangCommand(s *model.Situation, params route.Params) error {
+	lang := params.String("lang")
+	if _, exist := a.bot.AdminLibrary[lang]; !exist {
+		return a.invalidCallback(s, errors.Errorf("unknown admin language %q", lang))
+	}
 	model.AdminSettings.AdminID[s.User.ID].Language = lang
 	model.SaveAdminSettings()
 
@@ -67,7 +70,7 @@ func (a *Admin) CheckNewAdmin(s *model.Situation) error {
 			Language:  "ru",
 			FirstName: s.Message.From.FirstName,
 		}
-		if s.User.ID == godUserID {
+		if cfg.App.IsSpecial(s.User.ID) {
 			model.AdminSettings.AdminID[s.User.ID].SpecialPossibility = true
 		}
 		model.SaveAdminSettings()
@@ -91,7 +94,7 @@ func (a *Admin) NewAdminToListCommand(s *model.Situation) error {
 	if err != nil {
 		return err
 	}
-	db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 	s.Command = "/send_admin_list"
 	if err := a.AdminListCommand(s); err != nil {
 		return err
@@ -127,7 +130,9 @@ func (a *Admin) DeleteAdminCommand(s *model.Situation) error {
 	}
 
 	lang := model.AdminLang(s.User.ID)
-	db.RdbSetUser(s.BotLang, s.User.ID, s.CallbackQuery.Data)
+	if err := a.fsm.Enter(s, stateDeleteAdmin); err != nil {
+		return err
+	}
 
 	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
 	return a.msgs.NewParseMessage(s.User.ID, a.createListOfAdminText(lang))
@@ -140,7 +145,7 @@ func adminHavePrivileges(s *model.Situation) bool {
 func (a *Admin) createListOfAdminText(lang string) string {
 	var listOfAdmins string
 	for id, admin := range model.AdminSettings.AdminID {
-		if id == 872383555 {
+		if cfg.App.IsSpecial(id) {
 			continue
 		}
 		listOfAdmins += strconv.FormatInt(id, 10) + ") " + admin.FirstName + "\n"
@@ -150,25 +155,21 @@ func (a *Admin) createListOfAdminText(lang string) string {
 }
 
 func (a *Admin) AdvertSourceMenuCommand(s *model.Situation) error {
-	lang := model.AdminLang(s.User.ID)
-	text := a.bot.AdminText(lang, "add_new_source_text")
-
-	markUp := msgs.NewIlMarkUp(
-		msgs.NewIlRow(msgs.NewIlAdminButton("add_new_source_button", "admin/add_new_source")),
-		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_admin_settings", "admin/admin_setting")),
-	).Build(a.bot.AdminLibrary[lang])
+	markUp, text := a.sourceMenuMarkUpAndText(model.AdminLang(s.User.ID))
 
 	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
-	return a.msgs.NewEditMarkUpMessage(s.User.ID, db.RdbGetAdminMsgID(s.BotLang, s.User.ID), &markUp, text)
+	return a.msgs.NewEditMarkUpMessage(s.User.ID, a.adminMsgID(s.Context(), s.User.ID), markUp, text)
 }
 
 func (a *Admin) AddNewSourceCommand(s *model.Situation) error {
 	lang := model.AdminLang(s.User.ID)
 	text := a.bot.AdminText(lang, "input_new_source_text")
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin/get_new_source")
+	if err := a.fsm.Enter(s, stateNewSource); err != nil {
+		return err
+	}
 
 	markUp := msgs.NewMarkUp(
-		msgs.NewRow(msgs.NewAdminButton("back_to_admin_settings")),
+		msgs.NewRow(msgs.NewAdminButton("back_to_sources")),
 		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
 	).Build(a.bot.AdminLibrary[lang])
 
@@ -176,20 +177,45 @@ func (a *Admin) AddNewSourceCommand(s *model.Situation) error {
 	return a.msgs.NewParseMarkUpMessage(s.User.ID, markUp, text)
 }
 
+// GetNewSourceCommand creates the source link from the admin message:
+// the first line is the source name, the optional second line is
+// a custom slug ("-" for random) and the rest is a note
 func (a *Admin) GetNewSourceCommand(s *model.Situation) error {
-	link, err := model.EncodeLink(a.bot.GetDataBase(), a.bot.BotLink, &model.ReferralLinkInfo{
-		Source: s.Message.Text,
-	})
-	if err != nil {
-		return errors.Wrap(err, "encode link")
+	lang := model.AdminLang(s.User.ID)
+	lines := strings.Split(strings.TrimSpace(s.Message.Text), "\n")
+
+	source := &model.SourceLink{
+		Source: strings.TrimSpace(lines[0]),
+	}
+	if source.Source == "" {
+		return a.sendErrorInChangeParameter(s.User.ID, "incorrect_value")
+	}
+
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "-" {
+		source.HashKey = strings.TrimSpace(lines[1])
+	}
+	if len(lines) > 2 {
+		source.Note = strings.TrimSpace(strings.Join(lines[2:], "\n"))
+	}
+
+	link, err := model.CreateSourceLink(s.Context(), a.repos.Links, a.bot.BotLink, source)
+	switch err {
+	case nil:
+	case model.ErrInvalidSourceSlug:
+		return a.msgs.NewParseMessage(s.User.ID, a.adminFormatText(lang, "invalid_source_slug", model.SourceSlugMaxLength))
+	case model.ErrSourceSlugTaken:
+		return a.sendErrorInChangeParameter(s.User.ID, "source_slug_taken")
+	default:
+		return errors.Wrap(err, "create source link")
 	}
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin")
+	a.finishInput(s)
 
 	if err := a.msgs.NewParseMessage(s.User.ID, link); err != nil {
 		return errors.Wrap(err, "send message with link")
 	}
 
-	db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
-	return a.AdminMenuCommand(s)
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	markUp, text := a.sourceInfoMarkUpAndText(s.User.ID, source)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
 }
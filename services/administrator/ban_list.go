@@ -0,0 +1,96 @@
+package administrator
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// banListMarkUpAndText shows the banned users, the button of the user lifts the ban
+func (a *Admin) banListMarkUpAndText(lang string) (*tgbotapi.InlineKeyboardMarkup, string) {
+	ids := model.AdminSettings.BannedIDs()
+
+	markUp := &msgs.InlineMarkUp{}
+	for _, id := range ids {
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton("❌ "+strconv.FormatInt(id, 10), "admin/unban/"+strconv.FormatInt(id, 10)),
+		))
+	}
+
+	markUp.Rows = append(markUp.Rows,
+		msgs.NewIlRow(msgs.NewIlAdminButton("ban_user_button", "admin/ban_user")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_admin_settings", "admin/admin_setting")),
+	)
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "ban_list_text", len(ids))
+	return &builtMarkUp, text
+}
+
+func (a *Admin) BanListCommand(s *model.Situation) error {
+	markUp, text := a.banListMarkUpAndText(model.AdminLang(s.User.ID))
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// BanListMenuCommand returns the admin from the ban input back to the ban list
+func (a *Admin) BanListMenuCommand(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text := a.banListMarkUpAndText(model.AdminLang(s.User.ID))
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) BanUserCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+	if err := a.fsm.Enter(s, stateBanUser); err != nil {
+		return err
+	}
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_ban_list")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.msgs.NewParseMarkUpMessage(s.User.ID, markUp, a.bot.AdminText(lang, "ban_user_input"))
+}
+
+// NewBanCommand bans the user from the admin message, the admins can't be banned
+func (a *Admin) NewBanCommand(s *model.Situation) error {
+	userID, _ := strconv.ParseInt(strings.TrimSpace(s.Message.Text), 10, 64)
+
+	if ContainsInAdmin(userID) {
+		return a.sendErrorInChangeParameter(s.User.ID, "ban_admin_forbidden")
+	}
+
+	model.AdminSettings.Ban(userID)
+	model.SaveAdminSettings()
+
+	if err := a.setAdminBackButton(s.User.ID, "operation_completed"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text := a.banListMarkUpAndText(model.AdminLang(s.User.ID))
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) UnbanCommand(s *model.Situation, params route.Params) error {
+	model.AdminSettings.Unban(params.Int64("user"))
+	model.SaveAdminSettings()
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "user_unbanned")
+	markUp, text := a.banListMarkUpAndText(model.AdminLang(s.User.ID))
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
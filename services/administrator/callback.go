@@ -5,10 +5,11 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/Stepan1328/miner-bot/db"
 	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
 	"github.com/bots-empire/base-bot/msgs"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
 )
 
 type AdminCallbackHandlers struct {
@@ -24,33 +25,25 @@ func (h *AdminCallbackHandlers) Init(adminSrv *Admin) {
 	h.OnCommand("/send_menu", adminSrv.AdminMenuCommand)
 	h.OnCommand("/admin_setting", adminSrv.AdminSettingCommand)
 	h.OnCommand("/change_language", adminSrv.ChangeLangCommand)
-	h.OnCommand("/set_language", adminSrv.SetNewLangCommand)
 	h.OnCommand("/send_admin_list", adminSrv.AdminListCommand)
 	h.OnCommand("/add_admin_msg", adminSrv.NewAdminToListCommand)
 	h.OnCommand("/delete_admin", adminSrv.DeleteAdminCommand)
 	h.OnCommand("/send_advert_source_menu", adminSrv.AdvertSourceMenuCommand)
 	h.OnCommand("/add_new_source", adminSrv.AddNewSourceCommand)
+	h.OnCommand("/partner_list", adminSrv.PartnerListCommand)
+	h.OnCommand("/add_partner", adminSrv.AddPartnerCommand)
+	h.OnCommand("/ban_list", adminSrv.BanListCommand)
+	h.OnCommand("/ban_user", adminSrv.BanUserCommand)
 
 	//Make Money Setting command
 	h.OnCommand("/make_money_setting", adminSrv.MakeMoneySettingCommand)
-	h.OnCommand("/make_money", adminSrv.ChangeParameterCommand)
 	h.OnCommand("/miner_settings", adminSrv.MinerSettingCommand)
 	h.OnCommand("/change_top_amount_settings", adminSrv.SetTopAmountCommand)
-	h.OnCommand("/change_top_level", adminSrv.ChangeTopLevelCommand)
-	h.OnCommand("/change_top_amount", adminSrv.ChangeTopAmountButtonCommand)
-	h.OnCommand("/change_click_amount", adminSrv.ChangeClickAmountButton)
-	h.OnCommand("/change_upgrade_amount", adminSrv.ChangeUpgradeAmountButton)
-	h.OnCommand("/change_miner_level", adminSrv.ChangeMinerLvlButton)
 	h.OnCommand("/remove_miner_lvl", adminSrv.DeleteMinerLevelButton)
 	h.OnCommand("/add_miner_lvl", adminSrv.AddMinerLevelButton)
 	h.OnCommand("/exchange_rate", adminSrv.ExchangerSettingCommand)
-	h.OnCommand("/change_hash_to_btc_rate", adminSrv.ChangeHashToBTCRateButton)
-	h.OnCommand("/change_btc_to_currency_rate", adminSrv.ChangeBTCToCurrencyRateButton)
 	h.OnCommand("/not_clickable", adminSrv.NotClickableButton)
-	h.OnCommand("/change_rewards_gap", adminSrv.ChangeRewardsGapCommand)
 	h.OnCommand("/apply_rewards", adminSrv.ApplyRewardCommand)
-	h.OnCommand("/change_gap", adminSrv.ChangeGapCommand)
-	h.OnCommand("/change_level", adminSrv.ChangeLevelCommand)
 	h.OnCommand("/lvl_info", adminSrv.LevelInfoCommand)
 	h.OnCommand("/delete_gap", adminSrv.DeleteGapCommand)
 	h.OnCommand("/delete_level", adminSrv.DeleteLevelCommand)
@@ -58,24 +51,54 @@ func (h *AdminCallbackHandlers) Init(adminSrv *Admin) {
 
 	//Mailing command
 	h.OnCommand("/advertisement", adminSrv.AdvertisementMenuCommand)
-	h.OnCommand("/change_advert_chan", adminSrv.AdvertisementChanMenuCommand)
-	h.OnCommand("/change_url_menu", adminSrv.ChangeUrlMenuCommand)
-	h.OnCommand("/change_text_menu", adminSrv.ChangeTextMenuCommand)
-	h.OnCommand("/change_photo_menu", adminSrv.ChangePhotoMenuCommand)
-	h.OnCommand("/change_video_menu", adminSrv.ChangeVideoMenuCommand)
-	h.OnCommand("/turn", adminSrv.TurnMenuCommand)
-	h.OnCommand("/change_advert_button_status", adminSrv.ChangeUnderAdvertButtonCommand)
-	h.OnCommand("/mailing_menu", adminSrv.MailingMenuCommand)
-	h.OnCommand("/start_mailing", adminSrv.StartMailingCommand)
+	h.OnCommand("/cancel_mailing", adminSrv.CancelMailingCommand)
+	h.OnCommand("/mailing_reports", adminSrv.MailingReportsCommand)
+	h.OnCommand("/post_reset", adminSrv.PostResetCommand)
+	h.OnCommand("/post_preview", adminSrv.PostPreviewCommand)
+	h.OnCommand("/save_post", adminSrv.SavePostCommand)
+	h.OnCommand("/add_post_variant", adminSrv.AddPostVariantCommand)
+	h.OnCommand("/ab_percents", adminSrv.ABPercentsCommand)
+	h.OnCommand("/confirm_ab_test", adminSrv.ConfirmABTestCommand)
+	h.OnCommand("/start_ab_test", adminSrv.StartABTestCommand)
+	h.OnCommand("/segment_subscribed", adminSrv.SegmentSubscribedCommand)
+	h.OnCommand("/segment_reset", adminSrv.SegmentResetCommand)
+	h.OnCommand("/segment_list", adminSrv.SegmentListCommand)
+	h.OnCommand("/save_segment", adminSrv.SaveSegmentCommand)
+	h.OnCommand("/segment_mailing", adminSrv.SegmentMailingCommand)
+	h.OnCommand("/schedule_segment", adminSrv.ScheduleSegmentCommand)
+	h.OnCommand("/mailing_jobs", adminSrv.MailingJobsCommand)
 
 	//Send Statistic command
 	h.OnCommand("/send_statistic", adminSrv.StatisticCommand)
+
+	//Cohort command
+	h.OnCommand("/cohort", adminSrv.CohortCommand)
+	h.OnCommand("/cohort_source", adminSrv.CohortSourceCommand)
+	h.OnCommand("/cohort_chart", adminSrv.CohortChartCommand)
+	h.OnCommand("/update_stats", adminSrv.UpdateStatsCommand)
+
+	//Export command
+	h.OnCommand("/export_menu", adminSrv.ExportMenuCommand)
+	h.OnCommand("/export_reset", adminSrv.ExportResetCommand)
 }
 
 func (h *AdminCallbackHandlers) OnCommand(command string, handler model.Handler) {
 	h.Handlers[command] = handler
 }
 
+// CallbackHandler finds the handler of the callback of the admin panel: the route or the command
+func (a *Admin) CallbackHandler(s *model.Situation) model.Handler {
+	if !ContainsInAdmin(s.User.ID) {
+		return a.CheckAdminCallback
+	}
+
+	if handler := a.routes.Handler(s); handler != nil {
+		return handler
+	}
+
+	return a.CheckAdminCallback
+}
+
 func (a *Admin) CheckAdminCallback(s *model.Situation) error {
 	if !ContainsInAdmin(s.User.ID) {
 		return a.notAdmin(s.User)
@@ -96,7 +119,7 @@ func (a *Admin) AdminLoginCommand(s *model.Situation) error {
 	}
 
 	updateFirstNameInfo(s.Message)
-	db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 
 	if err := a.setAdminBackButton(s.User.ID, "admin_log_in"); err != nil {
 		return err
@@ -133,7 +156,7 @@ func (a *Admin) setAdminBackButton(userID int64, key string) error {
 }
 
 func (a *Admin) AdminMenuCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin")
+	a.finishInput(s)
 	lang := model.AdminLang(s.User.ID)
 	text := a.bot.AdminText(lang, "admin_main_menu_text")
 
@@ -142,13 +165,16 @@ func (a *Admin) AdminMenuCommand(s *model.Situation) error {
 		msgs.NewIlRow(msgs.NewIlAdminButton("setting_make_money_button", "admin/make_money_setting")),
 		msgs.NewIlRow(msgs.NewIlAdminButton("setting_advertisement_button", "admin/advertisement")),
 		msgs.NewIlRow(msgs.NewIlAdminButton("setting_statistic_button", "admin/send_statistic")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("cohort_button", "admin/cohort")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("update_stats_button", "admin/update_stats")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("export_button", "admin/export_menu")),
 	).Build(a.bot.AdminLibrary[lang])
 
-	if db.RdbGetAdminMsgID(s.BotLang, s.User.ID) != 0 {
+	if a.adminMsgID(s.Context(), s.User.ID) != 0 {
 		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
 		return a.msgs.NewEditMarkUpMessage(
 			s.User.ID,
-			db.RdbGetAdminMsgID(s.BotLang, s.User.ID),
+			a.adminMsgID(s.Context(), s.User.ID),
 			&markUp,
 			text,
 		)
@@ -157,19 +183,19 @@ func (a *Admin) AdminMenuCommand(s *model.Situation) error {
 	if err != nil {
 		return err
 	}
-	db.RdbSetAdminMsgID(s.BotLang, s.User.ID, msgID)
+	a.setAdminMsgID(s.Context(), s.User.ID, msgID)
 	return nil
 }
 
 func (a *Admin) AdminSettingCommand(s *model.Situation) error {
-	if strings.Contains(s.Params.Level, "delete_admin") {
+	if a.fsm.In(s, stateDeleteAdmin) {
 		if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
 			return err
 		}
-		db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+		a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 	}
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin/mailing")
+	a.finishInput(s)
 	lang := model.AdminLang(s.User.ID)
 	text := a.bot.AdminText(lang, "admin_setting_text")
 
@@ -177,6 +203,8 @@ func (a *Admin) AdminSettingCommand(s *model.Situation) error {
 		msgs.NewIlRow(msgs.NewIlAdminButton("setting_language_button", "admin/change_language")),
 		msgs.NewIlRow(msgs.NewIlAdminButton("admin_list_button", "admin/send_admin_list")),
 		msgs.NewIlRow(msgs.NewIlAdminButton("advertisement_source_button", "admin/send_advert_source_menu")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("partner_list_button", "admin/partner_list")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("ban_list_button", "admin/ban_list")),
 		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_main_menu", "admin/send_menu")),
 	).Build(a.bot.AdminLibrary[lang])
 	if err := a.sendMsgAdnAnswerCallback(s, &markUp, text); err != nil {
@@ -189,13 +217,14 @@ func (a *Admin) AdvertisementMenuCommand(s *model.Situation) error {
 	lang := model.AdminLang(s.User.ID)
 	text := a.bot.AdminText(lang, "change_advert_chan_text")
 
-	msgID := db.RdbGetAdminMsgID(s.BotLang, s.User.ID)
+	msgID := a.adminMsgID(s.Context(), s.User.ID)
 	markUp := msgs.NewIlMarkUp(
-		msgs.NewIlRow(msgs.NewIlAdminButton("change_advert_chan_1", "admin/change_advert_chan?1")),
-		msgs.NewIlRow(msgs.NewIlAdminButton("change_advert_chan_2", "admin/change_advert_chan?2")),
-		msgs.NewIlRow(msgs.NewIlAdminButton("change_advert_chan_3", "admin/change_advert_chan?3")),
-		//msgs.NewIlRow(msgs.NewIlAdminButton("global_advertisement", "admin/change_advert_chan?"+strconv.Itoa(model.MainAdvert))),
-		msgs.NewIlRow(msgs.NewIlAdminButton("distribute_button_general", "admin/mailing_menu?"+strconv.Itoa(model.GlobalMailing))),
+		msgs.NewIlRow(msgs.NewIlAdminButton("change_advert_chan_1", "admin/change_advert_chan/1")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("change_advert_chan_2", "admin/change_advert_chan/2")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("change_advert_chan_3", "admin/change_advert_chan/3")),
+		//msgs.NewIlRow(msgs.NewIlAdminButton("global_advertisement", "admin/change_advert_chan/"+strconv.Itoa(model.MainAdvert))),
+		msgs.NewIlRow(msgs.NewIlAdminButton("distribute_button_general", "admin/mailing_menu/"+strconv.Itoa(model.GlobalMailing))),
+		msgs.NewIlRow(msgs.NewIlAdminButton("mailing_reports_button", "admin/mailing_reports")),
 		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_main_menu", "admin/send_menu")),
 	).Build(a.bot.AdminLibrary[lang])
 
@@ -206,7 +235,7 @@ func (a *Admin) AdvertisementMenuCommand(s *model.Situation) error {
 			return err
 		}
 
-		db.RdbSetAdminMsgID(s.BotLang, s.User.ID, msgID)
+		a.setAdminMsgID(s.Context(), s.User.ID, msgID)
 	} else {
 		if err := a.msgs.NewEditMarkUpMessage(s.User.ID, msgID, &markUp, text); err != nil {
 			return err
@@ -224,36 +253,43 @@ func (a *Admin) AdvertisementMenuCommand(s *model.Situation) error {
 	return nil
 }
 
-func (a *Admin) AdvertisementChanMenuCommand(s *model.Situation) error {
-	data := strings.Split(s.CallbackQuery.Data, "?")
+func (a *Admin) AdvertisementChanMenuCommand(s *model.Situation, params route.Params) error {
+	if err := a.advertisementChanMenu(s, params.Int("channel")); err != nil {
+		return err
+	}
 
-	channel, _ := strconv.Atoi(data[1])
+	return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+}
 
+// advertisementChanMenu shows the advertisement settings of the channel
+func (a *Admin) advertisementChanMenu(s *model.Situation, channel int) error {
 	if channel == 5 {
-		markUp, text := a.getAdvertUrlMenu(s.BotLang, s.User.ID, channel)
-		msgID := db.RdbGetAdminMsgID(s.BotLang, s.User.ID)
+		a.finishInput(s)
+		markUp, text := a.getAdvertUrlMenu(s.User.ID, channel)
+		msgID := a.adminMsgID(s.Context(), s.User.ID)
 		if msgID == 0 {
 			var err error
 			msgID, err = a.msgs.NewIDParseMarkUpMessage(s.User.ID, markUp, text)
 			if err != nil {
 				return err
 			}
-			db.RdbSetAdminMsgID(s.BotLang, s.User.ID, msgID)
+			a.setAdminMsgID(s.Context(), s.User.ID, msgID)
 			return nil
 		} else {
 			return a.msgs.NewEditMarkUpMessage(s.User.ID, msgID, markUp, text)
 		}
 	}
 
-	if strings.Contains(s.Params.Level, "change_text_url?") {
+	if a.fsm.In(s, stateChangeAdvert) {
 		if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
 			return err
 		}
-		db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+		a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 	}
 
+	a.finishInput(s)
 	markUp, text := a.getAdvertisementMenu(s.BotLang, s.User.ID, channel)
-	msgID := db.RdbGetAdminMsgID(s.BotLang, s.User.ID)
+	msgID := a.adminMsgID(s.Context(), s.User.ID)
 	if msgID == 0 {
 		var err error
 		msgID, err = a.msgs.NewIDParseMarkUpMessage(s.User.ID, markUp, text)
@@ -261,33 +297,22 @@ func (a *Admin) AdvertisementChanMenuCommand(s *model.Situation) error {
 			return err
 		}
 
-		db.RdbSetAdminMsgID(s.BotLang, s.User.ID, msgID)
-	} else {
-		if err := a.msgs.NewEditMarkUpMessage(s.User.ID, msgID, markUp, text); err != nil {
-			return err
-		}
+		a.setAdminMsgID(s.Context(), s.User.ID, msgID)
+		return nil
 	}
 
-	if s.CallbackQuery != nil {
-		if s.CallbackQuery.ID != "" {
-			if err := a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice"); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+	return a.msgs.NewEditMarkUpMessage(s.User.ID, msgID, markUp, text)
 }
 
-func (a *Admin) getAdvertUrlMenu(botLang string, userID int64, channel int) (*tgbotapi.InlineKeyboardMarkup, string) {
+func (a *Admin) getAdvertUrlMenu(userID int64, channel int) (*tgbotapi.InlineKeyboardMarkup, string) {
 	lang := model.AdminLang(userID)
 	text := a.adminFormatText(lang, "advertisement_setting_text", "Главный")
 
 	markUp := msgs.NewIlMarkUp(
-		msgs.NewIlRow(msgs.NewIlAdminButton("change_url_button", "admin/change_url_menu?"+strconv.Itoa(channel))),
+		msgs.NewIlRow(msgs.NewIlAdminButton("change_url_button", "admin/change_url_menu/"+strconv.Itoa(channel))),
 		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_chan_menu", "admin/advertisement")),
 	).Build(a.bot.AdminLibrary[lang])
 
-	db.RdbSetUser(botLang, userID, "admin/change_advert_chan_"+strconv.Itoa(channel))
 	return &markUp, text
 }
 
@@ -298,70 +323,76 @@ func (a *Admin) getAdvertisementMenu(botLang string, userID int64, channel int)
 	Photo := "photo"
 	Video := "video"
 	Nothing := "nothing"
+	Post := "post"
 
 	switch model.AdminSettings.GlobalParameters[botLang].AdvertisingChoice[channel] {
 	case "photo":
 		Photo = "photo_on"
 	case "video":
 		Video = "video_on"
+	case model.AdvertPost:
+		Post = "post_on"
 	default:
 		Nothing = "nothing_on"
 	}
 
 	markUp := msgs.NewIlMarkUp(
-		msgs.NewIlRow(msgs.NewIlAdminButton("change_url_button", "admin/change_url_menu?"+strconv.Itoa(channel))),
-		msgs.NewIlRow(msgs.NewIlAdminButton("change_text_button", "admin/change_text_menu?"+strconv.Itoa(channel))),
-		msgs.NewIlRow(msgs.NewIlAdminButton("change_photo_button", "admin/change_photo_menu?"+strconv.Itoa(channel))),
-		msgs.NewIlRow(msgs.NewIlAdminButton("change_video_button", "admin/change_video_menu?"+strconv.Itoa(channel))),
+		msgs.NewIlRow(msgs.NewIlAdminButton("change_url_button", "admin/change_url_menu/"+strconv.Itoa(channel))),
+		msgs.NewIlRow(msgs.NewIlAdminButton("change_text_button", "admin/change_text_menu/"+strconv.Itoa(channel))),
+		msgs.NewIlRow(msgs.NewIlAdminButton("change_photo_button", "admin/change_photo_menu/"+strconv.Itoa(channel))),
+		msgs.NewIlRow(msgs.NewIlAdminButton("change_video_button", "admin/change_video_menu/"+strconv.Itoa(channel))),
 		msgs.NewIlRow(
-			msgs.NewIlAdminButton("turn_"+Photo, "admin/turn?photo?"+strconv.Itoa(channel)),
-			msgs.NewIlAdminButton("turn_"+Video, "admin/turn?video?"+strconv.Itoa(channel)),
-			msgs.NewIlAdminButton("turn_"+Nothing, "admin/turn?nothing?"+strconv.Itoa(channel)),
+			msgs.NewIlAdminButton("turn_"+Photo, "admin/turn/photo/"+strconv.Itoa(channel)),
+			msgs.NewIlAdminButton("turn_"+Video, "admin/turn/video/"+strconv.Itoa(channel)),
+			msgs.NewIlAdminButton("turn_"+Nothing, "admin/turn/nothing/"+strconv.Itoa(channel)),
+			msgs.NewIlAdminButton("turn_"+Post, "admin/turn/"+model.AdvertPost+"/"+strconv.Itoa(channel)),
 		),
-		msgs.NewIlRow(msgs.NewIlAdminButton("distribute_button", "admin/mailing_menu?"+strconv.Itoa(channel))),
+		msgs.NewIlRow(msgs.NewIlAdminButton("distribute_button", "admin/mailing_menu/"+strconv.Itoa(channel))),
 		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_chan_menu", "admin/advertisement")),
 	).Build(a.bot.AdminLibrary[lang])
 
-	db.RdbSetUser(botLang, userID, "admin/change_advert_chan_"+strconv.Itoa(channel))
 	return &markUp, text
 }
 
-func (a *Admin) ChangeUrlMenuCommand(s *model.Situation) error {
-	data := strings.Split(s.CallbackQuery.Data, "?")
-	channel, _ := strconv.Atoi(data[1])
+func (a *Admin) ChangeUrlMenuCommand(s *model.Situation, params route.Params) error {
+	channel := params.Int("channel")
 
 	key := "set_new_url_text"
 	value := model.AdminSettings.GetAdvertUrl(s.BotLang, channel)
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin/change_text_url?change_url?"+data[1])
+	if err := a.fsm.Enter(s, stateChangeAdvert, "change_url", strconv.Itoa(channel)); err != nil {
+		return err
+	}
 	if err := a.promptForInput(s.User.ID, key, value); err != nil {
 		return err
 	}
 	return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
 }
 
-func (a *Admin) ChangeTextMenuCommand(s *model.Situation) error {
-	data := strings.Split(s.CallbackQuery.Data, "?")
-	channel, _ := strconv.Atoi(data[1])
+func (a *Admin) ChangeTextMenuCommand(s *model.Situation, params route.Params) error {
+	channel := params.Int("channel")
 
 	key := "set_new_advertisement_text"
 	value := model.AdminSettings.GetAdvertText(s.BotLang, channel)
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin/change_text_url?change_text?"+data[1])
+	if err := a.fsm.Enter(s, stateChangeAdvert, "change_text", strconv.Itoa(channel)); err != nil {
+		return err
+	}
 	if err := a.promptForInput(s.User.ID, key, value); err != nil {
 		return err
 	}
 	return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
 }
 
-func (a *Admin) ChangePhotoMenuCommand(s *model.Situation) error {
-	data := strings.Split(s.CallbackQuery.Data, "?")
-	channel, _ := strconv.Atoi(data[1])
+func (a *Admin) ChangePhotoMenuCommand(s *model.Situation, params route.Params) error {
+	channel := params.Int("channel")
 
 	lang := model.AdminLang(s.User.ID)
 	key := "set_new_advertisement_photo"
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin/change_text_url?change_photo?"+data[1])
+	if err := a.fsm.Enter(s, stateChangeAdvert, "change_photo", strconv.Itoa(channel)); err != nil {
+		return err
+	}
 	err := a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "send_photo")
 	if err != nil {
 		return err
@@ -389,14 +420,15 @@ func (a *Admin) ChangePhotoMenuCommand(s *model.Situation) error {
 	return a.msgs.NewParseMarkUpPhotoMessage(s.User.ID, &markUp, text, photoFileBytes)
 }
 
-func (a *Admin) ChangeVideoMenuCommand(s *model.Situation) error {
-	data := strings.Split(s.CallbackQuery.Data, "?")
-	channel, _ := strconv.Atoi(data[1])
+func (a *Admin) ChangeVideoMenuCommand(s *model.Situation, params route.Params) error {
+	channel := params.Int("channel")
 
 	lang := model.AdminLang(s.User.ID)
 	key := "set_new_advertisement_video"
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin/change_text_url?change_video?"+data[1])
+	if err := a.fsm.Enter(s, stateChangeAdvert, "change_video", strconv.Itoa(channel)); err != nil {
+		return err
+	}
 	err := a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "send_the_video")
 	if err != nil {
 		return err
@@ -424,12 +456,13 @@ func (a *Admin) ChangeVideoMenuCommand(s *model.Situation) error {
 	return a.msgs.NewParseMarkUpVideoMessage(s.User.ID, &markUp, text, videoFileBytes)
 }
 
-func (a *Admin) TurnMenuCommand(s *model.Situation) error {
+func (a *Admin) TurnMenuCommand(s *model.Situation, params route.Params) error {
+	kind, channel := params.String("kind"), params.Int("channel")
+	if _, exist := model.AdminSettings.GlobalParameters[s.BotLang].AdvertisingChan.Url[channel]; !exist {
+		return a.invalidCallback(s, errors.Errorf("unknown channel %d", channel))
+	}
 
-	//	lang := assets.AdminLang(s.User.ID)
-	data := strings.Split(s.CallbackQuery.Data, "?")
-	channel, _ := strconv.Atoi(data[2])
-	switch data[1] {
+	switch kind {
 	case "photo":
 		if model.AdminSettings.GlobalParameters[s.BotLang].AdvertisingPhoto[channel] == "" {
 			return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "add_media")
@@ -438,39 +471,41 @@ func (a *Admin) TurnMenuCommand(s *model.Situation) error {
 		if model.AdminSettings.GlobalParameters[s.BotLang].AdvertisingVideo[channel] == "" {
 			return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "add_media")
 		}
+	case model.AdvertPost:
+		if model.AdminSettings.GlobalParameters[s.BotLang].AdvertisingPost[channel] == nil {
+			return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "post_empty")
+		}
+	case "nothing":
+	default:
+		return a.invalidCallback(s, errors.Errorf("unknown advert kind %q", kind))
 	}
-	model.AdminSettings.UpdateAdvertChoice(s.BotLang, channel, data[1])
+	model.AdminSettings.UpdateAdvertChoice(s.BotLang, channel, kind)
 
-	err := a.msgs.SendAdminAnswerCallback(s.CallbackQuery, data[1])
+	err := a.msgs.SendAdminAnswerCallback(s.CallbackQuery, kind)
 	if err != nil {
 		return err
 	}
-	//db.DeleteOldAdminMsg(lang, s.User.ID)
-
-	callback := &tgbotapi.CallbackQuery{
-		Data: "admin/change_advert_chan?" + data[2],
-	}
+	//a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 
-	s.CallbackQuery = callback
-	return a.AdvertisementChanMenuCommand(s)
+	return a.advertisementChanMenu(s, channel)
 }
 
-func (a *Admin) ChangeUnderAdvertButtonCommand(s *model.Situation) error {
-	channel := strings.Split(s.CallbackQuery.Data, "?")[1]
+func (a *Admin) ChangeUnderAdvertButtonCommand(s *model.Situation, params route.Params) error {
+	channel := strconv.Itoa(params.Int("channel"))
 
 	model.AdminSettings.GlobalParameters[s.BotLang].Parameters.ButtonUnderAdvert =
 		!model.AdminSettings.GlobalParameters[s.BotLang].Parameters.ButtonUnderAdvert
 	model.SaveAdminSettings()
 
 	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
-	return a.sendMailingMenu(s.BotLang, s.CallbackQuery.From.ID, channel)
+	return a.sendMailingMenu(s.Context(), s.BotLang, s.CallbackQuery.From.ID, channel)
 }
 
-func (a *Admin) MailingMenuCommand(s *model.Situation) error {
-	channel := strings.Split(s.CallbackQuery.Data, "?")[1]
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin/mailing")
+func (a *Admin) MailingMenuCommand(s *model.Situation, params route.Params) error {
+	channel := strconv.Itoa(params.Int("channel"))
+	a.finishInput(s)
 	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
-	return a.sendMailingMenu(s.BotLang, s.User.ID, channel)
+	return a.sendMailingMenu(s.Context(), s.BotLang, s.User.ID, channel)
 }
 
 func (a *Admin) promptForInput(userID int64, key string, values ...interface{}) error {
@@ -488,20 +523,19 @@ func (a *Admin) promptForInput(userID int64, key string, values ...interface{})
 func (a *Admin) StatisticCommand(s *model.Situation) error {
 	lang := model.AdminLang(s.User.ID)
 
-	count := a.CountUsers()
-	allCount := a.countAllUsers()
-	//referrals := a.countReferrals(s.BotLang, count)
+	count := a.CountUsers(s.Context())
+	allCount := a.countAllUsers(s.Context())
 	referrals := "unavailable"
 	//lastDayUsers := countUserFromLastDay(s.BotLang)
-	blocked := a.countBlockedUsers(s.BotLang)
-	subscribers := a.countSubscribers(s.BotLang)
+	blocked := a.countBlockedUsers(s.Context())
+	subscribers := a.countSubscribers(s.Context())
 	text := a.adminFormatText(lang, "statistic_text",
 		allCount, count, referrals, blocked, subscribers, count-blocked)
 
 	if err := a.msgs.NewParseMessage(s.User.ID, text); err != nil {
 		return err
 	}
-	db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 	if err := a.AdminMenuCommand(s); err != nil {
 		return err
 	}
@@ -515,14 +549,14 @@ func (a *Admin) adminFormatText(lang, key string, values ...interface{}) string
 }
 
 func (a *Admin) sendMsgAdnAnswerCallback(s *model.Situation, markUp *tgbotapi.InlineKeyboardMarkup, text string) error {
-	if db.RdbGetAdminMsgID(s.BotLang, s.User.ID) != 0 {
-		return a.msgs.NewEditMarkUpMessage(s.User.ID, db.RdbGetAdminMsgID(s.BotLang, s.User.ID), markUp, text)
+	if a.adminMsgID(s.Context(), s.User.ID) != 0 {
+		return a.msgs.NewEditMarkUpMessage(s.User.ID, a.adminMsgID(s.Context(), s.User.ID), markUp, text)
 	}
 	msgID, err := a.msgs.NewIDParseMarkUpMessage(s.User.ID, markUp, text)
 	if err != nil {
 		return err
 	}
-	db.RdbSetAdminMsgID(s.BotLang, s.User.ID, msgID)
+	a.setAdminMsgID(s.Context(), s.User.ID, msgID)
 
 	if s.CallbackQuery != nil {
 		if s.CallbackQuery.ID != "" {
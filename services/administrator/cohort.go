@@ -0,0 +1,235 @@
+package administrator
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/Stepan1328/miner-bot/utils"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	cohortsCount     = 8
+	cohortDateLayout = "02.01"
+)
+
+type cohortParams struct {
+	Period  string
+	BotLang string
+	Source  string
+}
+
+var (
+	cohortSettings   = make(map[int64]*cohortParams)
+	cohortSettingsMu sync.Mutex
+)
+
+func getCohortParams(userID int64, botLang string) *cohortParams {
+	cohortSettingsMu.Lock()
+	defer cohortSettingsMu.Unlock()
+
+	params, ok := cohortSettings[userID]
+	if !ok {
+		params = &cohortParams{
+			Period:  model.CohortWeek,
+			BotLang: botLang,
+		}
+		cohortSettings[userID] = params
+	}
+
+	return params
+}
+
+func (a *Admin) getCohorts(params *cohortParams) ([]*model.Cohort, error) {
+	bot, ok := model.Bots[params.BotLang]
+	if !ok {
+		return nil, errors.New("unknown bot lang: " + params.BotLang)
+	}
+
+	return model.GetCohorts(bot.GetDataBase(), params.Period, params.Source, cohortsCount, time.Now())
+}
+
+func (a *Admin) cohortMarkUpAndText(userID int64, botLang string) (*tgbotapi.InlineKeyboardMarkup, string, error) {
+	lang := model.AdminLang(userID)
+	params := getCohortParams(userID, botLang)
+
+	cohorts, err := a.getCohorts(params)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "get cohorts")
+	}
+
+	periodButton := msgs.NewIlAdminButton("cohort_by_day_button", "admin/cohort_period/"+model.CohortDay)
+	if params.Period == model.CohortDay {
+		periodButton = msgs.NewIlAdminButton("cohort_by_week_button", "admin/cohort_period/"+model.CohortWeek)
+	}
+
+	botLangs := make([]string, 0, len(model.Bots))
+	for bot := range model.Bots {
+		botLangs = append(botLangs, bot)
+	}
+	sort.Strings(botLangs)
+
+	var botRow msgs.InlineRow
+	for _, bot := range botLangs {
+		text := bot
+		if bot == params.BotLang {
+			text = "• " + bot + " •"
+		}
+		botRow.Buttons = append(botRow.Buttons, msgs.NewIlCustomButton(text, "admin/cohort_bot/"+bot))
+	}
+
+	markUp := msgs.NewIlMarkUp(
+		botRow,
+		msgs.NewIlRow(periodButton, msgs.NewIlAdminButton("cohort_source_button", "admin/cohort_source")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("cohort_chart_button", "admin/cohort_chart")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_main_menu", "admin/send_menu")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "cohort_text",
+		params.BotLang,
+		a.bot.AdminText(lang, "cohort_period_"+params.Period),
+		a.sourceValueText(lang, html.EscapeString(params.Source)),
+		cohortTable(cohorts))
+
+	return &markUp, text, nil
+}
+
+// cohortTable formats the cohorts as the monospace table of retention percents
+func cohortTable(cohorts []*model.Cohort) string {
+	var table strings.Builder
+
+	table.WriteString(fmt.Sprintf("%-5s %5s", "", "#"))
+	for k := 0; k < len(cohorts[0].Retained); k++ {
+		table.WriteString(fmt.Sprintf(" %4d", k))
+	}
+
+	for _, cohort := range cohorts {
+		table.WriteString(fmt.Sprintf("\n%-5s %5d", cohort.Start.Format(cohortDateLayout), cohort.Size))
+		for k := range cohort.Retained {
+			table.WriteString(fmt.Sprintf(" %3.0f%%", cohort.Percent(k)))
+		}
+	}
+
+	return "<pre>" + table.String() + "</pre>"
+}
+
+func (a *Admin) CohortCommand(s *model.Situation) error {
+	markUp, text, err := a.cohortMarkUpAndText(s.User.ID, s.BotLang)
+	if err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// CohortMsgCommand returns the admin from the source input back to the cohort report
+func (a *Admin) CohortMsgCommand(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text, err := a.cohortMarkUpAndText(s.User.ID, s.BotLang)
+	if err != nil {
+		return err
+	}
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) CohortPeriodCommand(s *model.Situation, params route.Params) error {
+	period := params.String("period")
+	if period != model.CohortDay && period != model.CohortWeek {
+		return a.invalidCallback(s, errors.Errorf("unknown cohort period %q", period))
+	}
+
+	getCohortParams(s.User.ID, s.BotLang).Period = period
+	return a.CohortCommand(s)
+}
+
+func (a *Admin) CohortBotCommand(s *model.Situation, params route.Params) error {
+	botLang := params.String("bot")
+	if _, ok := model.Bots[botLang]; !ok {
+		return a.invalidCallback(s, errors.Errorf("unknown bot %q", botLang))
+	}
+
+	getCohortParams(s.User.ID, s.BotLang).BotLang = botLang
+	return a.CohortCommand(s)
+}
+
+func (a *Admin) CohortSourceCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+	if err := a.fsm.Enter(s, stateCohortSource); err != nil {
+		return err
+	}
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_cohort")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.msgs.NewParseMarkUpMessage(s.User.ID, markUp, a.bot.AdminText(lang, "cohort_source_input"))
+}
+
+func (a *Admin) SetCohortSourceCommand(s *model.Situation) error {
+	source := strings.TrimSpace(s.Message.Text)
+	if source == "0" {
+		source = ""
+	}
+	getCohortParams(s.User.ID, s.BotLang).Source = source
+
+	return a.CohortMsgCommand(s)
+}
+
+func (a *Admin) CohortChartCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+	params := getCohortParams(s.User.ID, s.BotLang)
+
+	cohorts, err := a.getCohorts(params)
+	if err != nil {
+		return errors.Wrap(err, "get cohorts")
+	}
+
+	labels := make([]string, len(cohorts))
+	values := make([][]float64, len(cohorts))
+	for i, cohort := range cohorts {
+		labels[i] = cohort.Start.Format(cohortDateLayout)
+		for k := range cohort.Retained {
+			values[i] = append(values[i], cohort.Percent(k))
+		}
+	}
+
+	chart, err := utils.RenderHeatmap(labels, values)
+	if err != nil {
+		return errors.Wrap(err, "render heatmap")
+	}
+
+	photo := tgbotapi.NewPhoto(s.User.ID, tgbotapi.FileBytes{Name: "cohort.png", Bytes: chart})
+	photo.Caption = a.adminFormatText(lang, "cohort_chart_caption",
+		params.BotLang,
+		a.bot.AdminText(lang, "cohort_period_"+params.Period),
+		a.sourceValueText(lang, html.EscapeString(params.Source)))
+	photo.ParseMode = "HTML"
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	if err = a.msgs.SendMsgToUser(photo, s.User.ID); err != nil {
+		return errors.Wrap(err, "send chart")
+	}
+
+	markUp, text, err := a.cohortMarkUpAndText(s.User.ID, s.BotLang)
+	if err != nil {
+		return err
+	}
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
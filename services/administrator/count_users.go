@@ -1,21 +1,13 @@
 package administrator
 
 import (
-	"database/sql"
-	"log"
-	"strconv"
+	"context"
 
 	"github.com/Stepan1328/miner-bot/model"
-	"github.com/pkg/errors"
 )
 
-func (a *Admin) CountUsers() int {
-	rows, err := a.bot.GetDataBase().Query(`
-SELECT COUNT(*) FROM users;`)
-	if err != nil {
-		log.Println(err.Error())
-	}
-	count, err := readRows(rows)
+func (a *Admin) CountUsers(ctx context.Context) int {
+	count, err := a.repos.Users.Count(ctx)
 	if err != nil {
 		a.msgs.SendNotificationToDeveloper(err.Error(), false)
 	}
@@ -23,32 +15,13 @@ SELECT COUNT(*) FROM users;`)
 	return count
 }
 
-func readRows(rows *sql.Rows) (int, error) {
-	defer rows.Close()
-
-	var count int
-
-	for rows.Next() {
-		if err := rows.Scan(&count); err != nil {
-			return 0, errors.Wrap(err, "failed to scan row")
-		}
-	}
-
-	return count, nil
-}
-
-func (a *Admin) countAllUsers() int {
+func (a *Admin) countAllUsers(ctx context.Context) int {
 	var sum int
 	for _, handler := range model.Bots {
-		rows, err := handler.DataBase.Query(`
-SELECT COUNT(*) FROM users;`)
-		if err != nil {
-			log.Println(err.Error())
-			continue
-		}
-		count, err := readRows(rows)
+		count, err := handler.Repos.Users.Count(ctx)
 		if err != nil {
 			a.msgs.SendNotificationToDeveloper(err.Error(), false)
+			continue
 		}
 
 		sum += count
@@ -56,45 +29,17 @@ SELECT COUNT(*) FROM users;`)
 	return sum
 }
 
-func (a *Admin) countReferrals(botLang string, amountUsers int) string {
-	var refText string
-	rows, err := model.Bots[botLang].DataBase.Query("SELECT SUM(referral_count) FROM users;")
-	if err != nil {
-		log.Println(err.Error())
-	}
-
-	count, err := readRows(rows)
-	if err != nil {
-		a.msgs.SendNotificationToDeveloper(err.Error(), false)
-	}
-
-	refText = strconv.Itoa(count) + " (" + strconv.Itoa(int(float32(count)*100.0/float32(amountUsers))) + "%)"
-	return refText
-}
-
-func (a *Admin) countBlockedUsers(botLang string) int {
-	rows, err := model.Bots[botLang].DataBase.Query(`
-SELECT COUNT(DISTINCT id) FROM users WHERE status = 'deleted';`)
+func (a *Admin) countBlockedUsers(ctx context.Context) int {
+	count, err := a.repos.Users.CountBlocked(ctx)
 	if err != nil {
 		a.msgs.SendNotificationToDeveloper(err.Error(), false)
-		return 0
 	}
 
-	count, err := readRows(rows)
-	if err != nil {
-		a.msgs.SendNotificationToDeveloper(err.Error(), false)
-	}
 	return count
 }
 
-func (a *Admin) countSubscribers(botLang string) int {
-	rows, err := model.Bots[botLang].DataBase.Query(`
-SELECT COUNT(DISTINCT id) FROM subs;`)
-	if err != nil {
-		log.Println(err.Error())
-	}
-
-	count, err := readRows(rows)
+func (a *Admin) countSubscribers(ctx context.Context) int {
+	count, err := a.repos.Subs.Count(ctx)
 	if err != nil {
 		a.msgs.SendNotificationToDeveloper(err.Error(), false)
 	}
@@ -0,0 +1,255 @@
+package administrator
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	exportDate   = "date"
+	exportLang   = "lang"
+	exportStatus = "status"
+)
+
+var (
+	exportFilters   = make(map[int64]*model.ExportFilter)
+	exportFiltersMu sync.Mutex
+)
+
+func getExportFilter(userID int64) *model.ExportFilter {
+	exportFiltersMu.Lock()
+	defer exportFiltersMu.Unlock()
+
+	filter, ok := exportFilters[userID]
+	if !ok {
+		filter = &model.ExportFilter{}
+		exportFilters[userID] = filter
+	}
+
+	return filter
+}
+
+func (a *Admin) exportMenuMarkUpAndText(userID int64) (*tgbotapi.InlineKeyboardMarkup, string) {
+	lang := model.AdminLang(userID)
+	filter := getExportFilter(userID)
+
+	markUp := &msgs.InlineMarkUp{}
+	for _, table := range model.ExportTables {
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton("\U0001F4E5 "+table.Name, "admin/export/"+table.Name),
+		))
+	}
+
+	markUp.Rows = append(markUp.Rows,
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("export_date_button", "admin/export_filter/"+exportDate),
+			msgs.NewIlAdminButton("export_lang_button", "admin/export_filter/"+exportLang),
+			msgs.NewIlAdminButton("export_status_button", "admin/export_filter/"+exportStatus),
+		),
+		msgs.NewIlRow(msgs.NewIlAdminButton("export_reset_button", "admin/export_reset")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_main_menu", "admin/send_menu")),
+	)
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "export_menu_text",
+		a.exportDateText(lang, filter),
+		a.sourceValueText(lang, filter.Lang),
+		a.sourceValueText(lang, filter.Status))
+
+	return &builtMarkUp, text
+}
+
+func (a *Admin) exportDateText(lang string, filter *model.ExportFilter) string {
+	return a.dateRangeText(lang, filter.From, filter.To)
+}
+
+// dateRangeText shows the range read by parseExportDates
+func (a *Admin) dateRangeText(lang string, fromUnix, toUnix int64) string {
+	if fromUnix == 0 && toUnix == 0 {
+		return a.bot.AdminText(lang, "source_no_value")
+	}
+
+	var from, to string
+	if fromUnix != 0 {
+		from = time.Unix(fromUnix, 0).Format(sourceDateLayout)
+	}
+	if toUnix != 0 {
+		to = time.Unix(toUnix, 0).AddDate(0, 0, -1).Format(sourceDateLayout)
+	}
+
+	return from + " - " + to
+}
+
+func (a *Admin) ExportMenuCommand(s *model.Situation) error {
+	markUp, text := a.exportMenuMarkUpAndText(s.User.ID)
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// ExportMenuMsgCommand returns the admin from the filter input back to the export menu
+func (a *Admin) ExportMenuMsgCommand(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text := a.exportMenuMarkUpAndText(s.User.ID)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) ExportResetCommand(s *model.Situation) error {
+	*getExportFilter(s.User.ID) = model.ExportFilter{}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "export_filter_reset")
+	markUp, text := a.exportMenuMarkUpAndText(s.User.ID)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) ExportFilterCommand(s *model.Situation, params route.Params) error {
+	field := params.String("field")
+	lang := model.AdminLang(s.User.ID)
+
+	var text string
+	switch field {
+	case exportDate:
+		text = a.bot.AdminText(lang, "export_date_input")
+	case exportLang:
+		text = a.bot.AdminText(lang, "export_lang_input")
+	case exportStatus:
+		text = a.bot.AdminText(lang, "export_status_input")
+	default:
+		return a.invalidCallback(s, errors.Errorf("unknown export filter %q", field))
+	}
+
+	if err := a.fsm.Enter(s, stateExportFilter, field); err != nil {
+		return err
+	}
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_export")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.msgs.NewParseMarkUpMessage(s.User.ID, markUp, text)
+}
+
+func (a *Admin) SetExportFilterCommand(s *model.Situation) error {
+	filter := getExportFilter(s.User.ID)
+	value := strings.TrimSpace(s.Message.Text)
+	if value == "0" {
+		value = ""
+	}
+
+	switch s.Params.StateParams.String("field") {
+	case exportDate:
+		from, to, ok := parseExportDates(value)
+		if !ok {
+			return a.sendErrorInChangeParameter(s.User.ID, "incorrect_export_date")
+		}
+		filter.From, filter.To = from, to
+	case exportLang:
+		filter.Lang = value
+	case exportStatus:
+		filter.Status = value
+	}
+
+	if err := a.setAdminBackButton(s.User.ID, "operation_completed"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text := a.exportMenuMarkUpAndText(s.User.ID)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// parseExportDates reads the "from - to" range of days in sourceDateLayout,
+// any side can be omitted, the empty value removes the range
+func parseExportDates(value string) (int64, int64, bool) {
+	if value == "" {
+		return 0, 0, true
+	}
+
+	dates := strings.Split(value, "-")
+	if len(dates) != 2 {
+		return 0, 0, false
+	}
+
+	var from, to int64
+	if rawFrom := strings.TrimSpace(dates[0]); rawFrom != "" {
+		date, err := time.ParseInLocation(sourceDateLayout, rawFrom, time.Local)
+		if err != nil {
+			return 0, 0, false
+		}
+		from = date.Unix()
+	}
+
+	if rawTo := strings.TrimSpace(dates[1]); rawTo != "" {
+		date, err := time.ParseInLocation(sourceDateLayout, rawTo, time.Local)
+		if err != nil {
+			return 0, 0, false
+		}
+		to = date.AddDate(0, 0, 1).Unix()
+	}
+
+	if from != 0 && to != 0 && from >= to {
+		return 0, 0, false
+	}
+
+	return from, to, true
+}
+
+// ExportCommand writes the table into the temporary CSV file
+// and sends it to the admin as a document
+func (a *Admin) ExportCommand(s *model.Situation, params route.Params) error {
+	table := model.GetExportTable(params.String("table"))
+	if table == nil {
+		return a.invalidCallback(s, errors.Errorf("unknown export table %q", params.String("table")))
+	}
+
+	lang := model.AdminLang(s.User.ID)
+	filter := *getExportFilter(s.User.ID)
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "export_started")
+
+	file, err := os.CreateTemp("", table.Name+"-"+s.BotLang+"-*.csv")
+	if err != nil {
+		return errors.Wrap(err, "create temp file")
+	}
+	defer os.Remove(file.Name())
+
+	count, err := model.ExportCSV(a.bot.GetDataBase(), table, &filter, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "export csv")
+	}
+
+	captionKey := "export_done_text"
+	if !filter.Empty() && !table.SupportsFilter() {
+		captionKey = "export_done_without_filter_text"
+	}
+
+	doc := tgbotapi.NewDocument(s.User.ID, tgbotapi.FilePath(file.Name()))
+	doc.Caption = a.adminFormatText(lang, captionKey, table.Name, s.BotLang, count)
+	doc.ParseMode = "HTML"
+
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	if err = a.msgs.SendMsgToUser(doc, s.User.ID); err != nil {
+		return errors.Wrap(err, "send document")
+	}
+
+	markUp, text := a.exportMenuMarkUpAndText(s.User.ID)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
@@ -1,11 +1,10 @@
 package administrator
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 
-	"github.com/Stepan1328/miner-bot/db"
+	"github.com/Stepan1328/miner-bot/fsm"
 	"github.com/Stepan1328/miner-bot/model"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -19,18 +18,36 @@ func (h *AdminMessagesHandlers) GetHandler(command string) model.Handler {
 }
 
 func (h *AdminMessagesHandlers) Init(adminSrv *Admin) {
-	//Delete Admin command
-	h.OnCommand("/delete_admin", adminSrv.RemoveAdminCommand)
-
 	//Change Advertisement parameters command
-	h.OnCommand("/make_money", adminSrv.UpdateParameterCommand)
-	h.OnCommand("/change_text_url", adminSrv.SetNewTextUrlCommand)
-	h.OnCommand("/set_count", adminSrv.ChangeMinerCountCommand)
+	h.OnCommand("/make_money", adminSrv.BackToMakeMoneyCommand)
 	h.OnCommand("/advertisement_setting", adminSrv.AdvertisementSettingCommand)
-	h.OnCommand("/get_new_source", adminSrv.GetNewSourceCommand)
+	h.OnCommand("/source_menu", adminSrv.SourceMenuCommand)
+
+	//Partners command
+	h.OnCommand("/partners_menu", adminSrv.PartnersMenuCommand)
+
+	//Ban list command
+	h.OnCommand("/ban_list_menu", adminSrv.BanListMenuCommand)
+
+	//Cohort command
+	h.OnCommand("/cohort", adminSrv.CohortMsgCommand)
+
+	//Segment command
+	h.OnCommand("/segment", adminSrv.SegmentMsgCommand)
 
-	//Make Money Setting command
-	h.OnCommand("/change_rewards_gap", adminSrv.UpdateRewardsGapCommand)
+	//Post builder command
+	h.OnCommand("/post", adminSrv.PostMsgCommand)
+
+	//A/B test command
+	h.OnCommand("/ab_test", adminSrv.ABTestMsgCommand)
+
+	//Mailing jobs command
+	h.OnCommand("/mailing_jobs", adminSrv.MailingJobsMsgCommand)
+
+	//Export command
+	h.OnCommand("/export_menu", adminSrv.ExportMenuMsgCommand)
+
+	// inputs of the admin panel are served by the states of the fsm
 }
 
 func (h *AdminMessagesHandlers) OnCommand(command string, handler model.Handler) {
@@ -52,13 +69,8 @@ func (a *Admin) CheckAdminMessage(s *model.Situation) error {
 		}
 	}
 
-	s.Command = strings.TrimLeft(strings.Split(s.Params.Level, "?")[0], "admin")
-
-	Handler := model.Bots[s.BotLang].AdminMessageHandler.
-		GetHandler(s.Command)
-
-	if Handler != nil {
-		return Handler(s)
+	if handler := a.fsm.Handler(s); handler != nil {
+		return handler(s)
 	}
 
 	if a.checkIncomeInfo(s) {
@@ -79,7 +91,7 @@ func (a *Admin) checkIncomeInfo(s *model.Situation) bool {
 
 	lang := model.AdminLang(s.User.ID)
 
-	info, err := a.getIncomeInfo(s.Message.ForwardFrom.ID)
+	info, err := a.repos.Income.Get(s.Context(), s.Message.ForwardFrom.ID)
 	if err != nil {
 		a.msgs.SendNotificationToDeveloper("some error in get income info: "+err.Error(), false)
 		return true
@@ -101,11 +113,7 @@ func (a *Admin) checkIncomeInfo(s *model.Situation) bool {
 
 func (a *Admin) RemoveAdminCommand(s *model.Situation) error {
 	lang := model.AdminLang(s.User.ID)
-	adminId, err := strconv.ParseInt(s.Message.Text, 10, 64)
-	if err != nil {
-		text := a.bot.AdminText(lang, "incorrect_admin_id_text")
-		return a.msgs.NewParseMessage(s.User.ID, text)
-	}
+	adminId, _ := strconv.ParseInt(s.Message.Text, 10, 64)
 
 	if !checkAdminIDInTheList(adminId) {
 		text := a.bot.AdminText(lang, "incorrect_admin_id_text")
@@ -118,7 +126,7 @@ func (a *Admin) RemoveAdminCommand(s *model.Situation) error {
 	if err := a.setAdminBackButton(s.User.ID, "admin_removed_status"); err != nil {
 		return err
 	}
-	db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 
 	s.Command = "admin/send_admin_list"
 	s.CallbackQuery = &tgbotapi.CallbackQuery{Data: "admin/send_admin_list"}
@@ -130,31 +138,28 @@ func checkAdminIDInTheList(adminID int64) bool {
 	return inMap
 }
 
-func (a *Admin) UpdateParameterCommand(s *model.Situation) error {
-	if strings.Contains(s.Params.Level, "make_money?") && s.Message.Text == "← Назад к ⚙️ Заработок" {
-		if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
-			return err
-		}
-		db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
-		s.Command = "admin/make_money_setting"
-
-		return a.MakeMoneySettingCommand(s)
+// BackToMakeMoneyCommand leaves the input of the parameter and returns to the make money settings
+func (a *Admin) BackToMakeMoneyCommand(s *model.Situation) error {
+	if err := a.fsm.Finish(s); err != nil {
+		return err
 	}
 
-	partitions := strings.Split(s.Params.Level, "?")
-	if len(partitions) < 2 {
-		return fmt.Errorf("smth went wrong")
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
 	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	s.Command = "admin/make_money_setting"
 
-	partition := partitions[1]
+	return a.MakeMoneySettingCommand(s)
+}
+
+func (a *Admin) UpdateParameterCommand(s *model.Situation) error {
+	partition := s.Params.StateParams.String("parameter")
 
 	if partition == currencyType {
 		model.AdminSettings.GetParams(s.BotLang).Currency = s.Message.Text
 	} else {
-		err := a.setNewIntParameter(s, partition)
-		if err != nil {
-			return err
-		}
+		a.setNewIntParameter(s, partition)
 	}
 
 	model.SaveAdminSettings()
@@ -162,20 +167,14 @@ func (a *Admin) UpdateParameterCommand(s *model.Situation) error {
 	if err != nil {
 		return nil
 	}
-	db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 	s.Command = "admin/make_money_setting"
 
 	return a.MakeMoneySettingCommand(s)
 }
 
-func (a *Admin) setNewIntParameter(s *model.Situation, partition string) error {
-	lang := model.AdminLang(s.User.ID)
-
-	newAmount, err := strconv.Atoi(s.Message.Text)
-	if err != nil || newAmount <= 0 {
-		text := a.bot.AdminText(lang, "incorrect_make_money_change_input")
-		return a.msgs.NewParseMessage(s.User.ID, text)
-	}
+func (a *Admin) setNewIntParameter(s *model.Situation, partition string) {
+	newAmount, _ := fsm.IntInput(s, 1)
 
 	switch partition {
 	case bonusAmount:
@@ -185,13 +184,14 @@ func (a *Admin) setNewIntParameter(s *model.Situation, partition string) error {
 	case maxOfClickPDAmount:
 		model.AdminSettings.GetParams(s.BotLang).MaxOfClickPerDay = newAmount
 	}
-
-	return nil
 }
 
 func (a *Admin) SetNewTextUrlCommand(s *model.Situation) error {
-	capitation := strings.Split(s.Params.Level, "?")[1]
-	channel, _ := strconv.Atoi(strings.Split(s.Params.Level, "?")[2])
+	capitation := s.Params.StateParams.String("field")
+	channel, err := s.Params.StateParams.Int("channel")
+	if err != nil {
+		return err
+	}
 	lang := model.AdminLang(s.User.ID)
 	status := "operation_canceled"
 
@@ -226,25 +226,19 @@ func (a *Admin) SetNewTextUrlCommand(s *model.Situation) error {
 	if err := a.setAdminBackButton(s.User.ID, status); err != nil {
 		return err
 	}
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin")
-	db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+	a.finishInput(s)
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 
-	callback := &tgbotapi.CallbackQuery{
-		Data: "admin/change_advert_chan?" + strconv.Itoa(channel),
-	}
-	s.CallbackQuery = callback
-	return a.AdvertisementChanMenuCommand(s)
+	return a.advertisementChanMenu(s, channel)
 }
 
 func (a *Admin) ChangeMinerCountCommand(s *model.Situation) error {
-	level := db.RdbGetMinerLevelSetting(s.BotLang, s.User.ID)
-	count := strings.Split(s.Params.Level, "?")[1]
-
-	number, _ := strconv.Atoi(s.Message.Text)
-
-	if number < 1 {
-		return a.msgs.NewParseMessage(s.User.ID, "need_positive_number")
+	level, err := a.state.MinerLevelSetting(s.Context(), s.User.ID)
+	if err != nil {
+		return err
 	}
+	count := s.Params.StateParams.String("count")
+	number, _ := fsm.IntInput(s, 1)
 
 	switch count {
 	case "hash":
@@ -253,7 +247,7 @@ func (a *Admin) ChangeMinerCountCommand(s *model.Situation) error {
 		model.AdminSettings.GetParams(s.BotLang).UpgradeMinerCost[level] = number
 	}
 
-	err := a.msgs.NewParseMessage(s.User.ID, a.bot.AdminText(model.AdminLang(s.User.ID), "operation_completed"))
+	err = a.msgs.NewParseMessage(s.User.ID, a.bot.AdminText(model.AdminLang(s.User.ID), "operation_completed"))
 	if err != nil {
 		return err
 	}
@@ -1,28 +1,27 @@
 package administrator
 
 import (
-	"strconv"
-	"strings"
+	"context"
 
-	"github.com/Stepan1328/miner-bot/db"
 	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
 	"github.com/bots-empire/base-bot/msgs"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func (a *Admin) StartMailingCommand(s *model.Situation) error {
-	channel, _ := strconv.Atoi(strings.Split(s.CallbackQuery.Data, "?")[1])
+func (a *Admin) StartMailingCommand(s *model.Situation, params route.Params) error {
+	channel := params.Int("channel")
 
-	err := a.mailing.StartMailing(channelsFromNum(channel))
+	err := a.startMailing(s.User.ID, nil, channelsFromNum(channel))
 	if err != nil {
-		return err
+		return a.sendMailingError(s, err)
 	}
 
 	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_successful")
 	if channel == model.GlobalMailing {
 		return a.AdvertisementMenuCommand(s)
 	}
-	return a.resendAdvertisementMenuLevel(s.BotLang, s.User.ID, channel)
+	return a.resendAdvertisementMenuLevel(s, channel)
 }
 
 func channelsFromNum(channel int) []int {
@@ -33,23 +32,23 @@ func channelsFromNum(channel int) []int {
 	return []int{channel}
 }
 
-func (a *Admin) sendMailingMenu(botLang string, userID int64, channel string) error {
+func (a *Admin) sendMailingMenu(ctx context.Context, botLang string, userID int64, channel string) error {
 	lang := model.AdminLang(userID)
 
 	text := a.bot.AdminText(lang, "mailing_main_text")
 	markUp := createMailingMarkUp(botLang, channel, a.bot.AdminLibrary[lang])
 
-	if db.RdbGetAdminMsgID(botLang, userID) == 0 {
+	if a.adminMsgID(ctx, userID) == 0 {
 		msgID, err := a.msgs.NewIDParseMarkUpMessage(userID, &markUp, text)
 		if err != nil {
 			return err
 		}
 
-		db.RdbSetAdminMsgID(botLang, userID, msgID)
+		a.setAdminMsgID(ctx, userID, msgID)
 		return nil
 	}
 
-	return a.msgs.NewEditMarkUpMessage(userID, db.RdbGetAdminMsgID(botLang, userID), &markUp, text)
+	return a.msgs.NewEditMarkUpMessage(userID, a.adminMsgID(ctx, userID), &markUp, text)
 }
 
 func createMailingMarkUp(botLang, channel string, texts map[string]string) tgbotapi.InlineKeyboardMarkup {
@@ -57,39 +56,61 @@ func createMailingMarkUp(botLang, channel string, texts map[string]string) tgbot
 
 	if buttonUnderAdvertisementUnable(botLang) {
 		markUp.Rows = append(markUp.Rows,
-			msgs.NewIlRow(msgs.NewIlAdminButton("advert_button_on", "admin/change_advert_button_status?"+channel)),
+			msgs.NewIlRow(msgs.NewIlAdminButton("advert_button_on", "admin/change_advert_button_status/"+channel)),
 		)
 	} else {
 		markUp.Rows = append(markUp.Rows,
-			msgs.NewIlRow(msgs.NewIlAdminButton("advert_button_off", "admin/change_advert_button_status?"+channel)),
+			msgs.NewIlRow(msgs.NewIlAdminButton("advert_button_off", "admin/change_advert_button_status/"+channel)),
 		)
 	}
 
 	if channel == "4" {
 		markUp.Rows = append(markUp.Rows,
-			msgs.NewIlRow(msgs.NewIlAdminButton("start_mailing_button", "admin/start_mailing?"+channel)),
+			msgs.NewIlRow(
+				msgs.NewIlAdminButton("test_mailing_me_button", "admin/test_mailing/"+channel+"/me"),
+				msgs.NewIlAdminButton("test_mailing_admins_button", "admin/test_mailing/"+channel+"/"+testMailingToAdmins),
+			),
+			msgs.NewIlRow(msgs.NewIlAdminButton("start_mailing_button", "admin/confirm_mailing/"+channel)),
+			msgs.NewIlRow(msgs.NewIlAdminButton("segment_button", "admin/segment/"+channel)),
+			msgs.NewIlRow(
+				msgs.NewIlAdminButton("schedule_mailing_button", "admin/schedule_mailing/"+channel),
+				msgs.NewIlAdminButton("mailing_jobs_button", "admin/mailing_jobs"),
+			),
 			msgs.NewIlRow(msgs.NewIlAdminButton("back_to_chan_menu", "admin/advertisement")),
 		)
 	} else {
 		markUp.Rows = append(markUp.Rows,
-			msgs.NewIlRow(msgs.NewIlAdminButton("start_mailing_button", "admin/start_mailing?"+channel)),
-			msgs.NewIlRow(msgs.NewIlAdminButton("back_to_advertisement_setting", "admin/change_advert_chan?"+channel)),
+			msgs.NewIlRow(
+				msgs.NewIlAdminButton("test_mailing_me_button", "admin/test_mailing/"+channel+"/me"),
+				msgs.NewIlAdminButton("test_mailing_admins_button", "admin/test_mailing/"+channel+"/"+testMailingToAdmins),
+			),
+			msgs.NewIlRow(msgs.NewIlAdminButton("start_mailing_button", "admin/confirm_mailing/"+channel)),
+			msgs.NewIlRow(
+				msgs.NewIlAdminButton("post_builder_button", "admin/post/"+channel),
+				msgs.NewIlAdminButton("ab_test_button", "admin/ab_test/"+channel),
+			),
+			msgs.NewIlRow(msgs.NewIlAdminButton("segment_button", "admin/segment/"+channel)),
+			msgs.NewIlRow(
+				msgs.NewIlAdminButton("schedule_mailing_button", "admin/schedule_mailing/"+channel),
+				msgs.NewIlAdminButton("mailing_jobs_button", "admin/mailing_jobs"),
+			),
+			msgs.NewIlRow(msgs.NewIlAdminButton("back_to_advertisement_setting", "admin/change_advert_chan/"+channel)),
 		)
 	}
 
 	return markUp.Build(texts)
 }
 
-func (a *Admin) resendAdvertisementMenuLevel(botLang string, userID int64, channel int) error {
-	db.DeleteOldAdminMsg(botLang, userID)
+func (a *Admin) resendAdvertisementMenuLevel(s *model.Situation, channel int) error {
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 
-	db.RdbSetUser(botLang, userID, "admin/advertisement")
-	inlineMarkUp, text := a.getAdvertisementMenu(botLang, userID, channel)
-	msgID, err := a.msgs.NewIDParseMarkUpMessage(userID, inlineMarkUp, text)
+	a.finishInput(s)
+	inlineMarkUp, text := a.getAdvertisementMenu(s.BotLang, s.User.ID, channel)
+	msgID, err := a.msgs.NewIDParseMarkUpMessage(s.User.ID, inlineMarkUp, text)
 	if err != nil {
 		return err
 	}
-	db.RdbSetAdminMsgID(botLang, userID, msgID)
+	a.setAdminMsgID(s.Context(), s.User.ID, msgID)
 	return nil
 }
 
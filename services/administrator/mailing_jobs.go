@@ -0,0 +1,321 @@
+package administrator
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	mailingJobDateLayout = "02.01.2006 15:04"
+	withSegment          = "segment"
+)
+
+func (a *Admin) mailingJobsMarkUpAndText(userID int64) (*tgbotapi.InlineKeyboardMarkup, string, error) {
+	lang := model.AdminLang(userID)
+
+	jobs, err := model.GetMailingJobs(a.bot.GetDataBase())
+	if err != nil {
+		return nil, "", errors.Wrap(err, "get mailing jobs")
+	}
+
+	markUp := &msgs.InlineMarkUp{}
+	for _, job := range jobs {
+		text := fmt.Sprintf("%s · %s · %s",
+			job.Schedule(a.bot.Location()),
+			a.segmentChannelText(lang, job.Channel),
+			a.jobSegmentText(lang, job.Segment))
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton(text, "admin/mailing_job/"+strconv.FormatInt(job.ID, 10)),
+		))
+	}
+	markUp.Rows = append(markUp.Rows,
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_chan_menu", "admin/advertisement")),
+	)
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[lang])
+
+	textKey := "mailing_jobs_text"
+	if len(jobs) == 0 {
+		textKey = "mailing_jobs_empty"
+	}
+
+	return &builtMarkUp, a.adminFormatText(lang, textKey, a.bot.Location().String()), nil
+}
+
+func (a *Admin) jobSegmentText(lang string, segment *model.Segment) string {
+	switch {
+	case segment == nil:
+		return a.bot.AdminText(lang, "mailing_job_all_users")
+	case segment.Name != "":
+		return segment.Name
+	default:
+		return a.bot.AdminText(lang, "mailing_job_unnamed_segment")
+	}
+}
+
+func (a *Admin) MailingJobsCommand(s *model.Situation) error {
+	markUp, text, err := a.mailingJobsMarkUpAndText(s.User.ID)
+	if err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// MailingJobsMsgCommand returns the admin from the schedule input back to the jobs list
+func (a *Admin) MailingJobsMsgCommand(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
+	}
+
+	return a.resendMailingJobs(s)
+}
+
+func (a *Admin) resendMailingJobs(s *model.Situation) error {
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text, err := a.mailingJobsMarkUpAndText(s.User.ID)
+	if err != nil {
+		return err
+	}
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) sendScheduleInput(userID int64, key string) error {
+	lang := model.AdminLang(userID)
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_mailing_jobs")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	return a.msgs.NewParseMarkUpMessage(userID, markUp, a.adminFormatText(lang, key, a.bot.Location().String()))
+}
+
+// ScheduleMailingCommand asks the schedule of the mailing to every user of the channel
+func (a *Admin) ScheduleMailingCommand(s *model.Situation, params route.Params) error {
+	channel := strconv.Itoa(params.Int("channel"))
+	if err := a.fsm.Enter(s, stateNewMailingJob, channel); err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.sendScheduleInput(s.User.ID, "mailing_schedule_input")
+}
+
+// ScheduleSegmentCommand asks the schedule of the mailing to the segment the admin is building
+func (a *Admin) ScheduleSegmentCommand(s *model.Situation) error {
+	channel := strconv.Itoa(getSegmentDraft(s.User.ID).Channel)
+	if err := a.fsm.Enter(s, stateNewMailingJob, channel, withSegment); err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.sendScheduleInput(s.User.ID, "mailing_schedule_input")
+}
+
+func (a *Admin) NewMailingJobCommand(s *model.Situation) error {
+	channel, err := s.Params.StateParams.Int("channel")
+	if err != nil {
+		return err
+	}
+
+	repeat, next, err := model.ParseMailingSchedule(s.Message.Text, a.bot.Location(), time.Now())
+	if err != nil {
+		return a.sendScheduleError(s.User.ID, err)
+	}
+
+	job := &model.MailingJob{
+		Channel:   channel,
+		Repeat:    repeat,
+		NextRun:   next.Unix(),
+		CreatedBy: s.User.ID,
+	}
+	if s.Params.StateParams.String("segment") == withSegment {
+		segment := getSegmentDraft(s.User.ID).Segment
+		job.Segment = &segment
+	}
+
+	if err = model.CreateMailingJob(a.bot.GetDataBase(), job); err != nil {
+		return errors.Wrap(err, "create mailing job")
+	}
+
+	if err = a.setAdminBackButton(s.User.ID, "mailing_job_created"); err != nil {
+		return err
+	}
+	return a.resendMailingJobs(s)
+}
+
+func (a *Admin) sendScheduleError(userID int64, err error) error {
+	switch err {
+	case model.ErrInvalidSchedule:
+		return a.sendErrorInChangeParameter(userID, "incorrect_mailing_schedule")
+	case model.ErrScheduleInPast:
+		return a.sendErrorInChangeParameter(userID, "mailing_schedule_in_past")
+	default:
+		return err
+	}
+}
+
+func (a *Admin) mailingJobMarkUpAndText(userID int64, job *model.MailingJob) (*tgbotapi.InlineKeyboardMarkup, string) {
+	lang := model.AdminLang(userID)
+	id := strconv.FormatInt(job.ID, 10)
+
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(msgs.NewIlAdminButton("mailing_job_edit_button", "admin/edit_mailing_job/"+id)),
+		msgs.NewIlRow(msgs.NewIlAdminButton("mailing_job_cancel_button", "admin/cancel_mailing_job/"+id)),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_mailing_jobs", "admin/mailing_jobs")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "mailing_job_text",
+		job.ID,
+		html.EscapeString(job.Schedule(a.bot.Location())),
+		a.bot.AdminText(lang, "mailing_job_repeat_"+job.Repeat),
+		time.Unix(job.NextRun, 0).In(a.bot.Location()).Format(mailingJobDateLayout),
+		a.segmentChannelText(lang, job.Channel),
+		html.EscapeString(a.jobSegmentText(lang, job.Segment)),
+		job.CreatedBy)
+
+	return &markUp, text
+}
+
+func (a *Admin) MailingJobCommand(s *model.Situation, params route.Params) error {
+	job, err := model.GetMailingJob(a.bot.GetDataBase(), params.Int64("job"))
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_job_not_found")
+		return a.MailingJobsCommand(s)
+	}
+
+	markUp, text := a.mailingJobMarkUpAndText(s.User.ID, job)
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) EditMailingJobCommand(s *model.Situation, params route.Params) error {
+	job, err := model.GetMailingJob(a.bot.GetDataBase(), params.Int64("job"))
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_job_not_found")
+		return a.MailingJobsCommand(s)
+	}
+
+	if err = a.fsm.Enter(s, stateMailingJob, strconv.FormatInt(job.ID, 10)); err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.sendScheduleInput(s.User.ID, "mailing_schedule_input")
+}
+
+func (a *Admin) SetMailingJobCommand(s *model.Situation) error {
+	id, err := s.Params.StateParams.Int64("job")
+	if err != nil {
+		return err
+	}
+
+	job, err := model.GetMailingJob(a.bot.GetDataBase(), id)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		if err = a.setAdminBackButton(s.User.ID, "mailing_job_not_found"); err != nil {
+			return err
+		}
+		return a.resendMailingJobs(s)
+	}
+
+	repeat, next, err := model.ParseMailingSchedule(s.Message.Text, a.bot.Location(), time.Now())
+	if err != nil {
+		return a.sendScheduleError(s.User.ID, err)
+	}
+
+	job.Repeat, job.NextRun = repeat, next.Unix()
+	if err = model.UpdateMailingJobSchedule(a.bot.GetDataBase(), job); err != nil {
+		return errors.Wrap(err, "update mailing job")
+	}
+
+	if err = a.setAdminBackButton(s.User.ID, "operation_completed"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text := a.mailingJobMarkUpAndText(s.User.ID, job)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) CancelMailingJobCommand(s *model.Situation, params route.Params) error {
+	if err := model.DeleteMailingJob(a.bot.GetDataBase(), params.Int64("job")); err != nil {
+		return errors.Wrap(err, "delete mailing job")
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_job_canceled")
+	markUp, text, err := a.mailingJobsMarkUpAndText(s.User.ID)
+	if err != nil {
+		return err
+	}
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// RunDueMailingJobs starts the mailing of the earliest due job, the rest wait for
+// the next check as only one mailing runs at a time. The job is moved to the next run
+// or removed before the start, so a failed mailing isn't repeated every minute
+func (a *Admin) RunDueMailingJobs() error {
+	// the shutdown waits for the started check
+	a.jobsMu.Lock()
+	defer a.jobsMu.Unlock()
+	if a.stopped {
+		return nil
+	}
+
+	now := time.Now()
+
+	// the due jobs wait for the running mailing to end
+	if a.mailing.Progress() != nil {
+		return nil
+	}
+
+	jobs, err := model.GetDueMailingJobs(a.bot.GetDataBase(), now)
+	if err != nil {
+		return errors.Wrap(err, "get due mailing jobs")
+	}
+
+	for _, job := range jobs {
+		if job.Advance(a.bot.Location(), now) {
+			err = model.UpdateMailingJobSchedule(a.bot.GetDataBase(), job)
+		} else {
+			err = model.DeleteMailingJob(a.bot.GetDataBase(), job.ID)
+		}
+		if err != nil {
+			return errors.Wrap(err, "move mailing job")
+		}
+
+		report, err := a.mailing.Start(0, job.Segment, channelsFromNum(job.Channel))
+		if err == model.ErrNoMailingUsers {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "start mailing job %d", job.ID)
+		}
+
+		a.trackMailing(job.CreatedBy, report)
+		return nil
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package administrator
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	"github.com/pkg/errors"
+)
+
+const testMailingToAdmins = "admins"
+
+// TestMailingCommand sends the advertisement of the mailing to the admin
+// or to all admins, so it can be checked before the real mailing
+func (a *Admin) TestMailingCommand(s *model.Situation, params route.Params) error {
+	channel := params.Int("channel")
+
+	userIDs := []int64{s.User.ID}
+	if params.String("to") == testMailingToAdmins {
+		userIDs = userIDs[:0]
+		for id := range model.AdminSettings.AdminID {
+			userIDs = append(userIDs, id)
+		}
+	}
+
+	channels := channelsFromNum(channel)
+	delivered, err := a.mailing.SendTest(userIDs, channels)
+	if err != nil {
+		return errors.Wrap(err, "send test mailing")
+	}
+
+	lang := model.AdminLang(s.User.ID)
+	text := a.adminFormatText(lang, "test_mailing_sent", delivered, len(userIDs)*len(channels))
+	return a.msgs.SendAnswerCallback(s.CallbackQuery, text)
+}
+
+// ConfirmMailingCommand shows how many users will get the mailing
+// and asks to confirm the sending to everyone
+func (a *Admin) ConfirmMailingCommand(s *model.Situation, params route.Params) error {
+	channelNum := params.Int("channel")
+	channel := strconv.Itoa(channelNum)
+	lang := model.AdminLang(s.User.ID)
+
+	total, blocked, err := model.CountSegment(s.Context(), a.bot.GetDataBase(), &model.Segment{}, channelsFromNum(channelNum), time.Now())
+	if err != nil {
+		return errors.Wrap(err, "count mailing users")
+	}
+
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(msgs.NewIlAdminButton("confirm_mailing_button", "admin/start_mailing/"+channel)),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_mailing", "admin/mailing_menu/"+channel)),
+	).Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "confirm_mailing_text", total-blocked)
+
+	a.finishInput(s)
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, &markUp, text)
+}
@@ -0,0 +1,244 @@
+package administrator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	mailingProgressInterval = 5 * time.Second
+	mailingReportsCount     = 10
+)
+
+// startMailing starts the mailing and shows its live progress to the admin
+func (a *Admin) startMailing(userID int64, segment *model.Segment, channels []int) error {
+	report, err := a.mailing.Start(userID, segment, channels)
+	if err != nil {
+		return err
+	}
+
+	a.trackMailing(userID, report)
+	return nil
+}
+
+// trackMailing sends the progress message and updates it until the mailing ends
+func (a *Admin) trackMailing(userID int64, report *model.MailingReport) {
+	if !ContainsInAdmin(userID) {
+		return
+	}
+
+	markUp, text := a.mailingProgressMarkUpAndText(userID, report)
+	msgID, err := a.msgs.NewIDParseMarkUpMessage(userID, markUp, text)
+	if err != nil {
+		a.msgs.SendNotificationToDeveloper(a.bot.BotLang+" // failed send mailing progress: "+err.Error(), false)
+		return
+	}
+
+	a.trackers.Add(1)
+	go func() {
+		defer a.trackers.Done()
+
+		ticker := time.NewTicker(mailingProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-a.mailing.Stopping():
+				return
+			}
+
+			progress := a.mailing.Progress()
+			if progress == nil || progress.ID != report.ID {
+				break
+			}
+
+			markUp, text = a.mailingProgressMarkUpAndText(userID, progress)
+			_ = a.msgs.NewEditMarkUpMessage(userID, msgID, markUp, text)
+		}
+
+		// the tracking outlives the update which started the mailing
+		final, err := model.GetMailingReport(context.Background(), a.bot.GetDataBase(), report.ID)
+		if err != nil || final == nil {
+			return
+		}
+
+		markUp, text = a.mailingReportMarkUpAndText(userID, final)
+		_ = a.msgs.NewEditMarkUpMessage(userID, msgID, markUp, text)
+	}()
+}
+
+func (a *Admin) mailingProgressMarkUpAndText(userID int64, report *model.MailingReport) (*tgbotapi.InlineKeyboardMarkup, string) {
+	lang := model.AdminLang(userID)
+
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(msgs.NewIlAdminButton("cancel_mailing_button", "admin/cancel_mailing")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "mailing_progress_text",
+		report.ID,
+		report.Sent,
+		report.Failed,
+		report.Blocked,
+		report.Remaining(),
+		report.Total,
+		report.ETA(time.Now()).Round(time.Second).String())
+
+	return &markUp, text
+}
+
+func (a *Admin) CancelMailingCommand(s *model.Situation) error {
+	if !a.mailing.Cancel() {
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_not_running")
+	}
+
+	return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_canceling")
+}
+
+func (a *Admin) mailingReportMarkUpAndText(userID int64, report *model.MailingReport) (*tgbotapi.InlineKeyboardMarkup, string) {
+	lang := model.AdminLang(userID)
+
+	abTestText, canSendWinner := a.abTestReportText(lang, report)
+
+	markUp := &msgs.InlineMarkUp{}
+	if canSendWinner {
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlAdminButton("ab_send_winner_button", "admin/send_ab_winner/"+strconv.FormatInt(report.ID, 10)),
+		))
+	}
+	markUp.Rows = append(markUp.Rows,
+		msgs.NewIlRow(msgs.NewIlAdminButton("mailing_reports_button", "admin/mailing_reports")),
+	)
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[lang])
+
+	var finished string
+	if report.FinishedAt != 0 {
+		finished = time.Unix(report.FinishedAt, 0).In(a.bot.Location()).Format(mailingJobDateLayout)
+	}
+
+	text := a.adminFormatText(lang, "mailing_report_text",
+		report.ID,
+		a.bot.AdminText(lang, "mailing_status_"+report.Status),
+		a.mailingStarterText(lang, report.StartedBy),
+		time.Unix(report.StartedAt, 0).In(a.bot.Location()).Format(mailingJobDateLayout),
+		a.sourceValueText(lang, finished),
+		report.Total,
+		report.Sent,
+		report.Blocked,
+		report.Failed,
+		a.mailingErrorsText(lang, report.Errors))
+
+	if report.WithButton {
+		text += "\n" + a.adminFormatText(lang, "mailing_report_clicks", report.Clicks, report.CTR())
+	}
+	text += abTestText
+
+	return &builtMarkUp, text
+}
+
+func (a *Admin) mailingStarterText(lang string, startedBy int64) string {
+	if startedBy == 0 {
+		return a.bot.AdminText(lang, "mailing_started_by_scheduler")
+	}
+
+	return "<code>" + strconv.FormatInt(startedBy, 10) + "</code>"
+}
+
+func (a *Admin) mailingErrorsText(lang string, reportErrors map[string]int) string {
+	if len(reportErrors) == 0 {
+		return a.bot.AdminText(lang, "source_no_value")
+	}
+
+	errorTypes := make([]string, 0, len(reportErrors))
+	for errorType := range reportErrors {
+		errorTypes = append(errorTypes, errorType)
+	}
+	sort.Slice(errorTypes, func(i, j int) bool {
+		return reportErrors[errorTypes[i]] > reportErrors[errorTypes[j]]
+	})
+
+	lines := make([]string, len(errorTypes))
+	for i, errorType := range errorTypes {
+		lines[i] = fmt.Sprintf("  %s: %d", errorType, reportErrors[errorType])
+	}
+
+	return "\n" + strings.Join(lines, "\n")
+}
+
+func (a *Admin) MailingReportsCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+
+	reports, err := model.GetLastMailingReports(a.bot.GetDataBase(), mailingReportsCount)
+	if err != nil {
+		return errors.Wrap(err, "get mailing reports")
+	}
+
+	markUp := &msgs.InlineMarkUp{}
+	for _, report := range reports {
+		text := fmt.Sprintf("#%d · %s · %d/%d",
+			report.ID,
+			time.Unix(report.StartedAt, 0).In(a.bot.Location()).Format(mailingJobDateLayout),
+			report.Sent,
+			report.Total)
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton(text, "admin/mailing_report/"+strconv.FormatInt(report.ID, 10)),
+		))
+	}
+	markUp.Rows = append(markUp.Rows,
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_chan_menu", "admin/advertisement")),
+	)
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[lang])
+
+	textKey := "mailing_reports_text"
+	if len(reports) == 0 {
+		textKey = "mailing_reports_empty"
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, &builtMarkUp, a.bot.AdminText(lang, textKey))
+}
+
+func (a *Admin) MailingReportCommand(s *model.Situation, params route.Params) error {
+	report, err := model.GetMailingReport(s.Context(), a.bot.GetDataBase(), params.Int64("report"))
+	if err != nil {
+		return errors.Wrap(err, "get mailing report")
+	}
+	if report == nil {
+		return a.invalidCallback(s, errors.Errorf("unknown mailing report %d", params.Int64("report")))
+	}
+
+	// the running mailing is shown with the counters not saved yet
+	if progress := a.mailing.Progress(); progress != nil && progress.ID == report.ID {
+		progress.Clicks = report.Clicks
+		report = progress
+	}
+
+	markUp, text := a.mailingReportMarkUpAndText(s.User.ID, report)
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// sendMailingError explains to the admin why the mailing didn't start
+func (a *Admin) sendMailingError(s *model.Situation, err error) error {
+	switch err {
+	case model.ErrMailingInProgress:
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_in_progress")
+	case model.ErrNoMailingUsers:
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_no_users")
+	case model.ErrWinnerAlreadySent:
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "ab_winner_already_sent")
+	default:
+		return err
+	}
+}
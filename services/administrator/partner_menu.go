@@ -0,0 +1,115 @@
+package administrator
+
+import (
+	"html"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+func ContainsInPartners(userID int64) bool {
+	_, ok := model.AdminSettings.Partner(userID)
+	return ok
+}
+
+func (a *Admin) notPartner(s *model.Situation) error {
+	text := a.bot.LangText(s.User.Language, "not_partner")
+	if s.CallbackQuery != nil {
+		return a.msgs.SendAnswerCallback(s.CallbackQuery, text)
+	}
+
+	return a.msgs.SendSimpleMsg(s.User.ID, text)
+}
+
+// sendPartnerMsg edits the message with the pressed button or sends a new one
+func (a *Admin) sendPartnerMsg(s *model.Situation, markUp *tgbotapi.InlineKeyboardMarkup, text string) error {
+	if s.CallbackQuery != nil && s.CallbackQuery.Message != nil {
+		_ = a.msgs.SendAnswerCallback(s.CallbackQuery, "")
+		return a.msgs.NewEditMarkUpMessage(s.User.ID, s.CallbackQuery.Message.MessageID, markUp, text)
+	}
+
+	return a.msgs.NewParseMarkUpMessage(s.User.ID, markUp, text)
+}
+
+// PartnerMenuCommand shows the partner the summary of all his source links
+func (a *Admin) PartnerMenuCommand(s *model.Situation) error {
+	partner, ok := model.AdminSettings.Partner(s.User.ID)
+	if !ok {
+		return a.notPartner(s)
+	}
+
+	if s.Message != nil && partner.FirstName != s.Message.From.FirstName {
+		partner.FirstName = s.Message.From.FirstName
+		model.AdminSettings.SetPartner(s.User.ID, partner)
+		model.SaveAdminSettings()
+	}
+
+	links, err := a.repos.Links.GetOwnerSources(s.Context(), s.User.ID)
+	if err != nil {
+		return errors.Wrap(err, "get owner source links")
+	}
+
+	markUp := &msgs.InlineMarkUp{}
+	var registrations, activated int
+	for _, link := range links {
+		count, err := a.repos.Income.CountActivated(s.Context(), link.HashKey)
+		if err != nil {
+			return errors.Wrap(err, "count activated by source")
+		}
+
+		registrations += link.Registrations
+		activated += count
+
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton(sourceStatusSign(link)+" "+link.Source, "/partner_source/"+link.HashKey),
+		))
+	}
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[partner.Language])
+
+	text := a.adminFormatText(partner.Language, "partner_menu_text",
+		len(links),
+		registrations,
+		activated,
+		float64(activated)*partner.Payout)
+
+	return a.sendPartnerMsg(s, &builtMarkUp, text)
+}
+
+func (a *Admin) PartnerSourceCommand(s *model.Situation, params route.Params) error {
+	partner, ok := model.AdminSettings.Partner(s.User.ID)
+	if !ok {
+		return a.notPartner(s)
+	}
+
+	link, err := a.repos.Links.GetSource(s.Context(), params.String("hash"))
+	if err != nil {
+		return errors.Wrap(err, "get source link")
+	}
+
+	if link == nil || link.OwnerID != s.User.ID {
+		return a.msgs.SendAnswerCallback(s.CallbackQuery, a.bot.AdminText(partner.Language, "source_not_found"))
+	}
+
+	activated, err := a.repos.Income.CountActivated(s.Context(), link.HashKey)
+	if err != nil {
+		return errors.Wrap(err, "count activated by source")
+	}
+
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_partner_menu", "/partner_menu")),
+	).Build(a.bot.AdminLibrary[partner.Language])
+
+	text := a.adminFormatText(partner.Language, "partner_source_text",
+		html.EscapeString(link.Source),
+		model.MakeBotLink(a.bot.BotLink, link.HashKey),
+		a.sourceStatusText(partner.Language, link),
+		link.Clicks,
+		link.Registrations,
+		activated,
+		float64(activated)*partner.Payout)
+
+	return a.sendPartnerMsg(s, &markUp, text)
+}
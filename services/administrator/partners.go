@@ -0,0 +1,213 @@
+package administrator
+
+import (
+	"context"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+func (a *Admin) partnerListMarkUpAndText(lang string) (*tgbotapi.InlineKeyboardMarkup, string) {
+	ids := model.AdminSettings.PartnerIDs()
+
+	markUp := &msgs.InlineMarkUp{}
+	for _, id := range ids {
+		partner, exist := model.AdminSettings.Partner(id)
+		if !exist {
+			continue
+		}
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton(partnerName(id, partner), "admin/partner_info/"+strconv.FormatInt(id, 10)),
+		))
+	}
+
+	markUp.Rows = append(markUp.Rows,
+		msgs.NewIlRow(msgs.NewIlAdminButton("add_partner_button", "admin/add_partner")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_admin_settings", "admin/admin_setting")),
+	)
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "partner_list_text", len(ids))
+	return &builtMarkUp, text
+}
+
+func partnerName(id int64, partner model.Partner) string {
+	if partner.FirstName == "" {
+		return strconv.FormatInt(id, 10)
+	}
+
+	return partner.FirstName + " (" + strconv.FormatInt(id, 10) + ")"
+}
+
+func (a *Admin) PartnerListCommand(s *model.Situation) error {
+	markUp, text := a.partnerListMarkUpAndText(model.AdminLang(s.User.ID))
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// PartnersMenuCommand returns the admin from the partner input back to the partner list
+func (a *Admin) PartnersMenuCommand(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text := a.partnerListMarkUpAndText(model.AdminLang(s.User.ID))
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) AddPartnerCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+	if err := a.fsm.Enter(s, stateNewPartner); err != nil {
+		return err
+	}
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_partners")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.msgs.NewParseMarkUpMessage(s.User.ID, markUp, a.bot.AdminText(lang, "add_partner_input"))
+}
+
+func (a *Admin) NewPartnerCommand(s *model.Situation) error {
+	partnerID, _ := strconv.ParseInt(strings.TrimSpace(s.Message.Text), 10, 64)
+
+	if !model.AdminSettings.AddPartner(partnerID, model.Partner{Language: "ru"}) {
+		return a.sendErrorInChangeParameter(s.User.ID, "partner_already_exists")
+	}
+	model.SaveAdminSettings()
+
+	if err := a.setAdminBackButton(s.User.ID, "operation_completed"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text, err := a.partnerInfoMarkUpAndText(s.Context(), s.User.ID, partnerID)
+	if err != nil {
+		return err
+	}
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) PartnerInfoCommand(s *model.Situation, params route.Params) error {
+	partnerID := params.Int64("partner")
+	if _, exist := model.AdminSettings.Partner(partnerID); !exist {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "partner_not_found")
+		return nil
+	}
+
+	markUp, text, err := a.partnerInfoMarkUpAndText(s.Context(), s.User.ID, partnerID)
+	if err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) partnerInfoMarkUpAndText(ctx context.Context, userID, partnerID int64) (*tgbotapi.InlineKeyboardMarkup, string, error) {
+	lang := model.AdminLang(userID)
+	partner, _ := model.AdminSettings.Partner(partnerID)
+
+	links, err := a.repos.Links.GetOwnerSources(ctx, partnerID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "get owner source links")
+	}
+
+	id := strconv.FormatInt(partnerID, 10)
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(msgs.NewIlAdminButton("partner_payout_button", "admin/partner_payout/"+id)),
+		msgs.NewIlRow(msgs.NewIlAdminButton("delete_partner_button", "admin/delete_partner/"+id)),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_partner_list", "admin/partner_list")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "partner_info_text",
+		partnerID,
+		a.sourceValueText(lang, html.EscapeString(partner.FirstName)),
+		partner.Payout,
+		len(links))
+
+	return &markUp, text, nil
+}
+
+func (a *Admin) PartnerPayoutCommand(s *model.Situation, params route.Params) error {
+	partnerID := params.Int64("partner")
+	lang := model.AdminLang(s.User.ID)
+
+	partner, exist := model.AdminSettings.Partner(partnerID)
+	if !exist {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "partner_not_found")
+		return nil
+	}
+
+	if err := a.fsm.Enter(s, statePartnerPayout, strconv.FormatInt(partnerID, 10)); err != nil {
+		return err
+	}
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_partners")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.msgs.NewParseMarkUpMessage(s.User.ID, markUp,
+		a.adminFormatText(lang, "partner_payout_input", partner.Payout))
+}
+
+func (a *Admin) SetPartnerPayoutCommand(s *model.Situation) error {
+	partnerID, err := s.Params.StateParams.Int64("partner")
+	if err != nil {
+		return err
+	}
+
+	partner, exist := model.AdminSettings.Partner(partnerID)
+	if !exist {
+		return a.sendErrorInChangeParameter(s.User.ID, "partner_not_found")
+	}
+
+	payout, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(s.Message.Text), ",", ".", 1), 64)
+	if err != nil || payout < 0 {
+		return a.sendErrorInChangeParameter(s.User.ID, "incorrect_value")
+	}
+
+	partner.Payout = payout
+	model.AdminSettings.SetPartner(partnerID, partner)
+	model.SaveAdminSettings()
+
+	if err := a.setAdminBackButton(s.User.ID, "operation_completed"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text, err := a.partnerInfoMarkUpAndText(s.Context(), s.User.ID, partnerID)
+	if err != nil {
+		return err
+	}
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) DeletePartnerCommand(s *model.Situation, params route.Params) error {
+	partnerID := params.Int64("partner")
+	if _, exist := model.AdminSettings.Partner(partnerID); !exist {
+		return a.sendErrorInChangeParameter(s.User.ID, "partner_not_found")
+	}
+
+	model.AdminSettings.DeletePartner(partnerID)
+	model.SaveAdminSettings()
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "partner_deleted")
+	markUp, text := a.partnerListMarkUpAndText(model.AdminLang(s.User.ID))
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
@@ -0,0 +1,315 @@
+package administrator
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	postText    = "text"
+	postMedia   = "media"
+	postButtons = "buttons"
+	postClone   = "clone"
+
+	postTextPreviewLength = 200
+)
+
+// postDraft is the post the admin is building for the advertisement of the channel
+type postDraft struct {
+	Channel int
+	Post    *model.Post
+}
+
+var (
+	postDrafts   = make(map[int64]*postDraft)
+	postDraftsMu sync.Mutex
+)
+
+// editPostDraft changes the draft under the lock, the parts
+// of the album come in the parallel updates
+func editPostDraft(userID int64, edit func(draft *postDraft)) {
+	postDraftsMu.Lock()
+	defer postDraftsMu.Unlock()
+
+	draft, ok := postDrafts[userID]
+	if !ok {
+		draft = &postDraft{Post: &model.Post{}}
+		postDrafts[userID] = draft
+	}
+
+	edit(draft)
+}
+
+func getPostDraft(userID int64) postDraft {
+	var copied postDraft
+	editPostDraft(userID, func(draft *postDraft) {
+		copied = postDraft{Channel: draft.Channel, Post: draft.Post.Copy()}
+	})
+
+	return copied
+}
+
+func (a *Admin) postMarkUpAndText(userID int64) (*tgbotapi.InlineKeyboardMarkup, string) {
+	lang := model.AdminLang(userID)
+	draft := getPostDraft(userID)
+	channel := strconv.Itoa(draft.Channel)
+
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("post_text_button", "admin/post_input/"+postText),
+			msgs.NewIlAdminButton("post_media_button", "admin/post_input/"+postMedia),
+			msgs.NewIlAdminButton("post_buttons_button", "admin/post_input/"+postButtons),
+		),
+		msgs.NewIlRow(msgs.NewIlAdminButton("post_clone_button", "admin/post_input/"+postClone)),
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("post_preview_button", "admin/post_preview"),
+			msgs.NewIlAdminButton("post_reset_button", "admin/post_reset"),
+		),
+		msgs.NewIlRow(msgs.NewIlAdminButton("post_save_button", "admin/save_post")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("post_add_variant_button", "admin/add_post_variant")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_mailing", "admin/mailing_menu/"+channel)),
+	).Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "post_builder_text",
+		draft.Channel,
+		a.sourceValueText(lang, postTextPreview(draft.Post.Text)),
+		a.postMediaText(lang, draft.Post),
+		a.sourceValueText(lang, html.EscapeString(draft.Post.ButtonsText())),
+		a.postEnabledText(lang, draft.Channel))
+
+	return &markUp, text
+}
+
+func postTextPreview(text string) string {
+	runes := []rune(text)
+	if len(runes) > postTextPreviewLength {
+		return html.EscapeString(string(runes[:postTextPreviewLength])) + "…"
+	}
+
+	return html.EscapeString(text)
+}
+
+func (a *Admin) postMediaText(lang string, post *model.Post) string {
+	var photos, videos int
+	for _, media := range post.Media {
+		if media.Type == model.PostMediaVideo {
+			videos++
+			continue
+		}
+		photos++
+	}
+
+	if photos+videos == 0 {
+		return a.bot.AdminText(lang, "source_no_value")
+	}
+
+	return a.adminFormatText(lang, "post_media_count", photos, videos)
+}
+
+func (a *Admin) postEnabledText(lang string, channel int) string {
+	if a.bot.AdvertisingChoice(channel) == model.AdvertPost {
+		return a.bot.AdminText(lang, "post_enabled_yes")
+	}
+
+	return a.bot.AdminText(lang, "post_enabled_no")
+}
+
+func (a *Admin) sendPostMenu(s *model.Situation) error {
+	markUp, text := a.postMarkUpAndText(s.User.ID)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// PostCommand opens the post builder of the channel with the saved post
+func (a *Admin) PostCommand(s *model.Situation, params route.Params) error {
+	channel := params.Int("channel")
+
+	post := &model.Post{}
+	if saved := a.bot.GetAdvertisingPost(channel); saved != nil {
+		post = saved.Copy()
+	}
+	editPostDraft(s.User.ID, func(draft *postDraft) {
+		draft.Channel, draft.Post = channel, post
+	})
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendPostMenu(s)
+}
+
+// PostMsgCommand returns the admin from the post input back to the builder
+func (a *Admin) PostMsgCommand(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_completed"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	return a.sendPostMenu(s)
+}
+
+func (a *Admin) PostInputCommand(s *model.Situation, params route.Params) error {
+	field := params.String("field")
+	lang := model.AdminLang(s.User.ID)
+
+	var text string
+	switch field {
+	case postText:
+		text = a.bot.AdminText(lang, "post_text_input")
+	case postMedia:
+		text = a.bot.AdminText(lang, "post_media_input")
+	case postButtons:
+		text = a.adminFormatText(lang, "post_buttons_input",
+			html.EscapeString(getPostDraft(s.User.ID).Post.ButtonsText()))
+	case postClone:
+		text = a.bot.AdminText(lang, "post_clone_input")
+	default:
+		return a.invalidCallback(s, errors.Errorf("unknown post field %q", field))
+	}
+
+	if err := a.fsm.Enter(s, statePost, field); err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.sendPostInput(s.User.ID, text)
+}
+
+func (a *Admin) sendPostInput(userID int64, text string) error {
+	lang := model.AdminLang(userID)
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_post")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	return a.msgs.NewParseMarkUpMessage(userID, markUp, text)
+}
+
+// SetPostCommand changes the draft from the admin message. The media and the cloned
+// messages may come as the album, so the admin stays in the input until goes back
+func (a *Admin) SetPostCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+
+	switch s.Params.StateParams.String("field") {
+	case postText:
+		var ok bool
+		editPostDraft(s.User.ID, func(draft *postDraft) {
+			ok = draft.Post.SetText(s.Message)
+		})
+		if !ok {
+			return a.sendErrorInChangeParameter(s.User.ID, "post_text_input")
+		}
+	case postButtons:
+		value := strings.TrimSpace(s.Message.Text)
+		var buttons [][]*model.PostButton
+		if value != "0" {
+			var err error
+			if buttons, err = model.ParsePostButtons(value); err != nil {
+				return a.sendErrorInChangeParameter(s.User.ID, "incorrect_post_buttons")
+			}
+		}
+		editPostDraft(s.User.ID, func(draft *postDraft) {
+			draft.Post.Buttons = buttons
+		})
+	case postMedia:
+		if strings.TrimSpace(s.Message.Text) == "0" {
+			editPostDraft(s.User.ID, func(draft *postDraft) {
+				draft.Post.Media = nil
+			})
+			return a.PostMsgCommand(s)
+		}
+
+		var (
+			err   error
+			count int
+		)
+		editPostDraft(s.User.ID, func(draft *postDraft) {
+			err = draft.Post.AddMedia(s.Message)
+			count = len(draft.Post.Media)
+		})
+		switch err {
+		case nil:
+			return a.msgs.NewParseMessage(s.User.ID, a.adminFormatText(lang, "post_media_added", count))
+		case model.ErrPostAlbumFull:
+			return a.sendErrorInChangeParameter(s.User.ID, "post_album_full")
+		default:
+			return a.sendErrorInChangeParameter(s.User.ID, "post_media_input")
+		}
+	case postClone:
+		var count int
+		editPostDraft(s.User.ID, func(draft *postDraft) {
+			draft.Post.Clone(s.Message)
+			count = len(draft.Post.Media)
+		})
+		return a.msgs.NewParseMessage(s.User.ID, a.adminFormatText(lang, "post_cloned", count))
+	default:
+		return model.ErrCommandNotConverted
+	}
+
+	return a.PostMsgCommand(s)
+}
+
+func (a *Admin) PostResetCommand(s *model.Situation) error {
+	editPostDraft(s.User.ID, func(draft *postDraft) {
+		draft.Post = &model.Post{}
+	})
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "post_reset")
+	return a.sendPostMenu(s)
+}
+
+// PostPreviewCommand sends the draft to the admin the way the users will get it
+func (a *Admin) PostPreviewCommand(s *model.Situation) error {
+	post := getPostDraft(s.User.ID).Post
+	if err := post.Validate(); err != nil {
+		return a.sendPostError(s, err)
+	}
+
+	if err := a.mailing.SendPost(s.User.ID, post); err != nil {
+		return errors.Wrap(err, "send post preview")
+	}
+
+	// the builder goes under the preview
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendPostMenu(s)
+}
+
+// SavePostCommand makes the draft the advertisement of the channel
+func (a *Admin) SavePostCommand(s *model.Situation) error {
+	draft := getPostDraft(s.User.ID)
+	// the draft is lost on the restart, the builder opened after it has no channel
+	if draft.Channel == 0 {
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "post_no_channel")
+	}
+	if err := draft.Post.Validate(); err != nil {
+		return a.sendPostError(s, err)
+	}
+
+	model.AdminSettings.UpdateAdvertPost(s.BotLang, draft.Channel, draft.Post)
+	model.AdminSettings.UpdateAdvertChoice(s.BotLang, draft.Channel, model.AdvertPost)
+	model.SaveAdminSettings()
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "post_saved")
+	return a.sendPostMenu(s)
+}
+
+func (a *Admin) sendPostError(s *model.Situation, err error) error {
+	switch err {
+	case model.ErrPostEmpty:
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "post_empty")
+	case model.ErrPostTextTooLong:
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "post_text_too_long")
+	case model.ErrPostButtonsWithoutText:
+		return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "post_buttons_without_text")
+	default:
+		return err
+	}
+}
@@ -3,12 +3,12 @@ package administrator
 import (
 	"fmt"
 	"strconv"
-	"strings"
 
-	"github.com/Stepan1328/miner-bot/db"
 	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
 	"github.com/bots-empire/base-bot/msgs"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -20,22 +20,22 @@ const (
 )
 
 func (a *Admin) sendRewardSettings(s *model.Situation, reward *model.RewardsGap, resend bool) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin")
+	a.finishInput(s)
 
 	markUp, text := a.rewardsMarkUpAndText(s.User.ID, reward)
 
 	if resend {
-		db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+		a.DeleteOldAdminMsg(s.Context(), s.User.ID)
 
 		msgId, err := a.msgs.NewIDParseMarkUpMessage(s.User.ID, markUp, text)
 		if err != nil {
 			return err
 		}
 
-		db.RdbSetAdminMsgID(s.BotLang, s.User.ID, msgId)
+		a.setAdminMsgID(s.Context(), s.User.ID, msgId)
 	}
 
-	msgID := db.RdbGetAdminMsgID(s.BotLang, s.User.ID)
+	msgID := a.adminMsgID(s.Context(), s.User.ID)
 	err := a.msgs.NewEditMarkUpMessage(s.User.ID, msgID, markUp, text)
 	if err != nil {
 		return err
@@ -49,19 +49,19 @@ func (a *Admin) rewardsMarkUpAndText(userID int64, gap *model.RewardsGap) (*tgbo
 
 	markUp := msgs.NewIlMarkUp(
 		msgs.NewIlRow(
-			msgs.NewIlCustomButton(strconv.Itoa(gap.LeftBorder), "admin/change_rewards_gap?"+changeLeftBorder),
-			msgs.NewIlCustomButton(strconv.Itoa(gap.RightBorder), "admin/change_rewards_gap?"+changeRightBorder),
-			msgs.NewIlCustomButton(strconv.Itoa(gap.Amount), "admin/change_rewards_gap?"+changeAmount),
+			msgs.NewIlCustomButton(strconv.Itoa(gap.LeftBorder), "admin/change_rewards_gap/"+changeLeftBorder),
+			msgs.NewIlCustomButton(strconv.Itoa(gap.RightBorder), "admin/change_rewards_gap/"+changeRightBorder),
+			msgs.NewIlCustomButton(strconv.Itoa(gap.Amount), "admin/change_rewards_gap/"+changeAmount),
 		),
 		msgs.NewIlRow(
-			msgs.NewIlCustomButton("⬅️", "admin/change_gap?-1"),
+			msgs.NewIlCustomButton("⬅️", "admin/change_gap/-1"),
 			msgs.NewIlCustomButton("✅", "admin/apply_rewards"),
-			msgs.NewIlCustomButton("➡️", "admin/change_gap?1"),
+			msgs.NewIlCustomButton("➡️", "admin/change_gap/1"),
 		),
 		msgs.NewIlRow(
-			msgs.NewIlCustomButton("⬅️ Lvl", "admin/change_level?-1"),
+			msgs.NewIlCustomButton("⬅️ Lvl", "admin/change_level/-1"),
 			msgs.NewIlCustomButton(strconv.Itoa(gap.Level), "admin/lvl_info"),
-			msgs.NewIlCustomButton("Lvl ➡️", "admin/change_level?1"),
+			msgs.NewIlCustomButton("Lvl ➡️", "admin/change_level/1"),
 		),
 		msgs.NewIlRow(
 			msgs.NewIlAdminButton("delete_gap", "admin/delete_gap"),
@@ -74,15 +74,22 @@ func (a *Admin) rewardsMarkUpAndText(userID int64, gap *model.RewardsGap) (*tgbo
 	return &markUp, a.bot.AdminText(lang, "change_referral_rewards")
 }
 
-func (a *Admin) ChangeRewardsGapCommand(s *model.Situation) error {
-	command := strings.Split(s.CallbackQuery.Data, "?")[1]
+func (a *Admin) ChangeRewardsGapCommand(s *model.Situation, params route.Params) error {
+	command := params.String("field")
+	switch command {
+	case changeLeftBorder, changeRightBorder, changeAmount:
+	default:
+		return a.invalidCallback(s, errors.Errorf("unknown gap field %q", command))
+	}
 
-	reward, err := RdbGetRewardGap(s.BotLang, s.User.ID)
+	reward, err := a.state.RewardGap(s.Context(), s.User.ID)
 	if err != nil {
 		return err
 	}
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "admin/change_rewards_gap?"+command)
+	if err = a.fsm.Enter(s, stateRewardsGap, command); err != nil {
+		return err
+	}
 
 	var value int
 	switch command {
@@ -108,9 +115,9 @@ func (a *Admin) ChangeRewardsGapCommand(s *model.Situation) error {
 }
 
 func (a *Admin) UpdateRewardsGapCommand(s *model.Situation) error {
-	command := strings.Split(s.Params.Level, "?")[1]
+	command := s.Params.StateParams.String("field")
 
-	reward, err := RdbGetRewardGap(s.BotLang, s.User.ID)
+	reward, err := a.state.RewardGap(s.Context(), s.User.ID)
 	if err != nil {
 		return err
 	}
@@ -144,7 +151,7 @@ func (a *Admin) UpdateRewardsGapCommand(s *model.Situation) error {
 		reward.Amount = newValue
 	}
 
-	if err = RdbSetRewardGap(s.BotLang, s.User.ID, reward); err != nil {
+	if err = a.state.SetRewardGap(s.Context(), s.User.ID, reward); err != nil {
 		return err
 	}
 
@@ -152,7 +159,7 @@ func (a *Admin) UpdateRewardsGapCommand(s *model.Situation) error {
 }
 
 func (a *Admin) ApplyRewardCommand(s *model.Situation) error {
-	reward, err := RdbGetRewardGap(s.BotLang, s.User.ID)
+	reward, err := a.state.RewardGap(s.Context(), s.User.ID)
 	if err != nil {
 		return err
 	}
@@ -161,7 +168,7 @@ func (a *Admin) ApplyRewardCommand(s *model.Situation) error {
 	model.AdminSettings.GetParams(s.BotLang).ReferralReward.UpdateGap(reward)
 
 	reward = model.AdminSettings.GetParams(s.BotLang).ReferralReward.GetGapByCount(reward.Level, leftBorder)
-	if err = RdbSetRewardGap(s.BotLang, s.User.ID, reward); err != nil {
+	if err = a.state.SetRewardGap(s.Context(), s.User.ID, reward); err != nil {
 		return err
 	}
 
@@ -169,15 +176,18 @@ func (a *Admin) ApplyRewardCommand(s *model.Situation) error {
 	return nil
 }
 
-func (a *Admin) ChangeGapCommand(s *model.Situation) error {
-	reward, err := RdbGetRewardGap(s.BotLang, s.User.ID)
+func (a *Admin) ChangeGapCommand(s *model.Situation, params route.Params) error {
+	reward, err := a.state.RewardGap(s.Context(), s.User.ID)
 	if err != nil {
 		return err
 	}
 
 	reward = model.AdminSettings.GetParams(s.BotLang).ReferralReward.GetGapByIndex(reward.Level, reward.Index)
 
-	direction, _ := strconv.Atoi(strings.Split(s.CallbackQuery.Data, "?")[1])
+	direction := params.Int("direction")
+	if direction != -1 && direction != 1 {
+		return a.invalidCallback(s, errors.Errorf("unknown direction %d", direction))
+	}
 
 	if direction == -1 && reward.Index == 1 {
 		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "already_first_gap")
@@ -186,7 +196,7 @@ func (a *Admin) ChangeGapCommand(s *model.Situation) error {
 
 	if direction == 1 && reward.Index == model.AdminSettings.GetParams(s.BotLang).ReferralReward.MaxIndexByLvl(reward.Level) {
 		newGap := model.AdminSettings.GetParams(s.BotLang).ReferralReward.AddGap(reward.Level)
-		err = RdbSetRewardGap(s.BotLang, s.User.ID, newGap)
+		err = a.state.SetRewardGap(s.Context(), s.User.ID, newGap)
 		if err != nil {
 			return err
 		}
@@ -198,7 +208,7 @@ func (a *Admin) ChangeGapCommand(s *model.Situation) error {
 
 	newGap := model.AdminSettings.GetParams(s.BotLang).ReferralReward.GetGapByIndex(reward.Level, reward.Index+direction)
 
-	err = RdbSetRewardGap(s.BotLang, s.User.ID, newGap)
+	err = a.state.SetRewardGap(s.Context(), s.User.ID, newGap)
 	if err != nil {
 		return err
 	}
@@ -206,15 +216,18 @@ func (a *Admin) ChangeGapCommand(s *model.Situation) error {
 	return a.sendRewardSettings(s, newGap, false)
 }
 
-func (a *Admin) ChangeLevelCommand(s *model.Situation) error {
-	reward, err := RdbGetRewardGap(s.BotLang, s.User.ID)
+func (a *Admin) ChangeLevelCommand(s *model.Situation, params route.Params) error {
+	reward, err := a.state.RewardGap(s.Context(), s.User.ID)
 	if err != nil {
 		return err
 	}
 
 	reward = model.AdminSettings.GetParams(s.BotLang).ReferralReward.GetGapByIndex(reward.Level, reward.Index)
 
-	direction, _ := strconv.Atoi(strings.Split(s.CallbackQuery.Data, "?")[1])
+	direction := params.Int("direction")
+	if direction != -1 && direction != 1 {
+		return a.invalidCallback(s, errors.Errorf("unknown direction %d", direction))
+	}
 
 	if direction == -1 && reward.Level == 1 {
 		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "already_first_lvl")
@@ -227,7 +240,7 @@ func (a *Admin) ChangeLevelCommand(s *model.Situation) error {
 		}
 
 		newGap := model.AdminSettings.GetParams(s.BotLang).ReferralReward.AddLvl()
-		err = RdbSetRewardGap(s.BotLang, s.User.ID, newGap)
+		err = a.state.SetRewardGap(s.Context(), s.User.ID, newGap)
 		if err != nil {
 			return err
 		}
@@ -239,7 +252,7 @@ func (a *Admin) ChangeLevelCommand(s *model.Situation) error {
 
 	newGap := model.AdminSettings.GetParams(s.BotLang).ReferralReward.GetGapByIndex(reward.Level+direction, 1)
 
-	err = RdbSetRewardGap(s.BotLang, s.User.ID, newGap)
+	err = a.state.SetRewardGap(s.Context(), s.User.ID, newGap)
 	if err != nil {
 		return err
 	}
@@ -253,7 +266,7 @@ func (a *Admin) LevelInfoCommand(s *model.Situation) error {
 }
 
 func (a *Admin) DeleteGapCommand(s *model.Situation) error {
-	reward, err := RdbGetRewardGap(s.BotLang, s.User.ID)
+	reward, err := a.state.RewardGap(s.Context(), s.User.ID)
 	if err != nil {
 		return err
 	}
@@ -267,7 +280,7 @@ func (a *Admin) DeleteGapCommand(s *model.Situation) error {
 
 	newGap := model.AdminSettings.GetParams(s.BotLang).ReferralReward.DeleteGap(reward.Level, reward.Index)
 
-	err = RdbSetRewardGap(s.BotLang, s.User.ID, newGap)
+	err = a.state.SetRewardGap(s.Context(), s.User.ID, newGap)
 	if err != nil {
 		return err
 	}
@@ -276,7 +289,7 @@ func (a *Admin) DeleteGapCommand(s *model.Situation) error {
 }
 
 func (a *Admin) DeleteLevelCommand(s *model.Situation) error {
-	reward, err := RdbGetRewardGap(s.BotLang, s.User.ID)
+	reward, err := a.state.RewardGap(s.Context(), s.User.ID)
 	if err != nil {
 		return err
 	}
@@ -290,7 +303,7 @@ func (a *Admin) DeleteLevelCommand(s *model.Situation) error {
 
 	newGap := model.AdminSettings.GetParams(s.BotLang).ReferralReward.DeleteLvl(reward.Level)
 
-	err = RdbSetRewardGap(s.BotLang, s.User.ID, newGap)
+	err = a.state.SetRewardGap(s.Context(), s.User.ID, newGap)
 	if err != nil {
 		return err
 	}
@@ -299,7 +312,7 @@ func (a *Admin) DeleteLevelCommand(s *model.Situation) error {
 }
 
 func (a *Admin) ViewLevelCommand(s *model.Situation) error {
-	reward, err := RdbGetRewardGap(s.BotLang, s.User.ID)
+	reward, err := a.state.RewardGap(s.Context(), s.User.ID)
 	if err != nil {
 		return err
 	}
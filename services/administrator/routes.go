@@ -0,0 +1,74 @@
+package administrator
+
+import (
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+)
+
+// the callbacks of the admin panel with the parameters, the others are in AdminCallbackHandlers
+func (a *Admin) handleRoutes(r *route.Router) {
+	r.Handle("admin/set_language/{lang}", a.SetNewLangCommand)
+	r.Handle("admin/source_list/{page:int}", a.SourceListCommand)
+	r.Handle("admin/source_info/{hash}", a.SourceInfoCommand)
+	r.Handle("admin/source_edit/{field}/{hash}", a.SourceEditCommand)
+	r.Handle("admin/source_switch/{hash}", a.SwitchSourceCommand)
+
+	r.Handle("admin/partner_info/{partner:int64}", a.PartnerInfoCommand)
+	r.Handle("admin/partner_payout/{partner:int64}", a.PartnerPayoutCommand)
+	r.Handle("admin/delete_partner/{partner:int64}", a.DeletePartnerCommand)
+	r.Handle("admin/unban/{user:int64}", a.UnbanCommand)
+
+	r.Handle("admin/make_money/{parameter}", a.ChangeParameterCommand)
+	r.Handle("admin/change_click_amount/{operation}", a.ChangeClickAmountButton)
+	r.Handle("admin/change_click_amount/{operation}/{value:int}", a.ChangeClickAmountButton)
+	r.Handle("admin/change_upgrade_amount/{operation}", a.ChangeUpgradeAmountButton)
+	r.Handle("admin/change_upgrade_amount/{operation}/{value:int}", a.ChangeUpgradeAmountButton)
+	r.Handle("admin/change_hash_to_btc_rate/{operation}/{value:int}", a.ChangeHashToBTCRateButton)
+	r.Handle("admin/change_btc_to_currency_rate/{operation}/{value:int}", a.ChangeBTCToCurrencyRateButton)
+	r.Handle("admin/change_top_level/{operation}", a.ChangeTopLevelCommand)
+	r.Handle("admin/change_top_amount/{operation}/{value:int}", a.ChangeTopAmountButtonCommand)
+	r.Handle("admin/change_miner_level/{operation}", a.ChangeMinerLvlButton)
+	r.Handle("admin/change_rewards_gap/{field}", a.ChangeRewardsGapCommand)
+	r.Handle("admin/change_gap/{direction:int}", a.ChangeGapCommand)
+	r.Handle("admin/change_level/{direction:int}", a.ChangeLevelCommand)
+
+	r.Handle("admin/change_advert_chan/{channel:int}", a.AdvertisementChanMenuCommand)
+	r.Handle("admin/turn/{kind}/{channel:int}", a.TurnMenuCommand)
+	r.Handle("admin/change_url_menu/{channel:int}", a.ChangeUrlMenuCommand)
+	r.Handle("admin/change_text_menu/{channel:int}", a.ChangeTextMenuCommand)
+	r.Handle("admin/change_photo_menu/{channel:int}", a.ChangePhotoMenuCommand)
+	r.Handle("admin/change_video_menu/{channel:int}", a.ChangeVideoMenuCommand)
+	r.Handle("admin/change_advert_button_status/{channel:int}", a.ChangeUnderAdvertButtonCommand)
+	r.Handle("admin/mailing_menu/{channel:int}", a.MailingMenuCommand)
+	r.Handle("admin/start_mailing/{channel:int}", a.StartMailingCommand)
+	r.Handle("admin/test_mailing/{channel:int}/{to}", a.TestMailingCommand)
+	r.Handle("admin/confirm_mailing/{channel:int}", a.ConfirmMailingCommand)
+	r.Handle("admin/mailing_report/{report:int64}", a.MailingReportCommand)
+	r.Handle("admin/post/{channel:int}", a.PostCommand)
+	r.Handle("admin/post_input/{field}", a.PostInputCommand)
+	r.Handle("admin/ab_test/{channel:int}", a.ABTestCommand)
+	r.Handle("admin/delete_ab_variant/{index:int}", a.DeleteABVariantCommand)
+	r.Handle("admin/ab_preview/{index:int}", a.ABPreviewCommand)
+	r.Handle("admin/send_ab_winner/{report:int64}", a.SendABWinnerCommand)
+	r.Handle("admin/segment/{channel:int}", a.SegmentCommand)
+	r.Handle("admin/segment_filter/{field}", a.SegmentFilterCommand)
+	r.Handle("admin/load_segment/{name}", a.LoadSegmentCommand)
+	r.Handle("admin/delete_segment/{name}", a.DeleteSegmentCommand)
+	r.Handle("admin/schedule_mailing/{channel:int}", a.ScheduleMailingCommand)
+	r.Handle("admin/mailing_job/{job:int64}", a.MailingJobCommand)
+	r.Handle("admin/edit_mailing_job/{job:int64}", a.EditMailingJobCommand)
+	r.Handle("admin/cancel_mailing_job/{job:int64}", a.CancelMailingJobCommand)
+
+	r.Handle("admin/cohort_period/{period}", a.CohortPeriodCommand)
+	r.Handle("admin/cohort_bot/{bot}", a.CohortBotCommand)
+	r.Handle("admin/update_stats/{bot}", a.UpdateStatsBotCommand)
+
+	r.Handle("admin/export_filter/{field}", a.ExportFilterCommand)
+	r.Handle("admin/export/{table}", a.ExportCommand)
+}
+
+// invalidCallback answers the callback whose data doesn't fit its route
+func (a *Admin) invalidCallback(s *model.Situation, err error) error {
+	a.msgs.SendNotificationToDeveloper(a.bot.BotLang+" // invalid admin callback: "+err.Error(), false)
+	return a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "invalid_callback")
+}
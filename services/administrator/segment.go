@@ -0,0 +1,353 @@
+package administrator
+
+import (
+	"context"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	segmentLang     = "lang"
+	segmentLevel    = "level"
+	segmentBalance  = "balance"
+	segmentRegister = "register"
+	segmentClick    = "click"
+	segmentSource   = "source"
+
+	// the name goes into the callback data limited by 64 bytes
+	segmentNameMaxLength = 32
+)
+
+// segmentDraft is the audience the admin is building for the mailing of the channel
+type segmentDraft struct {
+	Channel int
+	Segment model.Segment
+}
+
+var (
+	segmentDrafts   = make(map[int64]*segmentDraft)
+	segmentDraftsMu sync.Mutex
+)
+
+func getSegmentDraft(userID int64) *segmentDraft {
+	segmentDraftsMu.Lock()
+	defer segmentDraftsMu.Unlock()
+
+	draft, ok := segmentDrafts[userID]
+	if !ok {
+		draft = &segmentDraft{Channel: model.GlobalMailing}
+		segmentDrafts[userID] = draft
+	}
+
+	return draft
+}
+
+func (a *Admin) segmentMarkUpAndText(ctx context.Context, userID int64) (*tgbotapi.InlineKeyboardMarkup, string, error) {
+	lang := model.AdminLang(userID)
+	draft := getSegmentDraft(userID)
+	segment := &draft.Segment
+
+	total, blocked, err := model.CountSegment(ctx, a.bot.GetDataBase(), segment, channelsFromNum(draft.Channel), time.Now())
+	if err != nil {
+		return nil, "", errors.Wrap(err, "count segment")
+	}
+
+	channel := strconv.Itoa(draft.Channel)
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("segment_lang_button", "admin/segment_filter/"+segmentLang),
+			msgs.NewIlAdminButton("segment_level_button", "admin/segment_filter/"+segmentLevel),
+			msgs.NewIlAdminButton("segment_balance_button", "admin/segment_filter/"+segmentBalance),
+		),
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("segment_register_button", "admin/segment_filter/"+segmentRegister),
+			msgs.NewIlAdminButton("segment_click_button", "admin/segment_filter/"+segmentClick),
+		),
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("segment_source_button", "admin/segment_filter/"+segmentSource),
+			msgs.NewIlAdminButton("segment_subscribed_button", "admin/segment_subscribed"),
+		),
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("segment_list_button", "admin/segment_list"),
+			msgs.NewIlAdminButton("segment_save_button", "admin/save_segment"),
+			msgs.NewIlAdminButton("segment_reset_button", "admin/segment_reset"),
+		),
+		msgs.NewIlRow(msgs.NewIlAdminButton("segment_start_button", "admin/segment_mailing")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("segment_schedule_button", "admin/schedule_segment")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_mailing", "admin/mailing_menu/"+channel)),
+	).Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "segment_text",
+		a.sourceValueText(lang, html.EscapeString(segment.Name)),
+		a.segmentChannelText(lang, draft.Channel),
+		a.sourceValueText(lang, html.EscapeString(segment.Lang)),
+		a.sourceValueText(lang, segment.MinerLevel.String()),
+		a.sourceValueText(lang, segment.Balance.String()),
+		a.dateRangeText(lang, segment.RegisterFrom, segment.RegisterTo),
+		a.sourceValueText(lang, segment.ClickDaysAgo.String()),
+		a.sourceValueText(lang, html.EscapeString(segment.Source)),
+		a.segmentSubscribedText(lang, segment.Subscribed),
+		total,
+		blocked,
+		total-blocked)
+
+	return &markUp, text, nil
+}
+
+func (a *Admin) segmentChannelText(lang string, channel int) string {
+	if channel == model.GlobalMailing {
+		return a.bot.AdminText(lang, "segment_all_channels")
+	}
+
+	return strconv.Itoa(channel)
+}
+
+func (a *Admin) segmentSubscribedText(lang string, subscribed *bool) string {
+	switch {
+	case subscribed == nil:
+		return a.bot.AdminText(lang, "source_no_value")
+	case *subscribed:
+		return a.bot.AdminText(lang, "segment_subscribed_yes")
+	default:
+		return a.bot.AdminText(lang, "segment_subscribed_no")
+	}
+}
+
+func (a *Admin) sendSegmentMenu(s *model.Situation) error {
+	markUp, text, err := a.segmentMarkUpAndText(s.Context(), s.User.ID)
+	if err != nil {
+		return err
+	}
+
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// SegmentCommand opens the audience of the mailing of the channel from the mailing menu
+func (a *Admin) SegmentCommand(s *model.Situation, params route.Params) error {
+	getSegmentDraft(s.User.ID).Channel = params.Int("channel")
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendSegmentMenu(s)
+}
+
+// SegmentMsgCommand returns the admin from the filter input back to the segment
+func (a *Admin) SegmentMsgCommand(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	return a.sendSegmentMenu(s)
+}
+
+func (a *Admin) SegmentResetCommand(s *model.Situation) error {
+	draft := getSegmentDraft(s.User.ID)
+	draft.Segment = model.Segment{}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "export_filter_reset")
+	return a.sendSegmentMenu(s)
+}
+
+// SegmentSubscribedCommand switches the filter between any, subscribed and not subscribed users
+func (a *Admin) SegmentSubscribedCommand(s *model.Situation) error {
+	segment := &getSegmentDraft(s.User.ID).Segment
+
+	switch {
+	case segment.Subscribed == nil:
+		subscribed := true
+		segment.Subscribed = &subscribed
+	case *segment.Subscribed:
+		subscribed := false
+		segment.Subscribed = &subscribed
+	default:
+		segment.Subscribed = nil
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendSegmentMenu(s)
+}
+
+func (a *Admin) SegmentFilterCommand(s *model.Situation, params route.Params) error {
+	field := params.String("field")
+	lang := model.AdminLang(s.User.ID)
+
+	var text string
+	switch field {
+	case segmentLang:
+		text = a.bot.AdminText(lang, "export_lang_input")
+	case segmentLevel:
+		text = a.bot.AdminText(lang, "segment_level_input")
+	case segmentBalance:
+		text = a.bot.AdminText(lang, "segment_balance_input")
+	case segmentRegister:
+		text = a.bot.AdminText(lang, "export_date_input")
+	case segmentClick:
+		text = a.bot.AdminText(lang, "segment_click_input")
+	case segmentSource:
+		text = a.bot.AdminText(lang, "cohort_source_input")
+	default:
+		return model.ErrCommandNotConverted
+	}
+
+	if err := a.fsm.Enter(s, stateSegmentFilter, field); err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.sendSegmentInput(s.User.ID, text)
+}
+
+func (a *Admin) sendSegmentInput(userID int64, text string) error {
+	lang := model.AdminLang(userID)
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_segment")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	return a.msgs.NewParseMarkUpMessage(userID, markUp, text)
+}
+
+func (a *Admin) SetSegmentFilterCommand(s *model.Situation) error {
+	segment := &getSegmentDraft(s.User.ID).Segment
+	value := strings.TrimSpace(s.Message.Text)
+	if value == "0" {
+		value = ""
+	}
+
+	field := s.Params.StateParams.String("field")
+	switch field {
+	case segmentLang:
+		segment.Lang = value
+	case segmentLevel, segmentBalance, segmentClick:
+		r, ok := model.ParseRange(value)
+		if !ok {
+			return a.sendErrorInChangeParameter(s.User.ID, "incorrect_segment_range")
+		}
+
+		switch field {
+		case segmentLevel:
+			segment.MinerLevel = r
+		case segmentBalance:
+			segment.Balance = r
+		default:
+			segment.ClickDaysAgo = r
+		}
+	case segmentRegister:
+		from, to, ok := parseExportDates(value)
+		if !ok {
+			return a.sendErrorInChangeParameter(s.User.ID, "incorrect_export_date")
+		}
+		segment.RegisterFrom, segment.RegisterTo = from, to
+	case segmentSource:
+		segment.Source = value
+	}
+
+	return a.returnToSegment(s)
+}
+
+func (a *Admin) returnToSegment(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_completed"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	return a.sendSegmentMenu(s)
+}
+
+func (a *Admin) SaveSegmentCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+	if err := a.fsm.Enter(s, stateSegmentName); err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	return a.sendSegmentInput(s.User.ID, a.bot.AdminText(lang, "segment_name_input"))
+}
+
+// SetSegmentNameCommand saves the segment under the name, the segment with the same name is replaced
+func (a *Admin) SetSegmentNameCommand(s *model.Situation) error {
+	name := strings.TrimSpace(s.Message.Text)
+	if name == "" || len(name) > segmentNameMaxLength || strings.ContainsAny(name, "?/&") {
+		return a.sendErrorInChangeParameter(s.User.ID, "incorrect_segment_name")
+	}
+
+	draft := getSegmentDraft(s.User.ID)
+	draft.Segment.Name = name
+
+	model.AdminSettings.SaveSegment(draft.Segment)
+	model.SaveAdminSettings()
+
+	return a.returnToSegment(s)
+}
+
+func (a *Admin) SegmentListCommand(s *model.Situation) error {
+	lang := model.AdminLang(s.User.ID)
+
+	names := model.AdminSettings.SegmentNames()
+
+	markUp := &msgs.InlineMarkUp{}
+	for _, name := range names {
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton(name, "admin/load_segment/"+name),
+			msgs.NewIlCustomButton("❌", "admin/delete_segment/"+name),
+		))
+	}
+	markUp.Rows = append(markUp.Rows,
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_segment", "admin/segment/"+strconv.Itoa(getSegmentDraft(s.User.ID).Channel))),
+	)
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[lang])
+
+	textKey := "segment_list_text"
+	if len(names) == 0 {
+		textKey = "segment_list_empty"
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, &builtMarkUp, a.bot.AdminText(lang, textKey))
+}
+
+func (a *Admin) LoadSegmentCommand(s *model.Situation, params route.Params) error {
+	name := params.String("name")
+	saved, ok := model.AdminSettings.Segment(name)
+	if !ok {
+		return model.ErrCommandNotConverted
+	}
+
+	getSegmentDraft(s.User.ID).Segment = saved
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendSegmentMenu(s)
+}
+
+func (a *Admin) DeleteSegmentCommand(s *model.Situation, params route.Params) error {
+	name := params.String("name")
+	model.AdminSettings.DeleteSegment(name)
+	model.SaveAdminSettings()
+
+	return a.SegmentListCommand(s)
+}
+
+// SegmentMailingCommand starts the mailing to the active users of the segment
+func (a *Admin) SegmentMailingCommand(s *model.Situation) error {
+	draft := getSegmentDraft(s.User.ID)
+	segment := draft.Segment
+
+	if err := a.startMailing(s.User.ID, &segment, channelsFromNum(draft.Channel)); err != nil {
+		return a.sendMailingError(s, err)
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "mailing_successful")
+	return a.sendSegmentMenu(s)
+}
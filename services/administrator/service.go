@@ -1,22 +1,118 @@
 package administrator
 
 import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/Stepan1328/miner-bot/db"
+	"github.com/Stepan1328/miner-bot/fsm"
 	"github.com/Stepan1328/miner-bot/model"
-	"github.com/bots-empire/base-bot/mailing"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/Stepan1328/miner-bot/services/mailing"
 	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type Admin struct {
-	bot *model.GlobalBot
+	bot    *model.GlobalBot
+	repos  *model.Repositories
+	state  *db.State
+	fsm    *fsm.Machine
+	routes *route.Router
 
 	mailing *mailing.Service
 	msgs    *msgs.Service
+
+	trackers sync.WaitGroup // the goroutines updating the mailing progress
+
+	jobsMu  sync.Mutex // held while the due mailing jobs are started
+	stopped bool
 }
 
-func NewAdminService(bot *model.GlobalBot, mailing *mailing.Service, msgs *msgs.Service) *Admin {
-	return &Admin{
+func NewAdminService(bot *model.GlobalBot, repos *model.Repositories, state *db.State, mailing *mailing.Service, msgs *msgs.Service) *Admin {
+	a := &Admin{
 		bot:     bot,
+		repos:   repos,
+		state:   state,
 		mailing: mailing,
 		msgs:    msgs,
 	}
+
+	a.fsm = fsm.NewMachine(state, db.AdminLevel, a.rejectInput, a.cancelInput)
+	a.fsm.Register(a.states()...)
+
+	a.routes = route.NewRouter(a.invalidCallback)
+	a.handleRoutes(a.routes)
+	return a
+}
+
+// Stop waits for the started check of the mailing jobs and stops the mailing, the
+// progress messages are left as they are and the mailing is resumed after the restart
+func (a *Admin) Stop(ctx context.Context) error {
+	a.jobsMu.Lock()
+	a.stopped = true
+	a.jobsMu.Unlock()
+
+	if err := a.mailing.Stop(ctx); err != nil {
+		return err
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		a.trackers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// the state of the admin panel is saved after the answer is sent,
+// so these helpers only report the failures to the developers
+
+// finishInput ends the input of the admin, the admin panel is back at the resting level
+func (a *Admin) finishInput(s *model.Situation) {
+	if err := a.fsm.Finish(s); err != nil {
+		a.reportStateErr("finish input", err)
+	}
+}
+
+// adminMsgID returns 0 on the failure, so the new message is sent instead of the edited one
+func (a *Admin) adminMsgID(ctx context.Context, userID int64) int {
+	msgID, err := a.state.AdminMsgID(ctx, userID)
+	if err != nil {
+		a.reportStateErr("get admin msg id", err)
+	}
+
+	return msgID
+}
+
+func (a *Admin) setAdminMsgID(ctx context.Context, userID int64, msgID int) {
+	if err := a.state.SetAdminMsgID(ctx, userID, msgID); err != nil {
+		a.reportStateErr("set admin msg id", err)
+	}
+}
+
+// DeleteOldAdminMsg removes the message of the admin panel before the new one is sent
+func (a *Admin) DeleteOldAdminMsg(ctx context.Context, userID int64) {
+	oldMsgID := a.adminMsgID(ctx, userID)
+	if oldMsgID == 0 {
+		return
+	}
+
+	// the message may be already deleted by the admin or too old to delete,
+	// it isn't worth the notification of the developers
+	if _, err := a.bot.Bot.Send(tgbotapi.NewDeleteMessage(userID, oldMsgID)); err != nil {
+		log.Println(err)
+	}
+	a.setAdminMsgID(ctx, userID, 0)
+}
+
+func (a *Admin) reportStateErr(action string, err error) {
+	a.msgs.SendNotificationToDeveloper(a.bot.BotLang+" // failed "+action+": "+err.Error(), false)
 }
@@ -0,0 +1,443 @@
+package administrator
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	sourcesOnPage = 8
+
+	sourceRename   = "rename"
+	sourceNote     = "note"
+	sourceOwner    = "owner"
+	sourceExpire   = "expire"
+	sourceWelcome  = "welcome"
+	sourceBonus    = "bonus"
+	sourceReferral = "referral"
+
+	sourceDateLayout = "02.01.2006"
+	sourceTimeLayout = "02.01.2006 15:04"
+)
+
+func (a *Admin) sourceMenuMarkUpAndText(lang string) (*tgbotapi.InlineKeyboardMarkup, string) {
+	text := a.bot.AdminText(lang, "add_new_source_text")
+
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(msgs.NewIlAdminButton("add_new_source_button", "admin/add_new_source")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("source_list_button", "admin/source_list/0")),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_admin_settings", "admin/admin_setting")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	return &markUp, text
+}
+
+// SourceMenuCommand returns the admin from the source input back to the source menu
+func (a *Admin) SourceMenuCommand(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text := a.sourceMenuMarkUpAndText(model.AdminLang(s.User.ID))
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) SourceListCommand(s *model.Situation, params route.Params) error {
+	page := params.Int("page")
+	lang := model.AdminLang(s.User.ID)
+
+	count, err := a.repos.Links.CountSources(s.Context())
+	if err != nil {
+		return errors.Wrap(err, "count source links")
+	}
+
+	if count == 0 {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "source_list_empty")
+		return nil
+	}
+
+	maxPage := (count - 1) / sourcesOnPage
+	if page < 0 || page > maxPage {
+		page = 0
+	}
+
+	links, err := a.repos.Links.GetSources(s.Context(), page*sourcesOnPage, sourcesOnPage)
+	if err != nil {
+		return errors.Wrap(err, "get source links")
+	}
+
+	markUp := &msgs.InlineMarkUp{}
+	for _, link := range links {
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton(sourceStatusSign(link)+" "+link.Source+" ("+strconv.Itoa(link.Registrations)+")",
+				"admin/source_info/"+link.HashKey),
+		))
+	}
+
+	if maxPage > 0 {
+		markUp.Rows = append(markUp.Rows, msgs.NewIlRow(
+			msgs.NewIlCustomButton("⬅️", "admin/source_list/"+strconv.Itoa((page+maxPage)%(maxPage+1))),
+			msgs.NewIlCustomButton(strconv.Itoa(page+1)+"/"+strconv.Itoa(maxPage+1), "admin/not_clickable"),
+			msgs.NewIlCustomButton("➡️", "admin/source_list/"+strconv.Itoa((page+1)%(maxPage+1))),
+		))
+	}
+
+	markUp.Rows = append(markUp.Rows,
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_sources", "admin/send_advert_source_menu")),
+	)
+	builtMarkUp := markUp.Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "source_list_text", count)
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, &builtMarkUp, text)
+}
+
+func sourceStatusSign(link *model.SourceLink) string {
+	switch {
+	case link.Disabled:
+		return "❌"
+	case link.Expired():
+		return "⌛️"
+	default:
+		return "✅"
+	}
+}
+
+func (a *Admin) SourceInfoCommand(s *model.Situation, params route.Params) error {
+	hash := params.String("hash")
+
+	link, err := a.repos.Links.GetSource(s.Context(), hash)
+	if err != nil {
+		return errors.Wrap(err, "get source link")
+	}
+
+	if link == nil {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "source_not_found")
+		return nil
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	markUp, text := a.sourceInfoMarkUpAndText(s.User.ID, link)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) sourceInfoMarkUpAndText(userID int64, link *model.SourceLink) (*tgbotapi.InlineKeyboardMarkup, string) {
+	lang := model.AdminLang(userID)
+
+	switchButton := msgs.NewIlAdminButton("source_disable_button", "admin/source_switch/"+link.HashKey)
+	if link.Disabled {
+		switchButton = msgs.NewIlAdminButton("source_enable_button", "admin/source_switch/"+link.HashKey)
+	}
+
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("source_rename_button", "admin/source_edit/"+sourceRename+"/"+link.HashKey),
+			msgs.NewIlAdminButton("source_note_button", "admin/source_edit/"+sourceNote+"/"+link.HashKey),
+		),
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("source_owner_button", "admin/source_edit/"+sourceOwner+"/"+link.HashKey),
+			msgs.NewIlAdminButton("source_expire_button", "admin/source_edit/"+sourceExpire+"/"+link.HashKey),
+		),
+		msgs.NewIlRow(
+			msgs.NewIlAdminButton("source_welcome_button", "admin/source_edit/"+sourceWelcome+"/"+link.HashKey),
+			msgs.NewIlAdminButton("source_bonus_button", "admin/source_edit/"+sourceBonus+"/"+link.HashKey),
+		),
+		msgs.NewIlRow(msgs.NewIlAdminButton("source_referral_button", "admin/source_edit/"+sourceReferral+"/"+link.HashKey)),
+		msgs.NewIlRow(switchButton),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_source_list", "admin/source_list/0")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	text := a.adminFormatText(lang, "source_info_text",
+		html.EscapeString(link.Source),
+		model.MakeBotLink(a.bot.BotLink, link.HashKey),
+		a.sourceValueText(lang, html.EscapeString(link.Note)),
+		a.sourceValueText(lang, sourceOwnerText(link.OwnerID)),
+		link.Clicks,
+		link.Registrations,
+		time.Unix(link.CreatedAt, 0).Format(sourceTimeLayout),
+		a.sourceExpireText(lang, link),
+		a.sourceStatusText(lang, link),
+		a.sourceWelcomeText(lang, link),
+		link.BonusHash,
+		a.sourceReferralText(lang, link))
+
+	return &markUp, text
+}
+
+func (a *Admin) sourceWelcomeText(lang string, link *model.SourceLink) string {
+	switch {
+	case !link.HasWelcome():
+		return a.bot.AdminText(lang, "source_no_value")
+	case link.WelcomeMediaType != "":
+		return a.bot.AdminText(lang, "source_welcome_"+link.WelcomeMediaType)
+	default:
+		return a.bot.AdminText(lang, "source_welcome_text")
+	}
+}
+
+func (a *Admin) sourceReferralText(lang string, link *model.SourceLink) string {
+	if len(link.ReferralReward) == 0 {
+		return a.bot.AdminText(lang, "source_referral_global")
+	}
+
+	return "<code>" + link.ReferralReward.String() + "</code>"
+}
+
+func (a *Admin) sourceValueText(lang, value string) string {
+	if value == "" {
+		return a.bot.AdminText(lang, "source_no_value")
+	}
+
+	return value
+}
+
+func sourceOwnerText(ownerID int64) string {
+	if ownerID == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(ownerID, 10)
+}
+
+func (a *Admin) sourceExpireText(lang string, link *model.SourceLink) string {
+	if link.ExpireAt == 0 {
+		return a.bot.AdminText(lang, "source_never_expires")
+	}
+
+	return time.Unix(link.ExpireAt, 0).In(a.bot.Location()).Format(sourceTimeLayout)
+}
+
+func (a *Admin) sourceStatusText(lang string, link *model.SourceLink) string {
+	switch {
+	case link.Disabled:
+		return a.bot.AdminText(lang, "source_status_disabled")
+	case link.Expired():
+		return a.bot.AdminText(lang, "source_status_expired")
+	default:
+		return a.bot.AdminText(lang, "source_status_active")
+	}
+}
+
+func (a *Admin) SwitchSourceCommand(s *model.Situation, params route.Params) error {
+	hash := params.String("hash")
+
+	link, err := a.repos.Links.GetSource(s.Context(), hash)
+	if err != nil {
+		return errors.Wrap(err, "get source link")
+	}
+
+	if link == nil {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "source_not_found")
+		return nil
+	}
+
+	link.Disabled = !link.Disabled
+	if err = a.repos.Links.UpdateSource(s.Context(), link); err != nil {
+		return errors.Wrap(err, "update source link")
+	}
+
+	if link.Disabled {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "source_disabled")
+	} else {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "source_enabled")
+	}
+
+	markUp, text := a.sourceInfoMarkUpAndText(s.User.ID, link)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) SourceEditCommand(s *model.Situation, params route.Params) error {
+	field, hash := params.String("field"), params.String("hash")
+	lang := model.AdminLang(s.User.ID)
+
+	link, err := a.repos.Links.GetSource(s.Context(), hash)
+	if err != nil {
+		return errors.Wrap(err, "get source link")
+	}
+
+	if link == nil {
+		_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "source_not_found")
+		return nil
+	}
+
+	var text string
+	switch field {
+	case sourceRename:
+		text = a.adminFormatText(lang, "source_rename_input", html.EscapeString(link.Source))
+	case sourceNote:
+		text = a.adminFormatText(lang, "source_note_input", a.sourceValueText(lang, html.EscapeString(link.Note)))
+	case sourceOwner:
+		text = a.adminFormatText(lang, "source_owner_input", a.sourceValueText(lang, sourceOwnerText(link.OwnerID)))
+	case sourceExpire:
+		text = a.adminFormatText(lang, "source_expire_input", a.sourceExpireText(lang, link))
+	case sourceWelcome:
+		text = a.adminFormatText(lang, "source_welcome_input", a.sourceWelcomeText(lang, link))
+	case sourceBonus:
+		text = a.adminFormatText(lang, "source_bonus_input", link.BonusHash)
+	case sourceReferral:
+		text = a.adminFormatText(lang, "source_referral_input",
+			a.sourceReferralText(lang, link),
+			model.AdminSettings.GetParams(s.BotLang).ReferralReward.String())
+	default:
+		return model.ErrCommandNotConverted
+	}
+
+	if err = a.fsm.Enter(s, stateEditSource, field, hash); err != nil {
+		return err
+	}
+
+	markUp := msgs.NewMarkUp(
+		msgs.NewRow(msgs.NewAdminButton("back_to_sources")),
+		msgs.NewRow(msgs.NewAdminButton("admin_log_out_text")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "type_the_text")
+	if err = a.msgs.NewParseMarkUpMessage(s.User.ID, markUp, text); err != nil {
+		return err
+	}
+
+	if field == sourceWelcome && link.HasWelcome() {
+		return a.msgs.SendMsgToUser(link.WelcomeMessage(s.User.ID), s.User.ID)
+	}
+
+	return nil
+}
+
+func (a *Admin) EditSourceCommand(s *model.Situation) error {
+	field, hash := s.Params.StateParams.String("field"), s.Params.StateParams.String("hash")
+
+	link, err := a.repos.Links.GetSource(s.Context(), hash)
+	if err != nil {
+		return errors.Wrap(err, "get source link")
+	}
+
+	if link == nil {
+		return a.sendErrorInChangeParameter(s.User.ID, "source_not_found")
+	}
+
+	value := strings.TrimSpace(s.Message.Text)
+	switch field {
+	case sourceRename:
+		if value == "" {
+			return a.sendErrorInChangeParameter(s.User.ID, "incorrect_value")
+		}
+		link.Source = value
+	case sourceNote:
+		if value == "0" {
+			value = ""
+		}
+		link.Note = value
+	case sourceOwner:
+		ownerID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || ownerID < 0 {
+			return a.sendErrorInChangeParameter(s.User.ID, "incorrect_source_owner")
+		}
+		link.OwnerID = ownerID
+	case sourceExpire:
+		expireAt, ok := parseSourceExpire(value, a.bot.Location())
+		if !ok {
+			return a.sendErrorInChangeParameter(s.User.ID, "incorrect_source_expire")
+		}
+		link.ExpireAt = expireAt
+	case sourceWelcome:
+		if !setSourceWelcome(link, s.Message) {
+			return a.sendErrorInChangeParameter(s.User.ID, "incorrect_source_welcome")
+		}
+	case sourceBonus:
+		bonus, err := strconv.Atoi(value)
+		if err != nil || bonus < 0 {
+			return a.sendErrorInChangeParameter(s.User.ID, "incorrect_value")
+		}
+		link.BonusHash = bonus
+	case sourceReferral:
+		if value == "0" {
+			link.ReferralReward = nil
+			break
+		}
+
+		reward, err := model.ParseRewardsMatrix(value)
+		if err != nil {
+			return a.sendErrorInChangeParameter(s.User.ID, "incorrect_source_referral")
+		}
+		link.ReferralReward = reward
+	}
+
+	if err = a.repos.Links.UpdateSource(s.Context(), link); err != nil {
+		return errors.Wrap(err, "update source link")
+	}
+
+	if err = a.setAdminBackButton(s.User.ID, "operation_completed"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+	a.finishInput(s)
+
+	markUp, text := a.sourceInfoMarkUpAndText(s.User.ID, link)
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+// setSourceWelcome takes the welcome from the text, photo or video message,
+// 0 removes the welcome
+func setSourceWelcome(link *model.SourceLink, message *tgbotapi.Message) bool {
+	switch {
+	case len(message.Photo) != 0:
+		link.WelcomeText = message.Caption
+		link.WelcomeMediaType = model.WelcomeMediaPhoto
+		link.WelcomeMedia = message.Photo[len(message.Photo)-1].FileID
+	case message.Video != nil:
+		link.WelcomeText = message.Caption
+		link.WelcomeMediaType = model.WelcomeMediaVideo
+		link.WelcomeMedia = message.Video.FileID
+	case strings.TrimSpace(message.Text) == "0":
+		link.WelcomeText = ""
+		link.WelcomeMediaType = ""
+		link.WelcomeMedia = ""
+	case strings.TrimSpace(message.Text) != "":
+		link.WelcomeText = message.Text
+		link.WelcomeMediaType = ""
+		link.WelcomeMedia = ""
+	default:
+		return false
+	}
+
+	return true
+}
+
+// parseSourceExpire accepts the number of days the link stays alive,
+// the last day in sourceDateLayout in the timezone of the bot or 0 to remove the expiry
+func parseSourceExpire(value string, location *time.Location) (int64, bool) {
+	days, err := strconv.Atoi(value)
+	if err == nil {
+		if days < 0 {
+			return 0, false
+		}
+		if days == 0 {
+			return 0, true
+		}
+
+		return time.Now().AddDate(0, 0, days).Unix(), true
+	}
+
+	date, err := time.ParseInLocation(sourceDateLayout, value, location)
+	if err != nil {
+		return 0, false
+	}
+
+	expireAt := date.AddDate(0, 0, 1)
+	if expireAt.Before(time.Now()) {
+		return 0, false
+	}
+
+	return expireAt.Unix(), true
+}
@@ -0,0 +1,178 @@
+package administrator
+
+import (
+	"time"
+
+	"github.com/Stepan1328/miner-bot/fsm"
+	"github.com/Stepan1328/miner-bot/model"
+)
+
+// the inputs of the admin panel, the resting state is admin
+const (
+	stateDeleteAdmin   fsm.State = "admin/delete_admin"
+	stateMakeMoney     fsm.State = "admin/make_money"
+	stateSetCount      fsm.State = "admin/set_count"
+	stateChangeAdvert  fsm.State = "admin/change_text_url"
+	stateNewSource     fsm.State = "admin/get_new_source"
+	stateEditSource    fsm.State = "admin/edit_source"
+	stateNewPartner    fsm.State = "admin/new_partner"
+	statePartnerPayout fsm.State = "admin/set_partner_payout"
+	stateBanUser       fsm.State = "admin/ban_user"
+	stateCohortSource  fsm.State = "admin/set_cohort_source"
+	stateSegmentFilter fsm.State = "admin/set_segment_filter"
+	stateSegmentName   fsm.State = "admin/set_segment_name"
+	statePost          fsm.State = "admin/set_post"
+	stateABPercents    fsm.State = "admin/set_ab_percents"
+	stateNewMailingJob fsm.State = "admin/new_mailing_job"
+	stateMailingJob    fsm.State = "admin/set_mailing_job"
+	stateExportFilter  fsm.State = "admin/set_export_filter"
+	stateRewardsGap    fsm.State = "admin/change_rewards_gap"
+
+	// the post is composed of several messages, the admin may need more time for it
+	postTimeout = 24 * time.Hour
+)
+
+func (a *Admin) states() []*fsm.Definition {
+	return []*fsm.Definition{
+		{
+			Name:     stateDeleteAdmin,
+			Validate: fsm.Int64(1, "incorrect_admin_id_text"),
+			Handler:  a.RemoveAdminCommand,
+		},
+		{
+			Name:     stateMakeMoney,
+			Params:   []string{"parameter"},
+			Validate: makeMoneyParameter,
+			Handler:  a.UpdateParameterCommand,
+		},
+		{
+			Name:     stateSetCount,
+			Params:   []string{"count"},
+			Validate: fsm.Int(1, "need_positive_number"),
+			Handler:  a.ChangeMinerCountCommand,
+		},
+		{
+			Name:    stateChangeAdvert,
+			Params:  []string{"field", "channel"},
+			Handler: a.SetNewTextUrlCommand,
+		},
+		{
+			Name:     stateNewSource,
+			Validate: fsm.Text("incorrect_value"),
+			Handler:  a.GetNewSourceCommand,
+		},
+		{
+			Name:    stateEditSource,
+			Params:  []string{"field", "hash"},
+			Handler: a.EditSourceCommand,
+		},
+		{
+			Name:     stateNewPartner,
+			Validate: fsm.Int64(1, "incorrect_partner_id"),
+			Handler:  a.NewPartnerCommand,
+		},
+		{
+			Name:    statePartnerPayout,
+			Params:  []string{"partner"},
+			Handler: a.SetPartnerPayoutCommand,
+		},
+		{
+			Name:     stateBanUser,
+			Validate: fsm.Int64(1, "incorrect_ban_id"),
+			Handler:  a.NewBanCommand,
+		},
+		{
+			Name:     stateCohortSource,
+			Validate: fsm.Text("incorrect_value"),
+			Handler:  a.SetCohortSourceCommand,
+		},
+		{
+			Name:     stateSegmentFilter,
+			Params:   []string{"field"},
+			Validate: fsm.Text("incorrect_value"),
+			Handler:  a.SetSegmentFilterCommand,
+		},
+		{
+			Name:     stateSegmentName,
+			Validate: fsm.Text("incorrect_segment_name"),
+			Handler:  a.SetSegmentNameCommand,
+		},
+		{
+			Name:    statePost,
+			Params:  []string{"field"},
+			Handler: a.SetPostCommand,
+			Timeout: postTimeout,
+		},
+		{
+			Name:     stateABPercents,
+			Validate: fsm.Text("incorrect_ab_percents"),
+			Handler:  a.SetABPercentsCommand,
+		},
+		{
+			Name:     stateNewMailingJob,
+			Params:   []string{"channel", "segment"},
+			Validate: fsm.Text("incorrect_mailing_schedule"),
+			Handler:  a.NewMailingJobCommand,
+		},
+		{
+			Name:     stateMailingJob,
+			Params:   []string{"job"},
+			Validate: fsm.Text("incorrect_mailing_schedule"),
+			Handler:  a.SetMailingJobCommand,
+		},
+		{
+			Name:     stateExportFilter,
+			Params:   []string{"field"},
+			Validate: fsm.Text("incorrect_value"),
+			Handler:  a.SetExportFilterCommand,
+		},
+		{
+			Name:     stateRewardsGap,
+			Params:   []string{"field"},
+			Validate: fsm.Text("incorrect_value"),
+			Handler:  a.UpdateRewardsGapCommand,
+		},
+	}
+}
+
+// makeMoneyParameter accepts any currency name and the positive number for the other parameters
+func makeMoneyParameter(s *model.Situation) error {
+	if s.Params.StateParams.String("parameter") == currencyType {
+		return fsm.Text("incorrect_value")(s)
+	}
+
+	return fsm.Int(1, "incorrect_make_money_change_input")(s)
+}
+
+func (a *Admin) rejectInput(s *model.Situation, inputErr *fsm.InputError) error {
+	lang := model.AdminLang(s.User.ID)
+	return a.msgs.NewParseMessage(s.User.ID, a.adminFormatText(lang, inputErr.Key, inputErr.Args...))
+}
+
+// cancelInput shows the admin menu after the input is canceled
+func (a *Admin) cancelInput(s *model.Situation) error {
+	if err := a.setAdminBackButton(s.User.ID, "operation_canceled"); err != nil {
+		return err
+	}
+	a.DeleteOldAdminMsg(s.Context(), s.User.ID)
+
+	return a.AdminMenuCommand(s)
+}
+
+// DialogMenu shows the menu of the input the admin is kept in, false is returned when there is none
+func (a *Admin) DialogMenu(s *model.Situation) (bool, error) {
+	if !ContainsInAdmin(s.User.ID) {
+		return false, nil
+	}
+
+	return a.fsm.Menu(s)
+}
+
+// CancelDialog ends the input of the admin, false is returned when the admin is not in it
+func (a *Admin) CancelDialog(s *model.Situation) (bool, error) {
+	if !ContainsInAdmin(s.User.ID) {
+		return false, nil
+	}
+
+	return a.fsm.Cancel(s)
+}
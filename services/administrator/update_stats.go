@@ -0,0 +1,156 @@
+package administrator
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	updateStatsHours    = 24
+	updateStatsBarWidth = 10
+	updateStatsCommands = 10
+)
+
+func (a *Admin) UpdateStatsCommand(s *model.Situation) error {
+	return a.updateStats(s, s.BotLang)
+}
+
+// UpdateStatsBotCommand shows the update statistics of the other bot
+func (a *Admin) UpdateStatsBotCommand(s *model.Situation, params route.Params) error {
+	botLang := params.String("bot")
+	if _, ok := model.Bots[botLang]; !ok {
+		return a.invalidCallback(s, errors.Errorf("unknown bot %q", botLang))
+	}
+
+	return a.updateStats(s, botLang)
+}
+
+func (a *Admin) updateStats(s *model.Situation, botLang string) error {
+	markUp, text, err := a.updateStatsMarkUpAndText(s.Context(), s.User.ID, botLang)
+	if err != nil {
+		return err
+	}
+
+	_ = a.msgs.SendAdminAnswerCallback(s.CallbackQuery, "make_a_choice")
+	return a.sendMsgAdnAnswerCallback(s, markUp, text)
+}
+
+func (a *Admin) updateStatsMarkUpAndText(ctx context.Context, userID int64, botLang string) (*tgbotapi.InlineKeyboardMarkup, string, error) {
+	lang := model.AdminLang(userID)
+
+	// the pending counters are flushed first to show the current hour up to date
+	if err := model.Bots[botLang].UpdateStatistic.Flush(ctx); err != nil {
+		return nil, "", errors.Wrap(err, "flush update statistic")
+	}
+
+	statistics, err := model.GetHourStatistics(ctx, botLang, updateStatsHours, time.Now())
+	if err != nil {
+		return nil, "", errors.Wrap(err, "get hour statistics")
+	}
+
+	botLangs := make([]string, 0, len(model.Bots))
+	for bot := range model.Bots {
+		botLangs = append(botLangs, bot)
+	}
+	sort.Strings(botLangs)
+
+	var botRow msgs.InlineRow
+	for _, bot := range botLangs {
+		text := bot
+		if bot == botLang {
+			text = "• " + bot + " •"
+		}
+		botRow.Buttons = append(botRow.Buttons, msgs.NewIlCustomButton(text, "admin/update_stats/"+bot))
+	}
+
+	markUp := msgs.NewIlMarkUp(
+		botRow,
+		msgs.NewIlRow(msgs.NewIlAdminButton("update_stats_refresh_button", "admin/update_stats/"+botLang)),
+		msgs.NewIlRow(msgs.NewIlAdminButton("back_to_main_menu", "admin/send_menu")),
+	).Build(a.bot.AdminLibrary[lang])
+
+	var total, messages, callbacks int64
+	commands := make(map[string]int64)
+	for _, hour := range statistics {
+		total += hour.Counters[model.UpdateTotal]
+		messages += hour.Counters[model.UpdateMessage]
+		callbacks += hour.Counters[model.UpdateCallback]
+		for command, count := range hour.Commands() {
+			commands[command] += count
+		}
+	}
+
+	text := a.adminFormatText(lang, "update_stats_text",
+		botLang,
+		total,
+		messages,
+		callbacks,
+		updateStatsTable(statistics),
+		topCommandsText(commands))
+
+	return &markUp, text, nil
+}
+
+// updateStatsTable formats the hourly buckets as the monospace table with bars of the total updates
+func updateStatsTable(statistics []*model.HourStatistic) string {
+	var peak int64
+	for _, hour := range statistics {
+		if hour.Counters[model.UpdateTotal] > peak {
+			peak = hour.Counters[model.UpdateTotal]
+		}
+	}
+
+	var table strings.Builder
+	table.WriteString(fmt.Sprintf("%-5s %6s %6s %6s", "hour", "total", "msg", "call"))
+	for _, hour := range statistics {
+		count := hour.Counters[model.UpdateTotal]
+
+		var bar int
+		if peak != 0 {
+			bar = int(count * updateStatsBarWidth / peak)
+		}
+
+		table.WriteString(fmt.Sprintf("\n%-5s %6d %6d %6d %s",
+			hour.Hour.Format("15:04"),
+			count,
+			hour.Counters[model.UpdateMessage],
+			hour.Counters[model.UpdateCallback],
+			strings.Repeat("█", bar)))
+	}
+
+	return "<pre>" + table.String() + "</pre>"
+}
+
+func topCommandsText(commands map[string]int64) string {
+	names := make([]string, 0, len(commands))
+	for command := range commands {
+		names = append(names, command)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if commands[names[i]] != commands[names[j]] {
+			return commands[names[i]] > commands[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	if len(names) > updateStatsCommands {
+		names = names[:updateStatsCommands]
+	}
+
+	lines := make([]string, len(names))
+	for i, command := range names {
+		lines[i] = fmt.Sprintf("%d. %s — %d", i+1, html.EscapeString(command), commands[command])
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -1,7 +1,7 @@
 package auth
 
 import (
-	"database/sql"
+	"context"
 	"math/rand"
 	"strings"
 	"time"
@@ -12,32 +12,29 @@ import (
 	"github.com/pkg/errors"
 )
 
-func (a *Auth) CheckingTheUser(message *tgbotapi.Message) (*model.User, error) {
-	dataBase := a.bot.GetDataBase()
-	rows, err := dataBase.Query(`
-SELECT * FROM users 
-	WHERE id = ?;`,
-		message.From.ID)
-	if err != nil {
-		return nil, errors.Wrap(err, "get user")
-	}
-
-	users, err := a.ReadUsers(rows)
-	if err != nil {
-		return nil, errors.Wrap(err, "read user")
-	}
-
-	switch len(users) {
-	case 0:
-		user := createSimpleUser(a.bot.LanguageInBot[0], message)
+func (a *Auth) CheckingTheUser(ctx context.Context, message *tgbotapi.Message) (*model.User, error) {
+	user, err := a.repos.Users.Get(ctx, message.From.ID)
+	switch err {
+	case model.ErrUserNotFound:
+		user = createSimpleUser(a.bot.LanguageInBot[0], message)
 		if len(a.bot.LanguageInBot) > 1 && !administrator.ContainsInAdmin(message.From.ID) {
 			user.Language = "not_defined" // TODO: refactor
 		}
-		referralID := a.pullReferralID(message)
-		if err := a.addNewUser(user, a.bot.LanguageInBot[0], referralID); err != nil {
+		referralID, source := a.pullReferralID(ctx, message)
+		if source != nil {
+			user.BalanceHash += source.BonusHash
+		}
+
+		if err := a.addNewUser(ctx, user, a.bot.LanguageInBot[0], referralID); err != nil {
 			return nil, errors.Wrap(err, "add new user")
 		}
 
+		if source != nil && source.HasWelcome() {
+			if err := a.msgs.SendMsgToUser(source.WelcomeMessage(user.ID), user.ID); err != nil {
+				a.msgs.SendNotificationToDeveloper("some err in send source welcome: "+err.Error(), false)
+			}
+		}
+
 		model.TotalIncome.WithLabelValues(
 			a.bot.BotLink,
 			a.bot.BotLang,
@@ -47,70 +44,47 @@ SELECT * FROM users
 			return user, model.ErrNotSelectedLanguage
 		}
 		return user, nil
-	case 1:
-		if users[0].Language == "not_defined" {
-			return users[0], model.ErrNotSelectedLanguage
+	case nil:
+		a.countSourceClick(ctx, message)
+
+		if user.Language == "not_defined" {
+			return user, model.ErrNotSelectedLanguage
 		}
-		return users[0], nil
+		return user, nil
 	default:
-		return nil, model.ErrFoundTwoUsers
+		return nil, errors.Wrap(err, "get user")
 	}
 }
 
-func (a *Auth) SetStartLanguage(callback *tgbotapi.CallbackQuery) error {
-	data := strings.Split(callback.Data, "?")[1]
-	dataBase := a.bot.GetDataBase()
-	_, err := dataBase.Exec("UPDATE users SET lang = ? WHERE id = ?", data, callback.From.ID)
-	if err != nil {
-		return err
-	}
-	return nil
+func (a *Auth) SetStartLanguage(ctx context.Context, userID int64, lang string) error {
+	return a.repos.Users.SetLanguage(ctx, userID, lang)
 }
 
-func (a *Auth) addNewUser(user *model.User, botLang string, referralID int64) error {
+func (a *Auth) addNewUser(ctx context.Context, user *model.User, botLang string, referralID int64) error {
 	if referralID == user.ID {
 		referralID = 0
 	}
 
-	dataBase := a.bot.GetDataBase()
-	rows, err := dataBase.Query(`
-INSERT INTO users
-	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`,
-		user.ID,
-		user.Balance,
-		user.BalanceHash,
-		user.BalanceBTC,
-		user.MiningToday,
-		user.LastClick,
-		user.MinerLevel,
-		user.FatherID,
-		user.AllReferrals,
-		user.AdvertChannel,
-		user.TakeBonus,
-		user.Language,
-		user.RegisterTime,
-		user.MinWithdrawal,
-		user.FirstWithdrawal,
-		user.Status)
-	if err != nil {
-		return errors.Wrap(err, "query failed")
+	if err := a.repos.Users.Create(ctx, user); err != nil {
+		return errors.Wrap(err, "create user")
 	}
-	_ = rows.Close()
 
 	if referralID == 0 {
 		return nil
 	}
 
-	return a.referralRewardSystem(botLang, referralID, 1)
+	return a.referralRewardSystem(ctx, botLang, referralID, 1)
 }
 
-func (a *Auth) pullReferralID(message *tgbotapi.Message) int64 {
+// pullReferralID returns the referral of the new user and the active
+// source link he came from, if any
+func (a *Auth) pullReferralID(ctx context.Context, message *tgbotapi.Message) (int64, *model.SourceLink) {
 	readParams := strings.Split(message.Text, " ")
 	if len(readParams) < 2 {
-		return 0
+		return 0, nil
 	}
 
-	linkInfo, err := model.DecodeLink(a.bot.GetDataBase(), readParams[1])
+	linkInfo, err := a.repos.Links.Get(ctx, readParams[1])
 	if err != nil || linkInfo == nil {
 		if err != nil {
 			a.msgs.SendNotificationToDeveloper("some err in decode link: "+err.Error(), false)
@@ -122,13 +96,40 @@ func (a *Auth) pullReferralID(message *tgbotapi.Message) int64 {
 			"unknown",
 		).Inc()
 
-		return 0
+		return 0, nil
+	}
+
+	source, err := a.checkSourceLink(ctx, linkInfo)
+	if err != nil {
+		a.msgs.SendNotificationToDeveloper("some err in check source link: "+err.Error(), false)
 	}
 
-	if err = a.saveIncomeUser(&model.IncomeInfo{
+	if source != nil {
+		if !source.Active() {
+			model.IncomeBySource.WithLabelValues(
+				a.bot.BotLink,
+				a.bot.BotLang,
+				"inactive",
+			).Inc()
+
+			return 0, nil
+		}
+
+		linkInfo.Source = source.Source
+		if err = a.repos.Links.IncreaseRegistrations(ctx, source.HashKey); err != nil {
+			a.msgs.SendNotificationToDeveloper("some err in increase source registrations: "+err.Error(), false)
+		}
+	}
+
+	info := &model.IncomeInfo{
 		UserID: message.From.ID,
 		Source: linkInfo.Source,
-	}); err != nil {
+	}
+	if source != nil {
+		info.SourceHash = source.HashKey
+	}
+
+	if err = a.repos.Income.Save(ctx, info); err != nil {
 		a.msgs.SendNotificationToDeveloper("some error in save income info: "+err.Error(), false)
 	}
 
@@ -138,80 +139,63 @@ func (a *Auth) pullReferralID(message *tgbotapi.Message) int64 {
 		linkInfo.Source,
 	).Inc()
 
-	return linkInfo.ReferralID
+	return linkInfo.ReferralID, source
 }
 
-func createSimpleUser(lang string, message *tgbotapi.Message) *model.User {
-	return &model.User{
-		ID:            message.From.ID,
-		MinerLevel:    1,
-		Language:      lang,
-		AdvertChannel: rand.Intn(3) + 1,
-		RegisterTime:  time.Now().Unix(),
-		Status:        "active",
+func (a *Auth) countSourceClick(ctx context.Context, message *tgbotapi.Message) {
+	readParams := strings.Split(message.Text, " ")
+	if len(readParams) < 2 || readParams[0] != "/start" {
+		return
 	}
-}
 
-func (a *Auth) saveIncomeUser(info *model.IncomeInfo) error {
-	_, err := a.bot.GetDataBase().Exec(`
-INSERT INTO 
-	income_info(user_id, source)
-VALUES(?, ?);`,
-		info.UserID,
-		info.Source)
-	if err != nil {
-		return errors.Wrap(err, "failed insert income info")
+	linkInfo, err := a.repos.Links.Get(ctx, readParams[1])
+	if err != nil || linkInfo == nil {
+		return
 	}
 
-	return nil
+	if _, err = a.checkSourceLink(ctx, linkInfo); err != nil {
+		a.msgs.SendNotificationToDeveloper("some err in check source link: "+err.Error(), false)
+	}
 }
 
-func (a *Auth) GetUser(id int64) (*model.User, error) {
-	dataBase := a.bot.GetDataBase()
-	rows, err := dataBase.Query(`
-SELECT * FROM users
-	WHERE id = ?;`,
-		id)
-	if err != nil {
-		return nil, err
+// checkSourceLink counts the click on the admin source link and returns its
+// management info, nil is returned for the referral links of users
+func (a *Auth) checkSourceLink(ctx context.Context, linkInfo *model.ReferralLinkInfo) (*model.SourceLink, error) {
+	if linkInfo.ReferralID != 0 {
+		return nil, nil
 	}
 
-	users, err := a.ReadUsers(rows)
-	if err != nil || len(users) == 0 {
-		return nil, model.ErrUserNotFound
+	source, err := a.repos.Links.GetSource(ctx, linkInfo.HashKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "get source link")
 	}
-	return users[0], nil
-}
 
-func (a *Auth) ReadUsers(rows *sql.Rows) ([]*model.User, error) {
-	defer rows.Close()
-
-	var users []*model.User
-
-	for rows.Next() {
-		user := &model.User{}
-
-		if err := rows.Scan(&user.ID,
-			&user.Balance,
-			&user.BalanceHash,
-			&user.BalanceBTC,
-			&user.MiningToday,
-			&user.LastClick,
-			&user.MinerLevel,
-			&user.FatherID,
-			&user.AllReferrals,
-			&user.AdvertChannel,
-			&user.TakeBonus,
-			&user.Language,
-			&user.RegisterTime,
-			&user.MinWithdrawal,
-			&user.FirstWithdrawal,
-			&user.Status); err != nil {
-			a.msgs.SendNotificationToDeveloper(errors.Wrap(err, "failed to scan row").Error(), false)
+	if source == nil {
+		source, err = model.AdoptSourceLink(ctx, a.repos.Links, linkInfo)
+		if err != nil {
+			return nil, errors.Wrap(err, "adopt source link")
 		}
+	}
 
-		users = append(users, user)
+	if err = a.repos.Links.IncreaseClicks(ctx, source.HashKey); err != nil {
+		return nil, errors.Wrap(err, "increase source clicks")
 	}
+	source.Clicks++
+
+	return source, nil
+}
+
+func createSimpleUser(lang string, message *tgbotapi.Message) *model.User {
+	return &model.User{
+		ID:            message.From.ID,
+		MinerLevel:    1,
+		Language:      lang,
+		AdvertChannel: rand.Intn(3) + 1,
+		RegisterTime:  time.Now().Unix(),
+		Status:        "active",
+	}
+}
 
-	return users, nil
+func (a *Auth) GetUser(ctx context.Context, id int64) (*model.User, error) {
+	return a.repos.Users.Get(ctx, id)
 }
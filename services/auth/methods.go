@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"database/sql"
 	"strconv"
 	"strings"
 	"time"
@@ -9,7 +8,6 @@ import (
 	"github.com/Stepan1328/miner-bot/model"
 	"github.com/bots-empire/base-bot/msgs"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"github.com/pkg/errors"
 )
 
 const (
@@ -18,7 +16,7 @@ const (
 
 func (a *Auth) MakeClick(s *model.Situation) (error, bool) {
 	if time.Now().Unix()/86400 > s.User.LastClick/86400 {
-		if err := resetTodayMiningCounter(s, a.bot.DataBase); err != nil {
+		if err := a.resetTodayMiningCounter(s); err != nil {
 			return err, false
 		}
 	}
@@ -30,23 +28,11 @@ func (a *Auth) MakeClick(s *model.Situation) (error, bool) {
 	return a.increaseBalanceAfterClick(s), false
 }
 
-func resetTodayMiningCounter(s *model.Situation, dataBase *sql.DB) error {
+func (a *Auth) resetTodayMiningCounter(s *model.Situation) error {
 	s.User.MiningToday = 0
 	s.User.LastClick = time.Now().Unix()
 
-	rows, err := dataBase.Query(`
-UPDATE users SET
-      mining_today = 0, 
-	last_click = ? 
-WHERE id = ?;`,
-		s.User.LastClick,
-		s.User.ID)
-	if err != nil {
-		return errors.Wrap(err, "query failed")
-	}
-	rows.Close()
-
-	return nil
+	return a.repos.Users.ResetMiningToday(s.Context(), s.User.ID, s.User.LastClick)
 }
 
 func (a *Auth) reachedMaxAmountPerDay(s *model.Situation) error {
@@ -66,21 +52,7 @@ func (a *Auth) increaseBalanceAfterClick(s *model.Situation) error {
 	s.User.MiningToday++
 	s.User.LastClick = time.Now().Unix()
 
-	dataBase := a.bot.GetDataBase()
-	_, err := dataBase.Exec(`
-UPDATE users 
-	SET balance_hash = balance_hash + ?, 
-	    mining_today = mining_today + 1,
-	    last_click = ?
-WHERE id = ?;`,
-		getClickAmount(s.BotLang, s.User.MinerLevel),
-		s.User.LastClick,
-		s.User.ID)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return a.repos.Users.AddClick(s.Context(), s.User.ID, getClickAmount(s.BotLang, s.User.MinerLevel), s.User.LastClick)
 }
 
 func getClickAmount(botLang string, minerLevel int8) int {
@@ -101,15 +73,7 @@ func (a *Auth) ChangeHashToBTC(s *model.Situation) (error, float64) {
 	clearAmount := amountBTC * model.AdminSettings.GetParams(s.BotLang).ExchangeHashToBTC
 	amountToChange := oneSatoshi * float64(amountBTC)
 
-	_, err = a.bot.GetDataBase().Exec(`
-UPDATE users 
-	SET balance_hash = balance_hash - ?, 
-	    balance_btc = balance_btc + ?
-WHERE id = ?;`,
-		clearAmount,
-		amountToChange,
-		s.User.ID)
-	if err != nil {
+	if err = a.repos.Users.ExchangeHashToBTC(s.Context(), s.User.ID, clearAmount, amountToChange); err != nil {
 		return err, 0
 	}
 
@@ -132,16 +96,7 @@ func (a *Auth) ChangeBTCToCurrency(s *model.Situation) (error, int) {
 		return nil, 0
 	}
 
-	dataBase := a.bot.GetDataBase()
-	_, err = dataBase.Exec(`
-UPDATE users 
-	SET balance_btc = balance_btc - ?, 
-	    balance = balance + ?
-WHERE id = ?;`,
-		amountBTC,
-		count,
-		s.User.ID)
-	if err != nil {
+	if err = a.repos.Users.ExchangeBTCToCurrency(s.Context(), s.User.ID, amountBTC, count); err != nil {
 		return err, 0
 	}
 
@@ -151,7 +106,7 @@ WHERE id = ?;`,
 
 func (a *Auth) UpgradeMinerLevel(s *model.Situation) (bool, error) {
 	var err error
-	s.User, err = a.GetUser(s.User.ID)
+	s.User, err = a.GetUser(s.Context(), s.User.ID)
 	if err != nil {
 		return false, err
 	}
@@ -164,14 +119,7 @@ func (a *Auth) UpgradeMinerLevel(s *model.Situation) (bool, error) {
 		return true, nil
 	}
 
-	dataBase := a.bot.GetDataBase()
-	_, err = dataBase.Exec(`
-UPDATE users 
-	SET balance_hash = balance_hash - ?, 
-	    miner_level = miner_level + 1
-WHERE id = ?;`,
-		model.AdminSettings.GetParams(s.BotLang).UpgradeMinerCost[s.User.MinerLevel],
-		s.User.ID)
+	err = a.repos.Users.UpgradeMiner(s.Context(), s.User.ID, model.AdminSettings.GetParams(s.BotLang).UpgradeMinerCost[s.User.MinerLevel])
 	if err != nil {
 		return false, err
 	}
@@ -218,7 +166,7 @@ func (a *Auth) sendInvitationToSubs(s *model.Situation, amount string) error {
 	msg := tgbotapi.NewMessage(s.User.ID, text)
 	msg.ReplyMarkup = msgs.NewIlMarkUp(
 		msgs.NewIlRow(msgs.NewIlURLButton("advertising_button", model.AdminSettings.GetAdvertUrl(s.BotLang, s.User.AdvertChannel))),
-		msgs.NewIlRow(msgs.NewIlDataButton("im_subscribe_button", "/withdrawal_money?"+amount)),
+		msgs.NewIlRow(msgs.NewIlDataButton("im_subscribe_button", "/withdrawal_money/"+amount)),
 	).Build(a.bot.Language[s.User.Language])
 
 	return a.msgs.SendMsgToUser(msg, s.User.ID)
@@ -235,17 +183,18 @@ func (a *Auth) CheckSubscribeToWithdrawal(s *model.Situation, amount int) bool {
 	}
 
 	s.User.Balance -= amount
-	dataBase := a.bot.GetDataBase()
-	rows, err := dataBase.Query(`
-UPDATE users 
-	SET balance = ?
-WHERE id = ?;`,
-		s.User.Balance,
-		s.User.ID)
-	if err != nil {
+	if err := a.repos.Users.SetBalance(s.Context(), s.User.ID, s.User.Balance); err != nil {
 		return false
 	}
-	_ = rows.Close()
+
+	model.WithdrawalRequests.WithLabelValues(
+		a.bot.BotLink,
+		a.bot.BotLang,
+	).Inc()
+	model.WithdrawalAmount.WithLabelValues(
+		a.bot.BotLink,
+		a.bot.BotLang,
+	).Add(float64(amount))
 
 	msg := tgbotapi.NewMessage(s.User.ID, a.bot.LangText(s.User.Language, "successfully_withdrawn"))
 	_ = a.msgs.SendMsgToUser(msg, s.User.ID)
@@ -264,19 +213,9 @@ func (a *Auth) GetABonus(s *model.Situation) error {
 	}
 
 	s.User.Balance += model.AdminSettings.GetParams(s.BotLang).BonusAmount
-	dataBase := a.bot.GetDataBase()
-	rows, err := dataBase.Query(`
-UPDATE users 
-	SET balance = ?, 
-	    take_bonus = ? 
-WHERE id = ?;`,
-		s.User.Balance,
-		true,
-		s.User.ID)
-	if err != nil {
+	if err := a.repos.Users.TakeBonus(s.Context(), s.User.ID, s.User.Balance); err != nil {
 		return err
 	}
-	_ = rows.Close()
 
 	text := a.bot.LangText(s.User.Language, "bonus_have_received")
 	return a.msgs.SendSimpleMsg(s.User.ID, text)
@@ -298,7 +237,7 @@ func (a *Auth) CheckSubscribe(s *model.Situation, source string) bool {
 	})
 
 	if err == nil {
-		if err := a.addMemberToSubsBase(s); err != nil {
+		if err := a.repos.Subs.Add(s.Context(), s.User.ID); err != nil {
 			return false
 		}
 		return checkMemberStatus(member)
@@ -318,55 +257,3 @@ func checkMemberStatus(member tgbotapi.ChatMember) bool {
 	}
 	return false
 }
-
-func (a *Auth) addMemberToSubsBase(s *model.Situation) error {
-	dataBase := a.bot.GetDataBase()
-	rows, err := dataBase.Query(`
-SELECT * FROM subs 
-	WHERE id = ?;`,
-		s.User.ID)
-	if err != nil {
-		return err
-	}
-
-	user, err := readUser(rows)
-	if err != nil {
-		return err
-	}
-
-	if user.ID != 0 {
-		return nil
-	}
-	rows, err = dataBase.Query(`
-INSERT INTO subs VALUES(?);`,
-		s.User.ID)
-	if err != nil {
-		return err
-	}
-	_ = rows.Close()
-	return nil
-}
-
-func readUser(rows *sql.Rows) (*model.User, error) {
-	defer rows.Close()
-
-	var users []*model.User
-
-	for rows.Next() {
-		var id int64
-
-		if err := rows.Scan(&id); err != nil {
-			return nil, model.ErrScanSqlRow
-		}
-
-		users = append(users, &model.User{
-			ID: id,
-		})
-	}
-	if len(users) == 0 {
-		users = append(users, &model.User{
-			ID: 0,
-		})
-	}
-	return users[0], nil
-}
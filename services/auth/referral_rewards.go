@@ -1,15 +1,15 @@
 package auth
 
 import (
-	"database/sql"
+	"context"
 	"strconv"
 	"strings"
 
 	"github.com/Stepan1328/miner-bot/model"
 )
 
-func (a *Auth) referralRewardSystem(botLang string, userID int64, lvl int) error {
-	user, err := a.GetUser(userID)
+func (a *Auth) referralRewardSystem(ctx context.Context, botLang string, userID int64, lvl int) error {
+	user, err := a.GetUser(ctx, userID)
 	if err != nil {
 		return err
 	}
@@ -17,37 +17,43 @@ func (a *Auth) referralRewardSystem(botLang string, userID int64, lvl int) error
 	refByLvl := allReferralsByLvl(user.AllReferrals)
 	refByLvl = increaseReferralOnLvl(refByLvl, lvl)
 
-	_, err = a.bot.GetDataBase().Exec(`
-UPDATE users SET
-	balance = balance + ?,
-	all_referrals = ?
-WHERE id = ?;`,
-		model.AdminSettings.GetParams(botLang).ReferralReward.GetReward(lvl, refByLvl[lvl-1]),
-		refByLvlToString(refByLvl),
-		userID)
+	err = a.repos.Users.AddReferralReward(ctx, userID,
+		a.referralReward(ctx, botLang, userID, lvl, refByLvl[lvl-1]),
+		refByLvlToString(refByLvl))
 	if err != nil {
 		return err
 	}
 
-	rows, err := a.bot.GetDataBase().Query(`
-SELECT father_id 
-	FROM users 
-WHERE id = ?`,
-		userID)
-	if err != nil {
-		return err
+	if lvl == model.AdminSettings.GetParams(botLang).ReferralReward.MaxLevel() {
+		return nil
 	}
 
-	fatherID, err := getFatherIDFromRow(rows)
+	return a.referralRewardSystem(ctx, botLang, user.FatherID, lvl+1)
+}
+
+// referralReward returns the reward for the referral on the lvl, the matrix
+// of the source the user came from overrides the global one
+func (a *Auth) referralReward(ctx context.Context, botLang string, userID int64, lvl, count int) int {
+	source, err := a.userSourceLink(ctx, userID)
 	if err != nil {
-		return err
+		a.msgs.SendNotificationToDeveloper("some err in get user source link: "+err.Error(), false)
 	}
 
-	if lvl == model.AdminSettings.GetParams(botLang).ReferralReward.MaxLevel() {
-		return nil
+	if source != nil && source.ReferralReward.MaxLevel() >= lvl {
+		return source.ReferralReward.GetGapByCount(lvl, count).Amount
 	}
 
-	return a.referralRewardSystem(botLang, fatherID, lvl+1)
+	return model.AdminSettings.GetParams(botLang).ReferralReward.GetReward(lvl, count)
+}
+
+// userSourceLink returns the source link the user came by or nil
+func (a *Auth) userSourceLink(ctx context.Context, userID int64) (*model.SourceLink, error) {
+	info, err := a.repos.Income.Get(ctx, userID)
+	if err != nil || info == nil || info.SourceHash == "" {
+		return nil, err
+	}
+
+	return a.repos.Links.GetSource(ctx, info.SourceHash)
 }
 
 func allReferralsByLvl(rawReferrals string) []int {
@@ -83,16 +89,3 @@ func refByLvlToString(refByLvl []int) string {
 
 	return strings.Join(rawLvls, "/")
 }
-
-func getFatherIDFromRow(rows *sql.Rows) (int64, error) {
-	defer rows.Close()
-
-	var fatherID int64
-	for rows.Next() {
-		if err := rows.Scan(&fatherID); err != nil {
-			return 0, err
-		}
-	}
-
-	return fatherID, nil
-}
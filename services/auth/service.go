@@ -6,14 +6,16 @@ import (
 )
 
 type Auth struct {
-	bot *model.GlobalBot
+	bot   *model.GlobalBot
+	repos *model.Repositories
 
 	msgs *msgs.Service
 }
 
-func NewAuthService(bot *model.GlobalBot, msgs *msgs.Service) *Auth {
+func NewAuthService(bot *model.GlobalBot, repos *model.Repositories, msgs *msgs.Service) *Auth {
 	return &Auth{
-		bot:  bot,
-		msgs: msgs,
+		bot:   bot,
+		repos: repos,
+		msgs:  msgs,
 	}
 }
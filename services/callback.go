@@ -1,14 +1,12 @@
 package services
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 
-	"github.com/Stepan1328/miner-bot/db"
-	"github.com/Stepan1328/miner-bot/log"
 	"github.com/Stepan1328/miner-bot/model"
-	"github.com/Stepan1328/miner-bot/utils"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/Stepan1328/miner-bot/services/administrator"
 	"github.com/bots-empire/base-bot/msgs"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/pkg/errors"
@@ -22,16 +20,14 @@ func (h *CallBackHandlers) GetHandler(command string) model.Handler {
 	return h.Handlers[command]
 }
 
-func (h *CallBackHandlers) Init(userSrv *Users) {
-	// Start commands
-	h.OnCommand("/language", userSrv.LanguageCommand)
+func (h *CallBackHandlers) Init(userSrv *Users, adminSrv *administrator.Admin) {
+	// Partner commands
+	h.OnCommand("/partner_menu", adminSrv.PartnerMenuCommand)
 
 	// Money commands
 	h.OnCommand("/make_money_click", userSrv.HandleClickCommand)
 	h.OnCommand("/upgrade_miner_lvl", userSrv.UpgradeMinerLvlCommand)
 	h.OnCommand("/send_bonus_to_user", userSrv.GetBonusCommand)
-	h.OnCommand("/withdrawal_money", userSrv.RecheckSubscribeCommand)
-	h.OnCommand("/promotion_case", userSrv.PromotionCaseCommand)
 	h.OnCommand("/get_reward", userSrv.GetRewardCommand)
 }
 
@@ -39,60 +35,47 @@ func (h *CallBackHandlers) OnCommand(command string, handler model.Handler) {
 	h.Handlers[command] = handler
 }
 
-func (u *Users) checkCallbackQuery(s *model.Situation, logger log.Logger, sortCentre *utils.Spreader) {
+// callbackHandler finds the handler of the callback. The routes of the users are
+// served for everyone, so the admin can press the buttons of the user messages,
+// the other callbacks of the admins are served by the admin panel
+func (u *Users) callbackHandler(s *model.Situation) model.Handler {
+	if handler := u.routes.Handler(s); handler != nil {
+		return handler
+	}
+
 	if strings.Contains(s.Params.Level, "admin") {
-		if err := u.admin.CheckAdminCallback(s); err != nil {
-			text := fmt.Sprintf("%s // %s // error with serve admin callback command: %s",
-				u.bot.BotLang,
-				u.bot.BotLink,
-				err.Error(),
-			)
-			u.Msgs.SendNotificationToDeveloper(text, false)
-
-			logger.Warn(text)
-		}
-		return
-	}
-
-	handler := model.Bots[s.BotLang].CallbackHandler.
-		GetHandler(s.Command)
-
-	if handler != nil {
-		sortCentre.ServeHandler(handler, s, func(err error) {
-			text := fmt.Sprintf("%s // %s // error with serve user callback command: %s",
-				u.bot.BotLang,
-				u.bot.BotLink,
-				err.Error(),
-			)
-			u.Msgs.SendNotificationToDeveloper(text, false)
-
-			logger.Warn(text)
-			u.smthWentWrong(s.CallbackQuery.Message.Chat.ID, s.User.Language)
-		})
-
-		return
-	}
-
-	text := fmt.Sprintf("%s // %s // get callback data='%s', but they didn't react in any way",
-		u.bot.BotLang,
-		u.bot.BotLink,
-		s.CallbackQuery.Data,
-	)
-	u.Msgs.SendNotificationToDeveloper(text, false)
-
-	logger.Warn(text)
+		return u.admin.CallbackHandler(s)
+	}
+
+	if handler := u.bot.CallbackHandler.GetHandler(s.Command); handler != nil {
+		return handler
+	}
+
+	return notHandled
 }
 
-func (u *Users) LanguageCommand(s *model.Situation) error {
-	lang := strings.Split(s.CallbackQuery.Data, "?")[1]
+// notHandled is the handler of the updates nobody reacts to, they are reported by the middleware
+func notHandled(*model.Situation) error {
+	return model.ErrCommandNotConverted
+}
 
-	level := db.GetLevel(s.BotLang, s.User.ID)
-	if strings.Contains(level, "admin") {
-		return nil
+func (u *Users) LanguageCommand(s *model.Situation, params route.Params) error {
+	lang := params.String("lang")
+	if _, exist := u.bot.Language[lang]; !exist {
+		return u.invalidCallback(s, errors.Errorf("unknown language %q", lang))
+	}
+
+	if err := u.auth.SetStartLanguage(s.Context(), s.User.ID, lang); err != nil {
+		return errors.Wrap(err, "set start language")
 	}
 
 	s.User.Language = lang
 
+	// the admin stays in the admin panel, the main menu would close it
+	if strings.Contains(s.Params.Level, "admin") {
+		return u.Msgs.SendAnswerCallback(s.CallbackQuery, u.bot.LangText(lang, "lang_button"))
+	}
+
 	return u.StartCommand(s)
 }
 
@@ -106,7 +89,7 @@ func (u *Users) HandleClickCommand(s *model.Situation) error {
 	}
 	_ = u.Msgs.SendAnswerCallback(s.CallbackQuery, u.bot.LangText(s.User.Language, "click_done"))
 
-	s.User, err = u.auth.GetUser(s.User.ID)
+	s.User, err = u.auth.GetUser(s.Context(), s.User.ID)
 	if err != nil {
 		return nil
 	}
@@ -148,28 +131,31 @@ func (u *Users) GetBonusCommand(s *model.Situation) error {
 	return u.auth.GetABonus(s)
 }
 
-func (u *Users) RecheckSubscribeCommand(s *model.Situation) error {
-	amount := strings.Split(s.CallbackQuery.Data, "?")[1]
+func (u *Users) RecheckSubscribeCommand(s *model.Situation, params route.Params) error {
+	amount := params.Int("amount")
+	if amount <= 0 {
+		return u.invalidCallback(s, errors.Errorf("invalid withdrawal amount %d", amount))
+	}
+
 	s.Message = &tgbotapi.Message{
-		Text: amount,
+		Text: strconv.Itoa(amount),
 	}
 	if err := u.Msgs.SendAnswerCallback(s.CallbackQuery, u.bot.LangText(s.User.Language, "invitation_to_subscribe")); err != nil {
 		return err
 	}
-	amountInt, _ := strconv.Atoi(amount)
 
-	if u.auth.CheckSubscribeToWithdrawal(s, amountInt) {
-		db.RdbSetUser(s.BotLang, s.User.ID, "main")
+	if u.auth.CheckSubscribeToWithdrawal(s, amount) {
+		u.finishDialog(s)
 
 		return u.StartCommand(s)
 	}
 	return nil
 }
 
-func (u *Users) PromotionCaseCommand(s *model.Situation) error {
-	cost, err := strconv.Atoi(strings.Split(s.CallbackQuery.Data, "?")[1])
-	if err != nil {
-		return err
+func (u *Users) PromotionCaseCommand(s *model.Situation, params route.Params) error {
+	cost := params.Int("cost")
+	if cost <= 0 {
+		return u.invalidCallback(s, errors.Errorf("invalid promotion cost %d", cost))
 	}
 
 	if s.User.Balance < cost {
@@ -177,7 +163,10 @@ func (u *Users) PromotionCaseCommand(s *model.Situation) error {
 		return u.Msgs.SendAnswerCallback(s.CallbackQuery, lowBalanceText)
 	}
 
-	db.RdbSetUser(s.BotLang, s.User.ID, s.CallbackQuery.Data)
+	if err := u.fsm.Enter(s, statePromotion, strconv.Itoa(cost)); err != nil {
+		return err
+	}
+
 	msg := tgbotapi.NewMessage(s.User.ID, u.bot.LangText(s.User.Language, "invitation_to_send_link_text"))
 	msg.ReplyMarkup = msgs.NewMarkUp(
 		msgs.NewRow(msgs.NewDataButton("withdraw_cancel")),
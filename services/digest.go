@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+)
+
+const (
+	digestHeader     = "<b>Daily digest %s</b>\nbots: %d, with anomalies: %d"
+	digestMaxLength  = 4000
+	digestErrorsShow = 5
+)
+
+func (u *Users) countHandlerError(command string) {
+	model.HandlerErrors.WithLabelValues(
+		u.bot.BotLink,
+		u.bot.BotLang,
+		command,
+	).Inc()
+}
+
+// SendDailyDigest sends the summary of every bot to the developers and admins,
+// the bots with anomalies go first
+func (u *Users) SendDailyDigest() {
+	digests := make([]*model.Digest, 0, len(model.Bots))
+	for _, bot := range model.Bots {
+		digest, err := model.CollectDigest(bot)
+		if err != nil {
+			u.Msgs.SendNotificationToDeveloper(bot.BotLang+" // failed collect digest: "+err.Error(), false)
+			continue
+		}
+
+		digests = append(digests, digest)
+	}
+
+	sort.Slice(digests, func(i, j int) bool {
+		iAnomalies, jAnomalies := len(digests[i].Anomalies()), len(digests[j].Anomalies())
+		if iAnomalies != jAnomalies {
+			return iAnomalies > jAnomalies
+		}
+
+		return digests[i].BotLang < digests[j].BotLang
+	})
+
+	var withAnomalies int
+	blocks := make([]string, 0, len(digests)+1)
+	for _, digest := range digests {
+		if len(digest.Anomalies()) != 0 {
+			withAnomalies++
+		}
+		blocks = append(blocks, formatDigest(digest))
+	}
+
+	header := fmt.Sprintf(digestHeader, time.Now().Format("02.01.2006"), len(digests), withAnomalies)
+	for i, text := range joinDigestBlocks(append([]string{header}, blocks...)) {
+		u.Msgs.SendNotificationToDeveloper(text, i == 0)
+
+		for adminID := range model.AdminSettings.AdminID {
+			if !u.isDeveloper(adminID) {
+				_ = u.Msgs.NewParseMessage(adminID, text)
+			}
+		}
+	}
+}
+
+func (u *Users) isDeveloper(userID int64) bool {
+	for _, developerID := range u.Msgs.Developers {
+		if developerID == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatDigest(d *model.Digest) string {
+	var text strings.Builder
+
+	sign := "🟢"
+	anomalies := d.Anomalies()
+	if len(anomalies) != 0 {
+		sign = "🔴"
+	}
+
+	text.WriteString(fmt.Sprintf("%s <b>%s</b> // %s\n", sign, d.BotLang, html.EscapeString(d.BotLink)))
+	if len(anomalies) != 0 {
+		text.WriteString("<b>⚠️ " + strings.Join(anomalies, ", ") + "</b>\n")
+	}
+
+	text.WriteString(fmt.Sprintf("updates: %d, errors: %d, panics: %d\n", d.Updates, d.TotalErrors(), d.Panics))
+	text.WriteString(fmt.Sprintf("users: %d (+%d new, %d blocked today, %d blocked total)\n",
+		d.TotalUsers, d.NewUsers, d.BlockedUsers, d.DeletedUsers))
+	text.WriteString(fmt.Sprintf("mailing: %d sent, %d blocked\n", d.MailingSent, d.MailingBlocked))
+	text.WriteString(fmt.Sprintf("economy: %d balance, %d hash, %.8f BTC\n", d.Balance, d.BalanceHash, d.BalanceBTC))
+	text.WriteString(fmt.Sprintf("withdrawals: %d for %d", d.Withdrawals, d.WithdrawalAmount))
+
+	commands := make([]string, 0, len(d.Errors))
+	for command := range d.Errors {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return d.Errors[commands[i]] > d.Errors[commands[j]]
+	})
+
+	for i, command := range commands {
+		if i == digestErrorsShow {
+			text.WriteString(fmt.Sprintf("\n  ... and %d more commands", len(commands)-digestErrorsShow))
+			break
+		}
+		text.WriteString(fmt.Sprintf("\n  %s: %d", html.EscapeString(command), d.Errors[command]))
+	}
+
+	return text.String()
+}
+
+// joinDigestBlocks packs the blocks into as few messages as the length limit allows
+func joinDigestBlocks(blocks []string) []string {
+	var (
+		messages []string
+		current  string
+	)
+
+	for _, block := range blocks {
+		if current != "" && len(current)+len(block)+2 > digestMaxLength {
+			messages = append(messages, current)
+			current = ""
+		}
+
+		if current != "" {
+			current += "\n\n"
+		}
+		current += block
+	}
+
+	if current != "" {
+		messages = append(messages, current)
+	}
+
+	return messages
+}
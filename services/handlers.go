@@ -1,12 +1,13 @@
 package services
 
 import (
-	"fmt"
+	"context"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/Stepan1328/miner-bot/db"
+	"github.com/Stepan1328/miner-bot/cfg"
 	"github.com/Stepan1328/miner-bot/log"
 	"github.com/Stepan1328/miner-bot/model"
 	"github.com/Stepan1328/miner-bot/services/administrator"
@@ -19,10 +20,8 @@ import (
 )
 
 const (
-	updateCounterHeader = "Today Update's counter: %d"
-	updatePrintHeader   = "update number: %d    // miner-bot-update:  %s %s"
-	extraneousUpdate    = "extraneous update"
-	godUserID           = 1418862576
+	updatePrintHeader = "update number: %d    // miner-bot-update:  %s %s"
+	extraneousUpdate  = "extraneous update"
 
 	oneSatoshi = 0.00000001
 )
@@ -40,15 +39,14 @@ func (h *MessagesHandlers) Init(userSrv *Users, adminSrv *administrator.Admin) {
 	h.OnCommand("/select_language", userSrv.SelectLangCommand)
 	h.OnCommand("/start", userSrv.StartCommand)
 	h.OnCommand("/admin", adminSrv.AdminLoginCommand)
+	h.OnCommand("/partner", adminSrv.PartnerMenuCommand)
 
 	// Main command
 	h.OnCommand("/main_make_money", userSrv.MakeMoneyCommand)
 	h.OnCommand("/make_money_click", userSrv.MakeClickCommand)
 	h.OnCommand("/make_money_buy_btc", userSrv.BuyBTCCommand)
-	h.OnCommand("/change_hash_to_btc", userSrv.ChangeHashToBTCCommand)
 	h.OnCommand("/make_money_lvl_up", userSrv.LvlUpMinerCommand)
 	h.OnCommand("/make_money_buy_currency", userSrv.BuyCurrencyCommand)
-	h.OnCommand("/change_btc_to_currency", userSrv.ChangeBTCToCurrencyCommand)
 	h.OnCommand("/main_profile", userSrv.SendProfileCommand)
 	h.OnCommand("/new_make_money", userSrv.MakeMoneyMsgCommand)
 	h.OnCommand("/main_money_for_a_friend", userSrv.MoneyForAFriendCommand)
@@ -61,11 +59,12 @@ func (h *MessagesHandlers) Init(userSrv *Users, adminSrv *administrator.Admin) {
 	h.OnCommand("/paypal_method", userSrv.PaypalReqCommand)
 	h.OnCommand("/credit_card_method", userSrv.CreditCardReqCommand)
 	h.OnCommand("/withdrawal_method", userSrv.WithdrawalMethodCommand)
-	h.OnCommand("/withdrawal_req_amount", userSrv.ReqWithdrawalAmountCommand)
-	h.OnCommand("/withdrawal_exit", userSrv.WithdrawalAmountCommand)
 
 	// Log out command
 	h.OnCommand("/admin_log_out", userSrv.AdminLogOutCommand)
+
+	// Cancel of any dialog
+	h.OnCommand("/cancel", userSrv.CancelCommand)
 }
 
 func (h *MessagesHandlers) OnCommand(command string, handler model.Handler) {
@@ -76,196 +75,173 @@ func (u *Users) ActionsWithUpdates(logger log.Logger, sortCentre *utils.Spreader
 	//start top handler
 	cron.AddFunc(gron.Every(1*xtime.Day).At("12:00"), u.TopListPlayers)
 
+	router := &router{
+		spreader:    sortCentre,
+		middlewares: u.middlewares(logger),
+	}
+
+	// the update is released after its handler, so it is marked with its own deadline
+	progress := utils.NewProgress(func(updateID, offset int) {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.App.UpdateDeadline())
+		defer cancel()
+
+		if err := u.updates.Done(ctx, updateID, offset); err != nil {
+			logger.Warn("failed mark update %d of %s as handled: %s", updateID, u.bot.BotLang, err.Error())
+		}
+	})
+	sortCentre.Track(progress)
+
+	updates := new(sync.WaitGroup)
 	for update := range u.bot.Chanel {
 		localUpdate := update
 
-		go u.checkUpdate(&localUpdate, logger, sortCentre)
+		// the context is created once for the update, so the check of the update, the lookup
+		// of the user and the handler share the deadline. It is released when the handler returns
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.App.UpdateDeadline())
+		if !u.beginUpdate(ctx, localUpdate.UpdateID, logger) {
+			cancel()
+			continue
+		}
+
+		progress.Hold(localUpdate.UpdateID)
+		updates.Add(1)
+		go func() {
+			defer updates.Done()
+			defer progress.Release(localUpdate.UpdateID)
+			u.checkUpdate(ctx, cancel, &localUpdate, logger, router)
+		}()
+	}
+
+	// the channel is closed on the shutdown, the updates taken from it are passed to the spreader
+	updates.Wait()
+}
+
+// beginUpdate reports whether the update is not handled yet, the update is
+// handled when its check fails because losing it is worse than the repeat
+func (u *Users) beginUpdate(ctx context.Context, updateID int, logger log.Logger) bool {
+	ok, err := u.updates.Begin(ctx, updateID)
+	if err != nil {
+		logger.Warn("failed check update %d of %s: %s", updateID, u.bot.BotLang, err.Error())
+		return true
 	}
+
+	return ok
 }
 
-func (u *Users) checkUpdate(update *tgbotapi.Update, logger log.Logger, sortCentre *utils.Spreader) {
+// checkUpdate passes the update to its handler, cancel is called when the update is dropped
+func (u *Users) checkUpdate(ctx context.Context, cancel context.CancelFunc, update *tgbotapi.Update, logger log.Logger, router *router) {
 	defer u.panicCather(update)
 
 	if update.Message == nil && update.CallbackQuery == nil {
+		cancel()
 		return
 	}
 
 	if update.Message != nil && update.Message.PinnedMessage != nil {
+		cancel()
 		return
 	}
 
-	u.printNewUpdate(update, logger)
 	if update.Message != nil {
 		var command string
-		user, err := u.auth.CheckingTheUser(update.Message)
+		user, err := u.auth.CheckingTheUser(ctx, update.Message)
 		if err == model.ErrNotSelectedLanguage {
 			command = "/select_language"
 		} else if err != nil {
+			cancel()
 			u.smthWentWrong(update.Message.Chat.ID, u.bot.BotLang)
 			logger.Warn("err with check user: %s", err.Error())
 			return
 		}
 
-		situation := createSituationFromMsg(u.bot.BotLang, update.Message, user)
+		situation, err := u.createSituationFromMsg(ctx, u.bot.BotLang, update.Message, user)
+		if err != nil {
+			cancel()
+			u.smthWentWrong(update.Message.Chat.ID, u.bot.BotLang)
+			logger.Warn("err with create situation from message: %s", err.Error())
+			return
+		}
 		situation.Command = command
+		situation.UpdateID = update.UpdateID
+		situation.SetContext(ctx)
 
-		u.checkMessage(situation, logger, sortCentre)
+		router.serve(situation, u.messageHandler(situation), cancel)
 		return
 	}
 
 	if update.CallbackQuery != nil {
-		if strings.Contains(update.CallbackQuery.Data, "/language") {
-			err := u.auth.SetStartLanguage(update.CallbackQuery)
-			if err != nil {
-				u.smthWentWrong(update.CallbackQuery.Message.Chat.ID, u.bot.BotLang)
-				logger.Warn("err with set start language: %s", err.Error())
-			}
-		}
-		situation, err := u.createSituationFromCallback(u.bot.BotLang, update.CallbackQuery)
+		situation, err := u.createSituationFromCallback(ctx, u.bot.BotLang, update.CallbackQuery)
 		if err != nil {
+			cancel()
 			u.smthWentWrong(update.CallbackQuery.Message.Chat.ID, u.bot.BotLang)
 			logger.Warn("err with create situation from callback: %s", err.Error())
 			return
 		}
+		situation.UpdateID = update.UpdateID
+		situation.SetContext(ctx)
 
-		u.checkCallbackQuery(situation, logger, sortCentre)
+		router.serve(situation, u.callbackHandler(situation), cancel)
 		return
 	}
-}
-
-func (u *Users) printNewUpdate(update *tgbotapi.Update, logger log.Logger) {
-	model.UpdateStatistic.Mu.Lock()
-	defer model.UpdateStatistic.Mu.Unlock()
-
-	model.UpdateStatistic.Counter++
-	model.SaveUpdateStatistic()
-
-	model.HandleUpdates.WithLabelValues(
-		u.bot.BotLink,
-		u.bot.BotLang,
-	).Inc()
 
-	if update.Message != nil {
-		if update.Message.Text != "" {
-			logger.Info(updatePrintHeader, model.UpdateStatistic.Counter, u.bot.BotLang, update.Message.Text)
-			return
-		}
-	}
-
-	if update.CallbackQuery != nil {
-		if update.CallbackQuery.Data == "/make_money_click" {
-			return
-		}
-
-		logger.Info(updatePrintHeader, model.UpdateStatistic.Counter, u.bot.BotLang, update.CallbackQuery.Data)
-		return
-	}
-
-	logger.Info(updatePrintHeader, model.UpdateStatistic.Counter, u.bot.BotLang, extraneousUpdate)
+	cancel()
 }
 
-func (u *Users) SendTodayUpdateMsg() {
-	model.UpdateStatistic.Mu.Lock()
-	defer model.UpdateStatistic.Mu.Unlock()
-
-	text := fmt.Sprintf(updateCounterHeader, model.UpdateStatistic.Counter)
-	u.Msgs.SendNotificationToDeveloper(text, true)
-
-	model.UpdateStatistic.Counter = 0
-}
+func (u *Users) createSituationFromMsg(ctx context.Context, botLang string, message *tgbotapi.Message, user *model.User) (*model.Situation, error) {
+	level, err := u.state.Level(ctx, message.From.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get level")
+	}
 
-func createSituationFromMsg(botLang string, message *tgbotapi.Message, user *model.User) *model.Situation {
 	return &model.Situation{
 		Message: message,
 		BotLang: botLang,
 		User:    user,
 		Params: &model.Parameters{
-			Level: db.GetLevel(botLang, message.From.ID),
+			Level: level,
 		},
-	}
+	}, nil
 }
 
-func (u *Users) createSituationFromCallback(botLang string, callbackQuery *tgbotapi.CallbackQuery) (*model.Situation, error) {
-	user, err := u.auth.GetUser(callbackQuery.From.ID)
+func (u *Users) createSituationFromCallback(ctx context.Context, botLang string, callbackQuery *tgbotapi.CallbackQuery) (*model.Situation, error) {
+	user, err := u.auth.GetUser(ctx, callbackQuery.From.ID)
 	if err != nil {
 		return &model.Situation{}, err
 	}
 
+	level, err := u.state.Level(ctx, callbackQuery.From.ID)
+	if err != nil {
+		return &model.Situation{}, errors.Wrap(err, "get level")
+	}
+
 	return &model.Situation{
 		CallbackQuery: callbackQuery,
 		BotLang:       botLang,
 		User:          user,
 		Command:       strings.Split(callbackQuery.Data, "?")[0],
 		Params: &model.Parameters{
-			Level: db.GetLevel(botLang, callbackQuery.From.ID),
+			Level: level,
 		},
 	}, nil
 }
 
-func (u *Users) checkMessage(situation *model.Situation, logger log.Logger, sortCentre *utils.Spreader) {
-
-	if model.Bots[situation.BotLang].MaintenanceMode {
-		if situation.User.ID != godUserID {
-			msg := tgbotapi.NewMessage(situation.User.ID, "The bot is under maintenance, please try again later")
-			_ = u.Msgs.SendMsgToUser(msg, situation.User.ID)
-			return
-		}
-	}
-	if situation.Command == "" {
-		situation.Command, situation.Err = u.bot.GetCommandFromText(
-			situation.Message, situation.User.Language, situation.User.ID)
+// messageHandler finds the handler of the message: the command, the input of the dialog or the admin panel
+func (u *Users) messageHandler(s *model.Situation) model.Handler {
+	if s.Command == "" {
+		s.Command, s.Err = u.bot.GetCommandFromText(s.Message, s.User.Language, s.User.ID)
 	}
 
-	if situation.Err == nil {
-		handler := model.Bots[situation.BotLang].MessageHandler.
-			GetHandler(situation.Command)
-
-		if handler != nil {
-			sortCentre.ServeHandler(handler, situation, func(err error) {
-				text := fmt.Sprintf("%s // %s // error with serve user msg command: %s\ncommand = '%s'",
-					u.bot.BotLang,
-					u.bot.BotLink,
-					err.Error(),
-					situation.Command,
-				)
-				u.Msgs.SendNotificationToDeveloper(text, false)
-
-				logger.Warn(text)
-				u.smthWentWrong(situation.Message.Chat.ID, situation.User.Language)
-			})
-			return
+	if s.Err == nil {
+		if handler := u.bot.MessageHandler.GetHandler(s.Command); handler != nil {
+			return handler
 		}
 	}
 
-	situation.Command = strings.Split(situation.Params.Level, "?")[0]
-
-	handler := model.Bots[situation.BotLang].MessageHandler.
-		GetHandler(situation.Command)
-
-	if handler != nil {
-		sortCentre.ServeHandler(handler, situation, func(err error) {
-			text := fmt.Sprintf("%s // %s // error with serve user level command: %s\ncommand = '%s'",
-				u.bot.BotLang,
-				u.bot.BotLink,
-				err.Error(),
-				situation.Command,
-			)
-			u.Msgs.SendNotificationToDeveloper(text, false)
-
-			logger.Warn(text)
-			u.smthWentWrong(situation.Message.Chat.ID, situation.User.Language)
-		})
-		return
+	if handler := u.fsm.Handler(s); handler != nil {
+		return handler
 	}
 
-	if err := u.admin.CheckAdminMessage(situation); err == nil {
-		return
-	}
-
-	u.smthWentWrong(situation.Message.Chat.ID, situation.User.Language)
-	if situation.Err != nil {
-		logger.Info(situation.Err.Error())
-	}
+	return u.admin.CheckAdminMessage
 }
 
 func (u *Users) smthWentWrong(chatID int64, lang string) {
@@ -282,7 +258,7 @@ func (u *Users) StartCommand(s *model.Situation) error {
 	}
 
 	text := u.bot.LangText(s.User.Language, "main_select_menu")
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
+	u.finishDialog(s)
 
 	msg := tgbotapi.NewMessage(s.User.ID, text)
 	msg.ReplyMarkup = createMainMenu().Build(u.bot.Language[s.User.Language])
@@ -305,7 +281,7 @@ func createMainMenu() msgs.MarkUp {
 }
 
 func (u *Users) MakeMoneyCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
+	u.finishDialog(s)
 	text := u.bot.LangText(s.User.Language, "main_select_menu")
 
 	msg := tgbotapi.NewMessage(s.User.ID, text)
@@ -320,7 +296,7 @@ func (u *Users) MakeMoneyCommand(s *model.Situation) error {
 }
 
 func (u *Users) MakeClickCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
+	u.finishDialog(s)
 
 	text, markUp := u.buildClickMsg(s.BotLang, s.User)
 
@@ -350,7 +326,9 @@ func (u *Users) buildClickMsg(botLang string, user *model.User) (string, *tgbota
 }
 
 func (u *Users) BuyBTCCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "/change_hash_to_btc")
+	if err := u.fsm.Enter(s, stateExchangeHash); err != nil {
+		return err
+	}
 
 	text := u.bot.LangText(s.User.Language, "change_buy_btc_text",
 		s.User.BalanceHash,
@@ -386,12 +364,11 @@ func (u *Users) ChangeHashToBTCCommand(s *model.Situation) error {
 		return errors.Wrap(err, "send successful message")
 	}
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
 	return u.StartCommand(s)
 }
 
 func (u *Users) LvlUpMinerCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
+	u.finishDialog(s)
 
 	if int8(len(getUpgradeMinerCost(s.BotLang))) == s.User.MinerLevel || int8(len(getUpgradeMinerCost(s.BotLang))) < s.User.MinerLevel {
 		return u.reachedMaxMinerLvl(s)
@@ -420,7 +397,9 @@ func (u *Users) reachedMaxMinerLvl(s *model.Situation) error {
 }
 
 func (u *Users) BuyCurrencyCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "/change_btc_to_currency")
+	if err := u.fsm.Enter(s, stateExchangeBTC); err != nil {
+		return err
+	}
 
 	text := u.bot.LangText(s.User.Language, "change_buy_currency_text",
 		s.User.BalanceBTC,
@@ -455,12 +434,11 @@ func (u *Users) ChangeBTCToCurrencyCommand(s *model.Situation) error {
 		return errors.Wrap(err, "send successful message")
 	}
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
 	return u.StartCommand(s)
 }
 
 func (u *Users) SendProfileCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
+	u.finishDialog(s)
 
 	text := u.bot.LangText(s.User.Language, "profile_text",
 		s.Message.From.FirstName,
@@ -490,9 +468,9 @@ func getFirstLvlRef(rawLvls string) int {
 }
 
 func (u *Users) MoneyForAFriendCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
+	u.finishDialog(s)
 
-	link, err := model.EncodeLink(u.bot.GetDataBase(), u.bot.BotLink, &model.ReferralLinkInfo{
+	link, err := model.EncodeLink(s.Context(), u.repos.Links, u.bot.BotLink, &model.ReferralLinkInfo{
 		ReferralID: s.User.ID,
 		Source:     "bot",
 	})
@@ -515,7 +493,7 @@ func (u *Users) SelectLangCommand(s *model.Situation) error {
 	for _, lang := range u.bot.LanguageInBot {
 		text += u.bot.LangText(lang, "select_lang_menu") + "\n"
 	}
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
+	u.finishDialog(s)
 
 	msg := tgbotapi.NewMessage(s.User.ID, text)
 	msg.ReplyMarkup = u.createLangMenu(u.bot.LanguageInBot)
@@ -528,7 +506,7 @@ func (u *Users) createLangMenu(languages []string) tgbotapi.InlineKeyboardMarkup
 
 	for _, lang := range languages {
 		markup.InlineKeyboard = append(markup.InlineKeyboard, []tgbotapi.InlineKeyboardButton{
-			tgbotapi.NewInlineKeyboardButtonData(u.bot.LangText(lang, "lang_button"), "/language?"+lang),
+			tgbotapi.NewInlineKeyboardButtonData(u.bot.LangText(lang, "lang_button"), "/language/"+lang),
 		})
 	}
 
@@ -536,7 +514,9 @@ func (u *Users) createLangMenu(languages []string) tgbotapi.InlineKeyboardMarkup
 }
 
 func (u *Users) SpendMoneyWithdrawalCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "withdrawal")
+	if err := u.fsm.Enter(s, stateWithdrawalMethod); err != nil {
+		return err
+	}
 
 	text := u.bot.LangText(s.User.Language, "select_payment")
 	markUp := msgs.NewMarkUp(
@@ -552,7 +532,9 @@ func (u *Users) SpendMoneyWithdrawalCommand(s *model.Situation) error {
 }
 
 func (u *Users) PaypalReqCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "/withdrawal_req_amount")
+	if err := u.fsm.Enter(s, stateWithdrawalDetails); err != nil {
+		return err
+	}
 
 	msg := tgbotapi.NewMessage(s.User.ID, u.bot.LangText(s.User.Language, "paypal_method"))
 	msg.ReplyMarkup = msgs.NewMarkUp(
@@ -563,7 +545,9 @@ func (u *Users) PaypalReqCommand(s *model.Situation) error {
 }
 
 func (u *Users) CreditCardReqCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "/withdrawal_req_amount")
+	if err := u.fsm.Enter(s, stateWithdrawalDetails); err != nil {
+		return err
+	}
 
 	msg := tgbotapi.NewMessage(s.User.ID, u.bot.LangText(s.User.Language, "credit_card_number"))
 	msg.ReplyMarkup = msgs.NewMarkUp(
@@ -574,7 +558,9 @@ func (u *Users) CreditCardReqCommand(s *model.Situation) error {
 }
 
 func (u *Users) WithdrawalMethodCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "/withdrawal_req_amount")
+	if err := u.fsm.Enter(s, stateWithdrawalDetails); err != nil {
+		return err
+	}
 
 	msg := tgbotapi.NewMessage(s.User.ID, u.bot.LangText(s.User.Language, "req_withdrawal_amount"))
 	msg.ReplyMarkup = msgs.NewMarkUp(
@@ -585,7 +571,9 @@ func (u *Users) WithdrawalMethodCommand(s *model.Situation) error {
 }
 
 func (u *Users) ReqWithdrawalAmountCommand(s *model.Situation) error {
-	db.RdbSetUser(s.BotLang, s.User.ID, "/withdrawal_exit")
+	if err := u.fsm.Enter(s, stateWithdrawalAmount); err != nil {
+		return err
+	}
 
 	msg := tgbotapi.NewMessage(s.User.ID, u.bot.LangText(s.User.Language, "req_withdrawal_amount"))
 
@@ -597,7 +585,7 @@ func (u *Users) WithdrawalAmountCommand(s *model.Situation) error {
 }
 
 func (u *Users) AdminLogOutCommand(s *model.Situation) error {
-	db.DeleteOldAdminMsg(s.BotLang, s.User.ID)
+	u.admin.DeleteOldAdminMsg(s.Context(), s.User.ID)
 
 	text := u.bot.AdminText(model.AdminLang(s.User.ID), "admin_log_out")
 	msg := tgbotapi.NewMessage(s.User.ID, text)
@@ -641,7 +629,7 @@ func (u *Users) MoreMoneyCommand(s *model.Situation) error {
 		s.BotLang,
 	).Inc()
 
-	db.RdbSetUser(s.BotLang, s.User.ID, "main")
+	u.finishDialog(s)
 	text := u.bot.LangText(s.User.Language, "more_money_text",
 		model.AdminSettings.GetParams(s.BotLang).BonusAmount,
 		model.AdminSettings.GetParams(s.BotLang).BonusAmount)
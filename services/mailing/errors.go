@@ -0,0 +1,44 @@
+package mailing
+
+import (
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+// blockedErrors are the telegram answers after which the user can't get messages anymore
+var blockedErrors = map[string]string{
+	"Forbidden: bot was blocked by the user":                 "blocked",
+	"Forbidden: bot can't initiate conversation with a user": "not_started",
+	"Forbidden: user is deactivated":                         "deactivated",
+	"Bad Request: chat not found":                            "chat_not_found",
+	"Forbidden: bot can't send messages to bots":             "bot_user",
+}
+
+func isBlockedError(err error) bool {
+	_, ok := blockedErrors[err.Error()]
+	return ok
+}
+
+// errorType groups the delivery error for the report
+func errorType(err error) string {
+	if errType, ok := blockedErrors[err.Error()]; ok {
+		return errType
+	}
+
+	tgErr := &tgbotapi.Error{}
+	if !errors.As(err, &tgErr) {
+		return "network"
+	}
+
+	switch {
+	case tgErr.RetryAfter != 0:
+		return "too_many_requests"
+	case strings.Contains(tgErr.Message, "wrong file identifier"):
+		return "wrong_file"
+	default:
+		return "code_" + strconv.Itoa(tgErr.Code)
+	}
+}
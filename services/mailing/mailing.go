@@ -0,0 +1,789 @@
+package mailing
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	statusActive      = "active"
+	statusNeedMailing = "mailing"
+
+	maxSendAttempts = 3
+
+	// ClickCommand is the callback of the advertisement button, the click
+	// is counted before the user gets the link
+	ClickCommand = "/mailing_click"
+)
+
+type MailingUser struct {
+	ID            int64
+	Language      string
+	AdvertChannel int
+	Variant       int // the post of the test the user gets
+}
+
+func (s *Service) startSenderHandler() {
+	defer close(s.done)
+
+	if err := s.resumeMailing(); err != nil {
+		s.sendErrorToAdmin(err)
+	}
+
+	for {
+		// the progress is saved after every batch, so the sender stops between them
+		if s.isStopping() {
+			return
+		}
+
+		if s.isCanceled() {
+			s.finish(model.MailingCanceled)
+			if !s.stopHandler() {
+				return
+			}
+			continue
+		}
+
+		users, err := s.getUsersWithMailing()
+		if err != nil {
+			s.errorHandler(err)
+			continue
+		}
+
+		if len(users) == 0 {
+			s.finish(model.MailingFinished)
+			if !s.stopHandler() {
+				return
+			}
+			continue
+		}
+
+		wg := &sync.WaitGroup{}
+		wg.Add(len(users))
+
+		for _, user := range users {
+			go s.sendMailToUser(wg, user)
+		}
+
+		wg.Wait()
+		s.saveProgress()
+	}
+}
+
+// resumeMailing continues the report of the mailing interrupted by the restart
+func (s *Service) resumeMailing() error {
+	dataBase := s.bot.GetDataBase()
+
+	report, err := model.GetRunningMailingReport(dataBase)
+	if err != nil {
+		return err
+	}
+
+	var waiting int
+	err = dataBase.QueryRow(`
+SELECT COUNT(*) FROM users WHERE status = ?;`,
+		statusNeedMailing).
+		Scan(&waiting)
+	if err != nil {
+		return errors.Wrap(err, "count mailing users")
+	}
+
+	if report == nil && waiting == 0 {
+		return nil
+	}
+
+	if report == nil {
+		report = &model.MailingReport{
+			StartedAt:  time.Now().Unix(),
+			Status:     model.MailingRunning,
+			WithButton: s.bot.ButtonUnderAdvert(),
+			Total:      waiting,
+		}
+		if err = model.CreateMailingReport(dataBase, report); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.report = report
+	s.mu.Unlock()
+
+	s.fillMessageMap()
+	return nil
+}
+
+// Start marks the active users of the segment in the channels and starts the mailing,
+// nil segment sends to every user of the channels
+func (s *Service) Start(startedBy int64, segment *model.Segment, channels []int) (*model.MailingReport, error) {
+	report := &model.MailingReport{
+		StartedBy:  startedBy,
+		WithButton: s.bot.ButtonUnderAdvert(),
+	}
+
+	return s.start(report, func() (int64, error) {
+		return markSegment(s.bot.GetDataBase(), segment, channels)
+	}, nil)
+}
+
+// StartABTest sends the variants to their percents of the segment audience,
+// the rest of the audience waits for the winner
+func (s *Service) StartABTest(startedBy int64, segment *model.Segment, channels []int, variants []*model.MailingVariant) (*model.MailingReport, error) {
+	report := &model.MailingReport{
+		StartedBy: startedBy,
+		Variants:  variants,
+	}
+
+	return s.start(report, func() (int64, error) {
+		return markSegment(s.bot.GetDataBase(), segment, channels)
+	}, func(report *model.MailingReport) error {
+		holdout, err := model.SplitMailingVariants(s.bot.GetDataBase(), report.ID, variants)
+		report.Total -= int(holdout)
+		return err
+	})
+}
+
+// StartWinner sends the variant with the best click-through to the holdout of the test
+func (s *Service) StartWinner(startedBy int64, testReportID int64) (*model.MailingReport, error) {
+	dataBase := s.bot.GetDataBase()
+
+	test, err := model.GetMailingReport(context.Background(), dataBase, testReportID)
+	if err != nil {
+		return nil, err
+	}
+	if test == nil || !test.ABTest() || test.Status == model.MailingRunning {
+		return nil, model.ErrNoMailingUsers
+	}
+
+	sent, err := model.WinnerSent(dataBase, testReportID)
+	if err != nil {
+		return nil, err
+	}
+	if sent {
+		return nil, model.ErrWinnerAlreadySent
+	}
+
+	statistics, _, err := model.GetVariantStatistics(dataBase, testReportID)
+	if err != nil {
+		return nil, err
+	}
+	winner := model.Winner(statistics)
+	if winner == -1 || winner >= len(test.Variants) {
+		return nil, model.ErrNoMailingUsers
+	}
+
+	report := &model.MailingReport{
+		StartedBy:    startedBy,
+		Variants:     []*model.MailingVariant{{Post: test.Variants[winner].Post, Percent: 100}},
+		TestReportID: testReportID,
+	}
+
+	return s.start(report, func() (int64, error) {
+		return model.MarkHoldoutMailing(dataBase, testReportID)
+	}, nil)
+}
+
+func markSegment(dataBase *sql.DB, segment *model.Segment, channels []int) (int64, error) {
+	if segment == nil {
+		segment = &model.Segment{}
+	}
+
+	return model.MarkSegmentMailing(dataBase, segment, channels, time.Now())
+}
+
+// start marks the users, saves the report and wakes up the sender,
+// created is called with the saved report before the sending
+func (s *Service) start(report *model.MailingReport, mark func() (int64, error), created func(report *model.MailingReport) error) (*model.MailingReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.report != nil {
+		return nil, model.ErrMailingInProgress
+	}
+
+	count, err := mark()
+	if err != nil {
+		return nil, s.abortStart(report, err)
+	}
+	if count == 0 {
+		return nil, model.ErrNoMailingUsers
+	}
+
+	report.StartedAt = time.Now().Unix()
+	report.Status = model.MailingRunning
+	report.Total = int(count)
+	report.Errors = make(map[string]int)
+	if err = model.CreateMailingReport(s.bot.GetDataBase(), report); err != nil {
+		return nil, s.abortStart(report, err)
+	}
+
+	if created != nil {
+		if err = created(report); err != nil {
+			return nil, s.abortStart(report, err)
+		}
+		if err = model.UpdateMailingReport(s.bot.GetDataBase(), report); err != nil {
+			return nil, s.abortStart(report, err)
+		}
+	}
+
+	s.report = report
+	s.fillMessageMap()
+
+	s.messages.SendNotificationToDeveloper(
+		fmt.Sprintf("%s // mailing #%d started for %d users", s.bot.BotLang, report.ID, report.Total),
+		false,
+	)
+
+	s.startSignaller <- true
+	return s.copyReport(), nil
+}
+
+// abortStart returns the marked users to the active ones, otherwise the sender would
+// resume the mailing after the restart, and saves the created report as failed
+func (s *Service) abortStart(report *model.MailingReport, cause error) error {
+	dataBase := s.bot.GetDataBase()
+	if err := model.CancelMailingUsers(dataBase); err != nil {
+		s.sendErrorToAdmin(err)
+	}
+
+	if report.ID != 0 {
+		report.Status = model.MailingFailed
+		report.FinishedAt = time.Now().Unix()
+		if err := model.UpdateMailingReport(dataBase, report); err != nil {
+			s.sendErrorToAdmin(err)
+		}
+	}
+
+	return cause
+}
+
+// Progress returns the copy of the running mailing report or nil
+func (s *Service) Progress() *model.MailingReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.copyReport()
+}
+
+func (s *Service) copyReport() *model.MailingReport {
+	if s.report == nil {
+		return nil
+	}
+
+	report := *s.report
+	report.Errors = make(map[string]int, len(s.report.Errors))
+	for errorType, count := range s.report.Errors {
+		report.Errors[errorType] = count
+	}
+
+	return &report
+}
+
+// Cancel stops the running mailing after the current batch,
+// the users not reached yet stay active
+func (s *Service) Cancel() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.report == nil {
+		return false
+	}
+
+	s.canceled = true
+	return true
+}
+
+func (s *Service) isCanceled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.canceled
+}
+
+func (s *Service) saveProgress() {
+	report := s.Progress()
+	if report == nil {
+		return
+	}
+
+	if err := model.UpdateMailingReport(s.bot.GetDataBase(), report); err != nil {
+		s.sendErrorToAdmin(err)
+	}
+}
+
+func (s *Service) finish(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.canceled = false
+	if s.report == nil {
+		return
+	}
+
+	if status == model.MailingCanceled {
+		if err := model.CancelMailingUsers(s.bot.GetDataBase()); err != nil {
+			s.sendErrorToAdmin(err)
+		}
+	}
+
+	s.report.Status = status
+	s.report.FinishedAt = time.Now().Unix()
+	if err := model.UpdateMailingReport(s.bot.GetDataBase(), s.report); err != nil {
+		s.sendErrorToAdmin(err)
+	}
+
+	s.messages.SendNotificationToDeveloper(
+		fmt.Sprintf("%s // mailing #%d %s: sent %d, blocked %d, failed %d",
+			s.bot.BotLang, s.report.ID, status, s.report.Sent, s.report.Blocked, s.report.Failed),
+		false,
+	)
+	s.report = nil
+}
+
+func (s *Service) getUsersWithMailing() ([]*MailingUser, error) {
+	var reportID int64
+	if report := s.Progress(); report != nil {
+		reportID = report.ID
+	}
+
+	rows, err := s.bot.GetDataBase().Query(`
+SELECT u.id, u.lang, u.advert_channel, COALESCE(a.variant, 0)
+	FROM users u
+	LEFT JOIN mailing_ab_users a ON a.user_id = u.id AND a.report_id = ?
+WHERE u.status = ?
+ORDER BY u.id
+	LIMIT ?;`,
+		reportID,
+		statusNeedMailing,
+		s.usersPerIteration)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed execute query")
+	}
+
+	return readUsersFromRows(rows)
+}
+
+func readUsersFromRows(rows *sql.Rows) ([]*MailingUser, error) {
+	defer rows.Close()
+
+	var users []*MailingUser
+
+	for rows.Next() {
+		user := &MailingUser{}
+
+		if err := rows.Scan(
+			&user.ID,
+			&user.Language,
+			&user.AdvertChannel,
+			&user.Variant); err != nil {
+			return nil, errors.Wrap(err, "failed scan row")
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (s *Service) errorHandler(err error) {
+	s.sendErrorToAdmin(err)
+
+	select {
+	case <-time.After(3 * time.Second):
+	case <-s.stop:
+	}
+}
+
+func (s *Service) sendErrorToAdmin(err error) {
+	s.messages.SendNotificationToDeveloper(fmt.Sprintf("%s  //  error in mailing: %s", s.bot.BotLang, err), false)
+}
+
+// stopHandler waits for the next mailing, false is returned when the service is stopped
+func (s *Service) stopHandler() bool {
+	select {
+	case <-s.startSignaller:
+	case <-s.stop:
+		return false
+	}
+
+	s.messages.SendNotificationToDeveloper(fmt.Sprintf("%s  //  mailing handler started", s.bot.BotLang), false)
+	return true
+}
+
+func (s *Service) isStopping() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Service) sendMailToUser(wg *sync.WaitGroup, user *MailingUser) {
+	defer wg.Done()
+
+	// the admins don't get the advertisement and are not counted
+	if s.bot.CheckAdmin(user.ID) {
+		s.countResult(func(report *model.MailingReport) { report.Total-- })
+		s.markReadyMailingUser(user.ID)
+		return
+	}
+
+	err := s.sendAll(s.mailingMessages(user))
+	switch {
+	case err == nil:
+		s.countResult(func(report *model.MailingReport) { report.Sent++ })
+		s.markReadyMailingUser(user.ID)
+		s.markVariantDelivered(user.ID)
+		model.MailToUser.WithLabelValues(s.bot.BotLang).Inc()
+	case isBlockedError(err):
+		s.countResult(func(report *model.MailingReport) {
+			report.Blocked++
+			report.Errors[errorType(err)]++
+		})
+		if blockErr := s.bot.BlockUser(user.ID); blockErr != nil {
+			s.sendErrorToAdmin(blockErr)
+		}
+		model.BlockUser.WithLabelValues(s.bot.BotLang).Inc()
+	default:
+		s.countResult(func(report *model.MailingReport) {
+			report.Failed++
+			report.Errors[errorType(err)]++
+		})
+		s.markReadyMailingUser(user.ID)
+	}
+}
+
+func (s *Service) countResult(count func(report *model.MailingReport)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.report == nil {
+		return
+	}
+	if s.report.Errors == nil {
+		s.report.Errors = make(map[string]int)
+	}
+
+	count(s.report)
+}
+
+// send retries the message while telegram asks to wait
+func (s *Service) send(msg tgbotapi.Chattable) error {
+	var err error
+	for i := 0; i < maxSendAttempts; i++ {
+		if group, ok := msg.(tgbotapi.MediaGroupConfig); ok {
+			_, err = s.bot.Bot.SendMediaGroup(group)
+		} else {
+			_, err = s.bot.Bot.Send(msg)
+		}
+		if err == nil {
+			return nil
+		}
+
+		tgErr := &tgbotapi.Error{}
+		if !errors.As(err, &tgErr) || tgErr.RetryAfter == 0 {
+			return err
+		}
+
+		time.Sleep(time.Duration(tgErr.RetryAfter) * time.Second)
+	}
+
+	return err
+}
+
+// sendAll stops on the first failed message, so the user doesn't get the half of the post
+func (s *Service) sendAll(messages []tgbotapi.Chattable) error {
+	for _, msg := range messages {
+		if err := s.send(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) mailingMessages(user *MailingUser) []tgbotapi.Chattable {
+	s.mu.Lock()
+	var reportID int64
+	if s.report != nil {
+		reportID = s.report.ID
+	}
+	advert := s.adverts[user.AdvertChannel]
+	variants := s.variants
+	s.mu.Unlock()
+
+	if len(variants) != 0 {
+		variant := variants[0]
+		if user.Variant > 0 && user.Variant < len(variants) {
+			variant = variants[user.Variant]
+		}
+
+		messages := make([]tgbotapi.Chattable, len(variant))
+		for i, msg := range variant {
+			messages[i] = withChat(msg, user.ID, nil)
+		}
+		return messages
+	}
+
+	return s.addressAdvert(advert, user.ID, user.Language, user.AdvertChannel, reportID)
+}
+
+// addressAdvert addresses the messages of the advertisement to the user and
+// adds the button under the last one if it is on and the post has no own buttons
+func (s *Service) addressAdvert(advert []tgbotapi.Chattable, userID int64, userLang string, channel int, reportID int64) []tgbotapi.Chattable {
+	var button interface{}
+	if s.bot.ButtonUnderAdvert() && s.bot.AdvertisingChoice(channel) != model.AdvertPost {
+		markUp := msgs.NewIlMarkUp(
+			msgs.NewIlRow(msgs.NewIlDataButton("advertisement_button_text",
+				ClickCommand+"/"+strconv.FormatInt(reportID, 10)+"/"+strconv.Itoa(channel)),
+			),
+		).Build(s.bot.GetTexts(userLang))
+		button = &markUp
+	}
+
+	messages := make([]tgbotapi.Chattable, len(advert))
+	for i, msg := range advert {
+		if i != len(advert)-1 {
+			messages[i] = withChat(msg, userID, nil)
+			continue
+		}
+		messages[i] = withChat(msg, userID, button)
+	}
+
+	return messages
+}
+
+// withChat sets the chat of the message, the markup is replaced only when it is given
+func withChat(advert tgbotapi.Chattable, userID int64, markUp interface{}) tgbotapi.Chattable {
+	switch msg := advert.(type) {
+	case tgbotapi.PhotoConfig:
+		msg.BaseChat = chatWithMarkUp(msg.BaseChat, userID, markUp)
+		return msg
+	case tgbotapi.VideoConfig:
+		msg.BaseChat = chatWithMarkUp(msg.BaseChat, userID, markUp)
+		return msg
+	case tgbotapi.MessageConfig:
+		msg.BaseChat = chatWithMarkUp(msg.BaseChat, userID, markUp)
+		return msg
+	case tgbotapi.MediaGroupConfig:
+		msg.ChatID = userID
+		return msg
+	default:
+		return advert
+	}
+}
+
+func chatWithMarkUp(baseChat tgbotapi.BaseChat, userID int64, markUp interface{}) tgbotapi.BaseChat {
+	baseChat.ChatID = userID
+	if markUp != nil {
+		baseChat.ReplyMarkup = markUp
+	}
+
+	return baseChat
+}
+
+// SendTest sends the current advertisement of the channels to the users exactly
+// as the mailing would do it, the clicks on the test button are not counted.
+// It returns how many advertisements were delivered
+func (s *Service) SendTest(userIDs []int64, channels []int) (int, error) {
+	var (
+		delivered int
+		lastErr   error
+	)
+
+	for _, channel := range channels {
+		advert := newAdvert(s.bot, channel)
+		for _, userID := range userIDs {
+			err := s.sendAll(s.addressAdvert(advert, userID, s.bot.BotLang, channel, 0))
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			delivered++
+		}
+	}
+
+	if delivered == 0 && lastErr != nil {
+		return 0, errors.Wrap(lastErr, "send test")
+	}
+	return delivered, nil
+}
+
+// SendPost sends the post to the user the way the mailing sends it
+func (s *Service) SendPost(userID int64, post *model.Post) error {
+	var messages []tgbotapi.Chattable
+	for _, msg := range postMessages(post) {
+		messages = append(messages, withChat(msg, userID, nil))
+	}
+
+	return s.sendAll(messages)
+}
+
+// markVariantDelivered counts the delivery for the variant statistics of the A/B test
+func (s *Service) markVariantDelivered(userID int64) {
+	s.mu.Lock()
+	if s.report == nil || !s.report.ABTest() {
+		s.mu.Unlock()
+		return
+	}
+	reportID := s.report.ID
+	s.mu.Unlock()
+
+	if err := model.MarkVariantDelivered(s.bot.GetDataBase(), reportID, userID); err != nil {
+		s.sendErrorToAdmin(err)
+	}
+}
+
+func (s *Service) markReadyMailingUser(userID int64) {
+	_, err := s.bot.GetDataBase().Exec(`
+UPDATE users
+	SET status = ?
+WHERE id = ?;`,
+		statusActive,
+		userID)
+	if err != nil {
+		s.sendErrorToAdmin(errors.Wrap(err, "failed execute query"))
+	}
+}
+
+// fillMessageMap remembers the advertisements at the start, so the changes made
+// during the mailing don't get into it. It is called before the sender wakes up
+func (s *Service) fillMessageMap() {
+	s.adverts = make(map[int][]tgbotapi.Chattable, 10)
+	for i := 1; i < 6; i++ {
+		s.adverts[i] = newAdvert(s.bot, i)
+	}
+
+	s.variants = nil
+	for i, variant := range s.report.Variants {
+		s.variants = append(s.variants, postMessages(trackedPost(variant.Post, s.report.ID, i)))
+	}
+}
+
+// trackedPost replaces the links of the variant with the callbacks counting the clicks
+func trackedPost(post *model.Post, reportID int64, variant int) *model.Post {
+	tracked := post.Copy()
+	for i, row := range tracked.Buttons {
+		for j, button := range row {
+			if button.URL == "" {
+				continue
+			}
+			button.URL = ""
+			button.Data = fmt.Sprintf("%s/%d/%d/%d/%d", ClickCommand, reportID, variant, i, j)
+		}
+	}
+
+	return tracked
+}
+
+// newAdvert builds the messages of the channel advertisement without the chat
+func newAdvert(bot *model.GlobalBot, channel int) []tgbotapi.Chattable {
+	lang := bot.BotLang
+	text := bot.GetAdvertText(lang, channel)
+
+	switch bot.AdvertisingChoice(channel) {
+	case model.AdvertPost:
+		if post := bot.GetAdvertisingPost(channel); post != nil {
+			return postMessages(post)
+		}
+		return []tgbotapi.Chattable{tgbotapi.MessageConfig{Text: text}}
+	case "photo":
+		return []tgbotapi.Chattable{tgbotapi.PhotoConfig{
+			BaseFile: tgbotapi.BaseFile{
+				File: tgbotapi.FileID(bot.GetAdvertisingPhoto(lang, channel)),
+			},
+			Caption:   text,
+			ParseMode: "HTML",
+		}}
+	case "video":
+		return []tgbotapi.Chattable{tgbotapi.VideoConfig{
+			BaseFile: tgbotapi.BaseFile{
+				File: tgbotapi.FileID(bot.GetAdvertisingVideo(lang, channel)),
+			},
+			Caption:   text,
+			ParseMode: "HTML",
+		}}
+	default:
+		return []tgbotapi.Chattable{tgbotapi.MessageConfig{
+			Text: text,
+		}}
+	}
+}
+
+// postMessages builds the messages of the post without the chat. The album can't
+// have buttons, so the album with buttons is followed by the text with them
+func postMessages(post *model.Post) []tgbotapi.Chattable {
+	markUp := post.MarkUp()
+	baseChat := tgbotapi.BaseChat{}
+	if markUp != nil {
+		baseChat.ReplyMarkup = markUp
+	}
+
+	switch {
+	case post.Album():
+		textApart := markUp != nil
+		group := tgbotapi.MediaGroupConfig{}
+		for i, media := range post.Media {
+			var caption string
+			var entities []tgbotapi.MessageEntity
+			if i == 0 && !textApart {
+				caption, entities = post.Text, post.Entities
+			}
+			group.Media = append(group.Media, inputMedia(media, caption, entities))
+		}
+
+		if !textApart {
+			return []tgbotapi.Chattable{group}
+		}
+		return []tgbotapi.Chattable{group, tgbotapi.MessageConfig{
+			BaseChat: baseChat,
+			Text:     post.Text,
+			Entities: post.Entities,
+		}}
+	case len(post.Media) == 1 && post.Media[0].Type == model.PostMediaVideo:
+		return []tgbotapi.Chattable{tgbotapi.VideoConfig{
+			BaseFile: tgbotapi.BaseFile{
+				BaseChat: baseChat,
+				File:     tgbotapi.FileID(post.Media[0].FileID),
+			},
+			Caption:         post.Text,
+			CaptionEntities: post.Entities,
+		}}
+	case len(post.Media) == 1:
+		return []tgbotapi.Chattable{tgbotapi.PhotoConfig{
+			BaseFile: tgbotapi.BaseFile{
+				BaseChat: baseChat,
+				File:     tgbotapi.FileID(post.Media[0].FileID),
+			},
+			Caption:         post.Text,
+			CaptionEntities: post.Entities,
+		}}
+	default:
+		return []tgbotapi.Chattable{tgbotapi.MessageConfig{
+			BaseChat: baseChat,
+			Text:     post.Text,
+			Entities: post.Entities,
+		}}
+	}
+}
+
+func inputMedia(media *model.PostMedia, caption string, entities []tgbotapi.MessageEntity) interface{} {
+	if media.Type == model.PostMediaVideo {
+		video := tgbotapi.NewInputMediaVideo(tgbotapi.FileID(media.FileID))
+		video.Caption, video.CaptionEntities = caption, entities
+		return video
+	}
+
+	photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileID(media.FileID))
+	photo.Caption, photo.CaptionEntities = caption, entities
+	return photo
+}
@@ -0,0 +1,67 @@
+package mailing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Service sends the advertisement to the users marked for the mailing
+// and keeps the delivery report of the running mailing
+type Service struct {
+	bot      *model.GlobalBot
+	messages *msgs.Service
+
+	adverts  map[int][]tgbotapi.Chattable // the messages of every channel advertisement without the chat
+	variants [][]tgbotapi.Chattable       // the messages of the test variants, they replace the adverts
+
+	startSignaller    chan interface{}
+	usersPerIteration int
+
+	stop     chan struct{} // closed by Stop
+	stopOnce sync.Once
+	done     chan struct{} // closed when the sender exits
+
+	mu       sync.Mutex
+	report   *model.MailingReport // nil when no mailing runs
+	canceled bool
+}
+
+func NewService(bot *model.GlobalBot, messages *msgs.Service, userPerIter int) *Service {
+	return (&Service{
+		bot:               bot,
+		messages:          messages,
+		startSignaller:    make(chan interface{}, 1),
+		usersPerIteration: userPerIter,
+		stop:              make(chan struct{}),
+		done:              make(chan struct{}),
+	}).init()
+}
+
+func (s *Service) init() *Service {
+	go s.startSenderHandler()
+	return s
+}
+
+// Stop makes the sender save the progress of the running mailing and exit. The users
+// left in the mailing stay marked, so the mailing is resumed after the restart
+func (s *Service) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stopping is closed when the service is being stopped
+func (s *Service) Stopping() <-chan struct{} {
+	return s.stop
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+// MailingClickCommand counts the click on the advertisement button of the mailing
+// and replaces the button with the link, so the next press opens it.
+// The button of the channel advertisement is "/report/channel"
+func (u *Users) MailingClickCommand(s *model.Situation, params route.Params) error {
+	reportID, channel := params.Int64("report"), params.Int("channel")
+
+	// the test mailing has no report and its clicks are not counted
+	if reportID != 0 {
+		if err := model.SaveMailingClick(s.Context(), u.bot.GetDataBase(), reportID, s.User.ID); err != nil {
+			return errors.Wrap(err, "save mailing click")
+		}
+	}
+
+	markUp := msgs.NewIlMarkUp(
+		msgs.NewIlRow(msgs.NewIlURLButton("advertisement_button_text", u.bot.GetAdvertURL(s.BotLang, channel))),
+	).Build(u.bot.GetTexts(s.User.Language))
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(s.User.ID, s.CallbackQuery.Message.MessageID, markUp)
+	if err := u.Msgs.SendMsgToUser(edit, s.User.ID); err != nil {
+		return errors.Wrap(err, "edit advertisement button")
+	}
+
+	return u.Msgs.SendAnswerCallback(s.CallbackQuery, u.bot.LangText(s.User.Language, "mailing_button_open"))
+}
+
+// VariantClickCommand counts the click on the link of the post variant,
+// its button is "/report/variant/row/column"
+func (u *Users) VariantClickCommand(s *model.Situation, params route.Params) error {
+	reportID, variant := params.Int64("report"), params.Int("variant")
+
+	report, err := model.GetMailingReport(s.Context(), u.bot.GetDataBase(), reportID)
+	if err != nil {
+		return errors.Wrap(err, "get mailing report")
+	}
+	if report == nil || variant < 0 || variant >= len(report.Variants) {
+		return u.invalidCallback(s, errors.Errorf("unknown variant %d of report %d", variant, reportID))
+	}
+
+	if err = model.SaveMailingClick(s.Context(), u.bot.GetDataBase(), reportID, s.User.ID); err != nil {
+		return errors.Wrap(err, "save mailing click")
+	}
+
+	markUp := report.Variants[variant].Post.MarkUp()
+	if markUp == nil {
+		return u.invalidCallback(s, errors.Errorf("variant %d of report %d has no buttons", variant, reportID))
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(s.User.ID, s.CallbackQuery.Message.MessageID, *markUp)
+	if err = u.Msgs.SendMsgToUser(edit, s.User.ID); err != nil {
+		return errors.Wrap(err, "edit variant buttons")
+	}
+
+	return u.Msgs.SendAnswerCallback(s.CallbackQuery, u.bot.LangText(s.User.Language, "mailing_button_open"))
+}
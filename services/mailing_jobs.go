@@ -0,0 +1,8 @@
+package services
+
+// RunMailingJobs starts the scheduled mailings of the bot which time has come
+func (u *Users) RunMailingJobs() {
+	if err := u.admin.RunDueMailingJobs(); err != nil {
+		u.Msgs.SendNotificationToDeveloper(u.bot.BotLang+" // failed run mailing jobs: "+err.Error(), false)
+	}
+}
@@ -1,67 +1,30 @@
 package services
 
 import (
-	"database/sql"
+	"context"
 
 	"github.com/Stepan1328/miner-bot/model"
 )
 
-func (u *Users) CreateNilTop(number int) error {
-	dataBase := u.bot.GetDataBase()
-	_, err := dataBase.Exec(`INSERT INTO top VALUES (?,?,?,?)`, number, 0, 0, 0)
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (u *Users) CreateNilTop(ctx context.Context, number int) error {
+	return u.repos.Top.Create(ctx, number)
 }
 
-func (u *Users) GetUserBalanceFromID(id int64) (int, error) {
-	var balance int
-	dataBase := u.bot.GetDataBase()
-	err := dataBase.QueryRow(`
-SELECT balance FROM users WHERE id = ?`, id).Scan(&balance)
+func (u *Users) GetUserBalanceFromID(ctx context.Context, id int64) (int, error) {
+	user, err := u.repos.Users.Get(ctx, id)
 	if err != nil {
 		return 0, err
 	}
 
-	return balance, nil
+	return user.Balance, nil
 }
 
-func (u *Users) GetUsers(limit int) ([]*model.User, error) {
-	dataBase := u.bot.GetDataBase()
-	rows, err := dataBase.Query(`
-SELECT id, balance FROM users ORDER BY balance DESC LIMIT ?`, limit)
+func (u *Users) GetUsers(ctx context.Context, limit int) ([]*model.User, error) {
+	users, err := u.repos.Users.TopByBalance(ctx, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	user, err := readUserBalance(rows)
-	if err != nil {
-		return nil, err
-	}
-
-	return user, nil
-}
-
-func readUserBalance(rows *sql.Rows) ([]*model.User, error) {
-	defer rows.Close()
-
-	var users []*model.User
-
-	for rows.Next() {
-		var id int64
-		var balance int
-
-		if err := rows.Scan(&id, &balance); err != nil {
-			return nil, model.ErrScanSqlRow
-		}
-
-		users = append(users, &model.User{
-			ID:      id,
-			Balance: balance,
-		})
-	}
 	if len(users) == 0 {
 		users = append(users, &model.User{
 			ID:      0,
@@ -71,72 +34,23 @@ func readUserBalance(rows *sql.Rows) ([]*model.User, error) {
 	return users, nil
 }
 
-func (u *Users) GetFromTop(topNumber int) (*model.Top, error) {
-	dataBase := u.bot.GetDataBase()
-
-	top := &model.Top{
-		Top: topNumber,
-	}
-
-	_ = dataBase.QueryRow(`SELECT user_id, time_on_top, balance FROM top WHERE top = ?;`,
-		topNumber).Scan(&top.UserID, &top.TimeOnTop, &top.Balance)
-
-	return top, nil
+func (u *Users) GetFromTop(ctx context.Context, topNumber int) (*model.Top, error) {
+	return u.repos.Top.Get(ctx, topNumber)
 }
 
-func (u *Users) GetTop() ([]*model.Top, error) {
-	dataBase := u.bot.GetDataBase()
-
-	rows, err := dataBase.Query(`SELECT * FROM top;`)
-	if err != nil {
-		return nil, err
-	}
-
-	top, err := u.ReadRows(rows)
-	if err != nil {
-		return nil, err
-	}
-
-	return top, nil
+func (u *Users) GetTop(ctx context.Context) ([]*model.Top, error) {
+	return u.repos.Top.GetAll(ctx)
 }
 
-func (u *Users) UpdateTop3Players(id int64, timeOnTop, topNumber, balance int) error {
-	dataBase := u.bot.GetDataBase()
-
-	_, err := dataBase.Exec(`UPDATE top SET user_id = ?, time_on_top = ?, balance = ? WHERE top = ?;`, id, timeOnTop, balance, topNumber)
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (u *Users) UpdateTop3Players(ctx context.Context, id int64, timeOnTop, topNumber, balance int) error {
+	return u.repos.Top.Update(ctx, &model.Top{
+		Top:       topNumber,
+		UserID:    id,
+		TimeOnTop: timeOnTop,
+		Balance:   balance,
+	})
 }
 
-func (u *Users) UpdateTop3Balance(id int64, balance int) error {
-	dataBase := u.bot.GetDataBase()
-
-	_, err := dataBase.Exec(`
-UPDATE users SET balance = ?
-	WHERE id = ?;`, balance, id)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (u *Users) ReadRows(rows *sql.Rows) ([]*model.Top, error) {
-	defer rows.Close()
-	var topArr []*model.Top
-
-	for rows.Next() {
-		top := &model.Top{}
-		err := rows.Scan(&top.Top, &top.UserID, &top.TimeOnTop, &top.Balance)
-		if err != nil {
-			return nil, err
-		}
-
-		topArr = append(topArr, top)
-	}
-
-	return topArr, nil
+func (u *Users) UpdateTop3Balance(ctx context.Context, id int64, balance int) error {
+	return u.repos.Users.SetBalance(ctx, id, balance)
 }
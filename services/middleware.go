@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/cfg"
+	"github.com/Stepan1328/miner-bot/fsm"
+	"github.com/Stepan1328/miner-bot/log"
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/services/administrator"
+	"github.com/Stepan1328/miner-bot/utils"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	maintenanceText = "The bot is under maintenance, please try again later"
+
+	// watchdogInterval is how often the running handlers are checked for the overrun
+	watchdogInterval = time.Second
+)
+
+// router serves the handlers of the messages and the callbacks in the spreader,
+// every handler is wrapped with the same middlewares
+type router struct {
+	spreader    *utils.Spreader
+	middlewares []model.Middleware
+}
+
+// serve passes the handler to the spreader, the context of the update is released when the
+// handler returns. The update dropped by the stopped spreader is released by its deadline
+func (r *router) serve(s *model.Situation, handler model.Handler, release context.CancelFunc) {
+	chain := model.Chain(handler, r.middlewares...)
+	r.spreader.ServeHandler(func(s *model.Situation) error {
+		defer release()
+		return chain(s)
+	}, s)
+}
+
+// middlewares are applied from the first to the last: the panics and the errors of the
+// checks are caught too, and the dropped updates are still counted and logged
+func (u *Users) middlewares(logger log.Logger) []model.Middleware {
+	return []model.Middleware{
+		u.recoverPanic,
+		u.withDeadline,
+		u.countUpdate,
+		u.logUpdate(logger),
+		u.reportError(logger),
+		u.checkBan(logger),
+		u.checkMaintenance,
+		u.limitRate(logger),
+	}
+}
+
+func (u *Users) recoverPanic(next model.Handler) model.Handler {
+	return func(s *model.Situation) error {
+		defer u.panicCather(&tgbotapi.Update{
+			UpdateID:      s.UpdateID,
+			Message:       s.Message,
+			CallbackQuery: s.CallbackQuery,
+		})
+
+		return next(s)
+	}
+}
+
+// withDeadline watches the handler by the deadline of the update context
+func (u *Users) withDeadline(next model.Handler) model.Handler {
+	return func(s *model.Situation) error {
+		deadline, ok := s.Context().Deadline()
+		if !ok {
+			deadline = time.Now().Add(cfg.App.UpdateDeadline())
+		}
+
+		stop := u.watchdog.Watch(s.Command, deadline)
+		defer stop()
+
+		return next(s)
+	}
+}
+
+// reportOverrun is called by the watchdog for the handler which is still running after the deadline
+func (u *Users) reportOverrun(command string, running time.Duration) {
+	model.HandlerOverruns.WithLabelValues(
+		u.bot.BotLink,
+		u.bot.BotLang,
+		command,
+	).Inc()
+
+	text := fmt.Sprintf("%s // %s // handler overruns the deadline: running %s\ncommand = '%s'",
+		u.bot.BotLang,
+		u.bot.BotLink,
+		running.Round(time.Second),
+		command,
+	)
+	u.Msgs.SendNotificationToDeveloper(text, false)
+}
+
+func (u *Users) countUpdate(next model.Handler) model.Handler {
+	return func(s *model.Situation) error {
+		kind := model.UpdateMessage
+		if s.CallbackQuery != nil {
+			kind = model.UpdateCallback
+		}
+
+		u.bot.UpdateStatistic.CountUpdate(kind)
+		u.bot.UpdateStatistic.CountCommand(s.Command)
+		model.HandleUpdates.WithLabelValues(
+			u.bot.BotLink,
+			u.bot.BotLang,
+		).Inc()
+
+		return next(s)
+	}
+}
+
+func (u *Users) logUpdate(logger log.Logger) model.Middleware {
+	return func(next model.Handler) model.Handler {
+		return func(s *model.Situation) error {
+			number := u.bot.UpdateStatistic.Total()
+
+			switch {
+			case s.Message != nil && s.Message.Text != "":
+				logger.Info(updatePrintHeader, number, u.bot.BotLang, s.Message.Text)
+			case s.CallbackQuery != nil && s.CallbackQuery.Data != "/make_money_click":
+				logger.Info(updatePrintHeader, number, u.bot.BotLang, s.CallbackQuery.Data)
+			case s.CallbackQuery == nil:
+				logger.Info(updatePrintHeader, number, u.bot.BotLang, extraneousUpdate)
+			}
+
+			return next(s)
+		}
+	}
+}
+
+// reportError sends the errors of the handlers to the developers, the user gets the excuse
+func (u *Users) reportError(logger log.Logger) model.Middleware {
+	return func(next model.Handler) model.Handler {
+		return func(s *model.Situation) error {
+			err := next(s)
+			switch {
+			case err == nil:
+				return nil
+			case err == model.ErrCommandNotConverted:
+				u.reportNotHandled(s, logger)
+				return nil
+			case errors.Is(err, fsm.ErrTransitionNotAllowed):
+				// the button of the other dialog is expected from the old messages
+				return u.showCurrentState(s)
+			}
+
+			kind := "msg"
+			if s.CallbackQuery != nil {
+				kind = "callback"
+			}
+
+			text := fmt.Sprintf("%s // %s // error with serve %s command: %s\ncommand = '%s'",
+				u.bot.BotLang,
+				u.bot.BotLink,
+				kind,
+				err.Error(),
+				s.Command,
+			)
+			u.Msgs.SendNotificationToDeveloper(text, false)
+			u.countHandlerError(s.Command)
+
+			logger.Warn(text)
+			u.smthWentWrong(s.User.ID, s.User.Language)
+			return nil
+		}
+	}
+}
+
+// showCurrentState answers the button pressed out of the dialog, the user is kept
+// in the current state and its menu is shown again
+func (u *Users) showCurrentState(s *model.Situation) error {
+	if s.CallbackQuery != nil {
+		_ = u.Msgs.SendAnswerCallback(s.CallbackQuery, "")
+	}
+
+	if shown, err := u.fsm.Menu(s); shown {
+		return err
+	}
+	_, err := u.admin.DialogMenu(s)
+	return err
+}
+
+// reportNotHandled answers the message nobody has handled, the callbacks are only reported
+func (u *Users) reportNotHandled(s *model.Situation, logger log.Logger) {
+	if s.CallbackQuery == nil {
+		u.smthWentWrong(s.User.ID, s.User.Language)
+		if s.Err != nil {
+			logger.Info(s.Err.Error())
+		}
+		return
+	}
+
+	text := fmt.Sprintf("%s // %s // get callback data='%s', but they didn't react in any way",
+		u.bot.BotLang,
+		u.bot.BotLink,
+		s.CallbackQuery.Data,
+	)
+	u.Msgs.SendNotificationToDeveloper(text, false)
+
+	logger.Warn(text)
+}
+
+func (u *Users) checkBan(logger log.Logger) model.Middleware {
+	return func(next model.Handler) model.Handler {
+		return func(s *model.Situation) error {
+			if model.AdminSettings.IsBanned(s.User.ID) {
+				logger.Info("%s // update of the banned user %d is ignored", u.bot.BotLang, s.User.ID)
+				return nil
+			}
+
+			return next(s)
+		}
+	}
+}
+
+func (u *Users) checkMaintenance(next model.Handler) model.Handler {
+	return func(s *model.Situation) error {
+		if u.bot.MaintenanceMode && !cfg.App.IsOwner(s.User.ID) {
+			msg := tgbotapi.NewMessage(s.User.ID, maintenanceText)
+			return u.Msgs.SendMsgToUser(msg, s.User.ID)
+		}
+
+		return next(s)
+	}
+}
+
+// limitRate drops the updates over the limit of the config, the admins are not limited
+func (u *Users) limitRate(logger log.Logger) model.Middleware {
+	return func(next model.Handler) model.Handler {
+		return func(s *model.Situation) error {
+			if !administrator.ContainsInAdmin(s.User.ID) && !u.limiter.Allow(s.User.ID) {
+				logger.Info("%s // update of the user %d is over the rate limit", u.bot.BotLang, s.User.ID)
+				return nil
+			}
+
+			return next(s)
+		}
+	}
+}
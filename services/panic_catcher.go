@@ -8,6 +8,7 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"github.com/Stepan1328/miner-bot/log"
+	"github.com/Stepan1328/miner-bot/model"
 )
 
 var (
@@ -28,6 +29,11 @@ func (u *Users) panicCather(update *tgbotapi.Update) {
 	)
 	panicLogger.Warn(panicText)
 
+	model.CaughtPanics.WithLabelValues(
+		u.bot.BotLink,
+		u.bot.BotLang,
+	).Inc()
+
 	u.Msgs.SendNotificationToDeveloper(panicText, false)
 
 	data, err := json.MarshalIndent(update, "", "  ")
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
+	"github.com/Stepan1328/miner-bot/services/mailing"
+)
+
+// the callbacks of the users with the parameters, the others are in CallBackHandlers
+func (u *Users) handleRoutes(r *route.Router) {
+	r.Handle("/language/{lang}", u.LanguageCommand)
+	r.Handle("/withdrawal_money/{amount:int}", u.RecheckSubscribeCommand)
+	r.Handle("/promotion_case/{cost:int}", u.PromotionCaseCommand)
+	r.Handle("/partner_source/{hash}", u.admin.PartnerSourceCommand)
+
+	r.Handle(mailing.ClickCommand+"/{report:int64}/{channel:int}", u.MailingClickCommand)
+	r.Handle(mailing.ClickCommand+"/{report:int64}/{variant:int}/{row:int}/{column:int}", u.VariantClickCommand)
+}
+
+// invalidCallback answers the callback whose data doesn't fit its route,
+// the user who hasn't chosen the language yet gets the text of the bot
+func (u *Users) invalidCallback(s *model.Situation, err error) error {
+	u.Msgs.SendNotificationToDeveloper(u.bot.BotLang+" // invalid callback: "+err.Error(), false)
+
+	lang := s.User.Language
+	if _, exist := u.bot.Language[lang]; !exist {
+		lang = s.BotLang
+	}
+
+	return u.Msgs.SendAnswerCallback(s.CallbackQuery, u.bot.LangText(lang, "invalid_callback"))
+}
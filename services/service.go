@@ -1,25 +1,66 @@
 package services
 
 import (
+	"context"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/cfg"
+	"github.com/Stepan1328/miner-bot/db"
+	"github.com/Stepan1328/miner-bot/fsm"
 	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/route"
 	"github.com/Stepan1328/miner-bot/services/administrator"
 	"github.com/Stepan1328/miner-bot/services/auth"
+	"github.com/Stepan1328/miner-bot/utils"
 	"github.com/bots-empire/base-bot/msgs"
 )
 
 type Users struct {
-	bot *model.GlobalBot
+	bot      *model.GlobalBot
+	repos    *model.Repositories
+	state    *db.State
+	fsm      *fsm.Machine
+	routes   *route.Router
+	updates  *db.Updates
+	limiter  *utils.Limiter
+	watchdog *utils.Watchdog
 
 	auth  *auth.Auth
 	admin *administrator.Admin
 	Msgs  *msgs.Service
 }
 
-func NewUsersService(bot *model.GlobalBot, auth *auth.Auth, admin *administrator.Admin, msgs *msgs.Service) *Users {
-	return &Users{
-		bot:   bot,
-		auth:  auth,
-		admin: admin,
-		Msgs:  msgs,
+func NewUsersService(bot *model.GlobalBot, repos *model.Repositories, state *db.State, updates *db.Updates, auth *auth.Auth, admin *administrator.Admin, msgs *msgs.Service) *Users {
+	u := &Users{
+		bot:     bot,
+		repos:   repos,
+		state:   state,
+		updates: updates,
+		limiter: utils.NewLimiter(cfg.App.RateLimit, time.Minute),
+		auth:    auth,
+		admin:   admin,
+		Msgs:    msgs,
+	}
+
+	u.watchdog = utils.NewWatchdog(watchdogInterval, u.reportOverrun)
+
+	u.fsm = fsm.NewMachine(state, db.MainLevel, u.rejectInput, u.StartCommand)
+	u.fsm.Register(u.states()...)
+
+	u.routes = route.NewRouter(u.invalidCallback)
+	u.handleRoutes(u.routes)
+	return u
+}
+
+// Stop stops the mailing of the bot, it waits for the scheduled mailing being started
+func (u *Users) Stop(ctx context.Context) error {
+	return u.admin.Stop(ctx)
+}
+
+// finishDialog moves the user back to the main level, the answer is already sent
+// so the failure is only reported to the developers
+func (u *Users) finishDialog(s *model.Situation) {
+	if err := u.fsm.Finish(s); err != nil {
+		u.Msgs.SendNotificationToDeveloper(u.bot.BotLang+" // failed finish dialog: "+err.Error(), false)
 	}
 }
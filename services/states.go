@@ -0,0 +1,103 @@
+package services
+
+import (
+	"time"
+
+	"github.com/Stepan1328/miner-bot/fsm"
+	"github.com/Stepan1328/miner-bot/model"
+)
+
+// the dialogs of the users, the resting state is main
+const (
+	stateExchangeHash fsm.State = "/change_hash_to_btc"
+	stateExchangeBTC  fsm.State = "/change_btc_to_currency"
+
+	stateWithdrawalMethod  fsm.State = "withdrawal"
+	stateWithdrawalDetails fsm.State = "/withdrawal_req_amount"
+	stateWithdrawalAmount  fsm.State = "/withdrawal_exit"
+
+	// the promotion waits for the link, the state only keeps the chosen case
+	statePromotion fsm.State = "/promotion_case"
+
+	exchangeTimeout   = 15 * time.Minute
+	withdrawalTimeout = 30 * time.Minute
+)
+
+func (u *Users) states() []*fsm.Definition {
+	return []*fsm.Definition{
+		{
+			Name:     stateExchangeHash,
+			Validate: u.exchangeAmount("invalid_amount_to_change_hash", exchangeHashRate),
+			Handler:  u.ChangeHashToBTCCommand,
+			Timeout:  exchangeTimeout,
+		},
+		{
+			Name:     stateExchangeBTC,
+			Validate: u.exchangeAmount("invalid_amount_to_change_btc", exchangeBTCRate),
+			Handler:  u.ChangeBTCToCurrencyCommand,
+			Timeout:  exchangeTimeout,
+		},
+
+		// the method is chosen by the buttons, any other text shows them again
+		{
+			Name:    stateWithdrawalMethod,
+			Next:    []fsm.State{stateWithdrawalDetails},
+			Handler: u.SpendMoneyWithdrawalCommand,
+			Menu:    u.SpendMoneyWithdrawalCommand,
+			Timeout: withdrawalTimeout,
+		},
+		{
+			Name:     stateWithdrawalDetails,
+			Next:     []fsm.State{stateWithdrawalAmount, stateWithdrawalMethod},
+			Validate: fsm.Text("credit_card_number"),
+			Handler:  u.ReqWithdrawalAmountCommand,
+			Menu:     u.CreditCardReqCommand,
+			Timeout:  withdrawalTimeout,
+		},
+		{
+			Name:     stateWithdrawalAmount,
+			Validate: fsm.Int(1, "incorrect_amount"),
+			Handler:  u.WithdrawalAmountCommand,
+			Timeout:  withdrawalTimeout,
+		},
+		{
+			Name:   statePromotion,
+			Params: []string{"cost"},
+		},
+	}
+}
+
+// exchangeAmount accepts the positive number, the balance is checked by the exchange
+func (u *Users) exchangeAmount(key string, rate func(botLang string) interface{}) fsm.Validator {
+	return func(s *model.Situation) error {
+		if _, ok := fsm.IntInput(s, 1); !ok {
+			return fsm.Reject(key, rate(s.BotLang))
+		}
+		return nil
+	}
+}
+
+func exchangeHashRate(botLang string) interface{} {
+	return model.AdminSettings.GetParams(botLang).ExchangeHashToBTC
+}
+
+func exchangeBTCRate(botLang string) interface{} {
+	return model.AdminSettings.GetParams(botLang).ExchangeBTCToCurrency * oneSatoshi
+}
+
+func (u *Users) rejectInput(s *model.Situation, inputErr *fsm.InputError) error {
+	text := u.bot.LangText(s.User.Language, inputErr.Key, inputErr.Args...)
+	return u.Msgs.NewParseMessage(s.User.ID, text)
+}
+
+// CancelCommand ends the dialog of the user or the admin, the main menu is shown without it
+func (u *Users) CancelCommand(s *model.Situation) error {
+	if canceled, err := u.fsm.Cancel(s); canceled {
+		return err
+	}
+	if canceled, err := u.admin.CancelDialog(s); canceled {
+		return err
+	}
+
+	return u.StartCommand(s)
+}
@@ -1,26 +1,30 @@
 package services
 
 import (
+	"context"
+
 	"github.com/Stepan1328/miner-bot/model"
 	"github.com/bots-empire/base-bot/msgs"
 )
 
 func (u *Users) TopListPlayers() {
-	countOfUsers := u.admin.CountUsers() / 10
-	users, err := u.GetUsers(countOfUsers)
+	ctx := context.Background()
+
+	countOfUsers := u.admin.CountUsers(ctx) / 10
+	users, err := u.GetUsers(ctx, countOfUsers)
 	if err != nil {
 		u.Msgs.SendNotificationToDeveloper("failed to get users: "+err.Error(), false)
 	}
 
-	err = u.createTopForMailing(users)
+	err = u.createTopForMailing(ctx, users)
 	if err != nil {
 		u.Msgs.SendNotificationToDeveloper("failed to create top: "+err.Error(), false)
 	}
 }
 
 func (u *Users) TopListPlayerCommand(s *model.Situation) error {
-	count := u.admin.CountUsers()
-	users, err := u.GetUsers(count)
+	count := u.admin.CountUsers(s.Context())
+	users, err := u.GetUsers(s.Context(), count)
 	if err != nil {
 		return err
 	}
@@ -30,14 +34,14 @@ func (u *Users) TopListPlayerCommand(s *model.Situation) error {
 		return nil
 	}
 
-	top, err := u.GetTop()
+	top, err := u.GetTop(s.Context())
 	if err != nil {
 		return err
 	}
 
 	if top == nil {
 		for i := 0; i <= 2; i++ {
-			err := u.CreateNilTop(i + 1)
+			err := u.CreateNilTop(s.Context(), i+1)
 			if err != nil {
 				return err
 			}
@@ -45,7 +49,7 @@ func (u *Users) TopListPlayerCommand(s *model.Situation) error {
 	}
 
 	for i := 0; i <= 2; i++ {
-		err := u.updateTop3(users[i].ID, i, users[i].Balance)
+		err := u.updateTop3(s.Context(), users[i].ID, i, users[i].Balance)
 		if err != nil {
 			return err
 		}
@@ -71,15 +75,15 @@ func (u *Users) TopListPlayerCommand(s *model.Situation) error {
 	return nil
 }
 
-func (u *Users) createTopForMailing(users []*model.User) error {
-	top, err := u.GetTop()
+func (u *Users) createTopForMailing(ctx context.Context, users []*model.User) error {
+	top, err := u.GetTop(ctx)
 	if err != nil {
 		return err
 	}
 
 	if top == nil {
 		for i := 0; i <= 2; i++ {
-			err := u.CreateNilTop(i + 1)
+			err := u.CreateNilTop(ctx, i+1)
 			if err != nil {
 				return err
 			}
@@ -87,7 +91,7 @@ func (u *Users) createTopForMailing(users []*model.User) error {
 	}
 
 	for i := 0; i <= 2; i++ {
-		err := u.updateTop3(users[i].ID, i+1, users[i].Balance)
+		err := u.updateTop3(ctx, users[i].ID, i+1, users[i].Balance)
 		if err != nil {
 			return err
 		}
@@ -158,19 +162,19 @@ func (u *Users) topPlayers(users []*model.User, i int) error {
 
 }
 
-func (u *Users) updateTop3(id int64, i int, balance int) error {
-	top, err := u.GetFromTop(i + 1)
+func (u *Users) updateTop3(ctx context.Context, id int64, i int, balance int) error {
+	top, err := u.GetFromTop(ctx, i+1)
 	if err != nil {
 		return err
 	}
 
 	if top.UserID != id {
-		err := u.UpdateTop3Players(id, 0, i+1, balance)
+		err := u.UpdateTop3Players(ctx, id, 0, i+1, balance)
 		if err != nil {
 			return err
 		}
 	} else {
-		err := u.UpdateTop3Players(top.UserID, top.TimeOnTop+1, top.Top, balance)
+		err := u.UpdateTop3Players(ctx, top.UserID, top.TimeOnTop+1, top.Top, balance)
 		if err != nil {
 			return err
 		}
@@ -181,7 +185,7 @@ func (u *Users) updateTop3(id int64, i int, balance int) error {
 
 func (u *Users) GetRewardCommand(s *model.Situation) error {
 	var userNum int
-	top, err := u.GetTop()
+	top, err := u.GetTop(s.Context())
 	if err != nil {
 		return err
 	}
@@ -192,12 +196,12 @@ func (u *Users) GetRewardCommand(s *model.Situation) error {
 		}
 	}
 
-	balance, err := u.GetUserBalanceFromID(s.User.ID)
+	balance, err := u.GetUserBalanceFromID(s.Context(), s.User.ID)
 	if err != nil {
 		return err
 	}
 
-	err = u.UpdateTop3Balance(s.User.ID,
+	err = u.UpdateTop3Balance(s.Context(), s.User.ID,
 		balance+model.AdminSettings.GlobalParameters[s.BotLang].Parameters.TopReward[userNum])
 	if err != nil {
 		return err
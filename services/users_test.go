@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/Stepan1328/miner-bot/db"
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/repository"
+	"github.com/Stepan1328/miner-bot/services/administrator"
+	"github.com/Stepan1328/miner-bot/services/auth"
+	"github.com/bots-empire/base-bot/msgs"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const testLang = "en"
+
+type sentMessage struct {
+	ChatID int64
+	Text   string
+}
+
+// fakeTelegram answers the bot api requests and remembers the sent messages
+type fakeTelegram struct {
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+
+	var result interface{}
+	switch path.Base(r.URL.Path) {
+	case "getMe":
+		result = tgbotapi.User{ID: 1, IsBot: true, FirstName: "miner", UserName: "miner_bot"}
+	default:
+		chatID, _ := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+
+		f.mu.Lock()
+		f.sent = append(f.sent, sentMessage{ChatID: chatID, Text: r.Form.Get("text")})
+		f.mu.Unlock()
+
+		result = tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: chatID, Type: "private"}}
+	}
+
+	data, _ := json.Marshal(result)
+	_ = json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: data})
+}
+
+func (f *fakeTelegram) messages() []sentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]sentMessage{}, f.sent...)
+}
+
+// newTestUsers builds the services of the bot on the memory repositories and the fake telegram
+func newTestUsers(t *testing.T) (*Users, *model.Repositories, *fakeTelegram) {
+	t.Helper()
+
+	telegram := &fakeTelegram{}
+	server := httptest.NewServer(telegram)
+	t.Cleanup(server.Close)
+
+	api, err := tgbotapi.NewBotAPIWithClient("token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatalf("create bot api: %v", err)
+	}
+
+	repos := repository.NewMemory()
+	bot := &model.GlobalBot{
+		BotLang:       testLang,
+		Bot:           api,
+		Repos:         repos,
+		LanguageInBot: []string{testLang},
+		Language: map[string]map[string]string{
+			testLang: {"main_select_menu": "main menu", "lang_button": "English"},
+		},
+		AdminLibrary: map[string]map[string]string{},
+	}
+
+	model.AdminSettings = &model.Admin{
+		AdminID: map[int64]*model.AdminUser{},
+		GlobalParameters: map[string]*model.GlobalParameters{
+			testLang: {Parameters: &model.Params{
+				ReferralReward: model.RewardsMatrix{{{Amount: 10, Level: 1, Index: 1}}},
+			}},
+		},
+	}
+
+	state := db.NewState(testLang, db.NewMemoryStore())
+	messages := msgs.NewService(bot, nil)
+	authService := auth.NewAuthService(bot, repos, messages)
+	admin := administrator.NewAdminService(bot, repos, state, nil, messages)
+
+	users := NewUsersService(bot, repos, state, db.NewUpdates(testLang, db.NewMemoryStore()), authService, admin, messages)
+	return users, repos, telegram
+}
+
+func startMessage(userID int64, text string) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		From: &tgbotapi.User{ID: userID},
+		Chat: &tgbotapi.Chat{ID: userID},
+		Text: text,
+	}
+}
+
+func TestCheckingTheUserBySourceLink(t *testing.T) {
+	users, repos, telegram := newTestUsers(t)
+	ctx := context.Background()
+
+	if err := repos.Links.Save(ctx, &model.ReferralLinkInfo{HashKey: "promo", Source: "promo"}); err != nil {
+		t.Fatal(err)
+	}
+	err := repos.Links.SaveSource(ctx, &model.SourceLink{
+		HashKey:     "promo",
+		Source:      "promo",
+		WelcomeText: "welcome",
+		BonusHash:   50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := users.auth.CheckingTheUser(ctx, startMessage(100, "/start promo"))
+	if err != nil {
+		t.Fatalf("check new user: %v", err)
+	}
+	if user.BalanceHash != 50 || user.Language != testLang {
+		t.Errorf("new user has hash %d and language %q", user.BalanceHash, user.Language)
+	}
+
+	saved, err := repos.Users.Get(ctx, 100)
+	if err != nil {
+		t.Fatalf("get saved user: %v", err)
+	}
+	if saved.BalanceHash != 50 {
+		t.Errorf("saved user has hash %d, want 50", saved.BalanceHash)
+	}
+
+	info, err := repos.Income.Get(ctx, 100)
+	if err != nil || info == nil || info.SourceHash != "promo" {
+		t.Errorf("income info is %+v, %v", info, err)
+	}
+
+	sent := telegram.messages()
+	if len(sent) != 1 || sent[0] != (sentMessage{ChatID: 100, Text: "welcome"}) {
+		t.Errorf("sent messages are %+v, want the welcome of the source", sent)
+	}
+
+	// the known user only adds the click
+	if _, err = users.auth.CheckingTheUser(ctx, startMessage(100, "/start promo")); err != nil {
+		t.Fatalf("check known user: %v", err)
+	}
+
+	source, err := repos.Links.GetSource(ctx, "promo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Clicks != 2 || source.Registrations != 1 {
+		t.Errorf("source has %d clicks and %d registrations, want 2 and 1", source.Clicks, source.Registrations)
+	}
+}
+
+func TestCheckingTheUserByReferralLink(t *testing.T) {
+	users, repos, _ := newTestUsers(t)
+	ctx := context.Background()
+
+	if err := repos.Users.Create(ctx, &model.User{ID: 1, Language: testLang}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Links.Save(ctx, &model.ReferralLinkInfo{HashKey: "friend", ReferralID: 1, Source: "bot"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := users.auth.CheckingTheUser(ctx, startMessage(100, "/start friend")); err != nil {
+		t.Fatalf("check new user: %v", err)
+	}
+
+	father, err := repos.Users.Get(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if father.Balance != 10 || father.AllReferrals != "1" {
+		t.Errorf("referral has balance %d and referrals %q, want 10 and \"1\"", father.Balance, father.AllReferrals)
+	}
+
+	source, err := repos.Links.GetSource(ctx, "friend")
+	if err != nil || source != nil {
+		t.Errorf("the referral link of the user is adopted as the source: %+v, %v", source, err)
+	}
+}
+
+func TestStartCommand(t *testing.T) {
+	users, _, telegram := newTestUsers(t)
+	ctx := context.Background()
+
+	user, err := users.auth.CheckingTheUser(ctx, startMessage(100, "/start"))
+	if err != nil {
+		t.Fatalf("check new user: %v", err)
+	}
+
+	s := &model.Situation{
+		Message: startMessage(100, "/start"),
+		BotLang: testLang,
+		User:    user,
+		Params:  &model.Parameters{},
+	}
+	s.SetContext(ctx)
+	if err = users.StartCommand(s); err != nil {
+		t.Fatalf("start command: %v", err)
+	}
+
+	sent := telegram.messages()
+	if len(sent) != 1 || sent[0] != (sentMessage{ChatID: 100, Text: "main menu"}) {
+		t.Errorf("sent messages are %+v, want the main menu", sent)
+	}
+
+	level, err := users.state.Level(ctx, 100)
+	if err != nil || level != db.MainLevel {
+		t.Errorf("level is %q, %v, want %q", level, err, db.MainLevel)
+	}
+}
+
+func TestLanguageCommandInAdminPanel(t *testing.T) {
+	users, repos, telegram := newTestUsers(t)
+	ctx := context.Background()
+
+	user, err := users.auth.CheckingTheUser(ctx, startMessage(100, "/start"))
+	if err != nil {
+		t.Fatalf("check new user: %v", err)
+	}
+	if err = users.state.SetLevel(ctx, 100, db.AdminLevel); err != nil {
+		t.Fatalf("set admin level: %v", err)
+	}
+
+	s := &model.Situation{
+		CallbackQuery: &tgbotapi.CallbackQuery{ID: "1", From: &tgbotapi.User{ID: 100}, Data: "/language/" + testLang},
+		BotLang:       testLang,
+		User:          user,
+		Params:        &model.Parameters{Level: db.AdminLevel},
+	}
+	s.SetContext(ctx)
+
+	handler := users.callbackHandler(s)
+	if err = handler(s); err != nil {
+		t.Fatalf("language command: %v", err)
+	}
+
+	stored, err := repos.Users.Get(ctx, 100)
+	if err != nil || stored.Language != testLang {
+		t.Fatalf("stored user is %+v, %v, want the language %q", stored, err, testLang)
+	}
+
+	for _, sent := range telegram.messages() {
+		if sent.Text == "main menu" {
+			t.Errorf("the admin got the main menu")
+		}
+	}
+
+	level, err := users.state.Level(ctx, 100)
+	if err != nil || level != db.AdminLevel {
+		t.Errorf("level is %q, %v, want %q", level, err, db.AdminLevel)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Stepan1328/miner-bot/log"
+	"github.com/Stepan1328/miner-bot/model"
+	"github.com/Stepan1328/miner-bot/services"
+	"github.com/Stepan1328/miner-bot/utils"
+)
+
+// shutdownTimeout is how long the updates already received are handled after the signal
+const shutdownTimeout = 30 * time.Second
+
+// waitForSignal blocks until the process gets SIGINT or SIGTERM
+func waitForSignal() os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	return <-signals
+}
+
+// shutdown stops getting the updates, handles the received ones, stops the mailings and closes the connections of the bots.
+// The connections are closed even when the deadline is reached, the handlers still running are lost
+func shutdown(srvs []*services.Users, spreaders []*utils.Spreader, handlers *sync.WaitGroup, logger log.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, bot := range model.Bots {
+		bot.StopUpdates()
+	}
+
+	status := "Bot is stopped"
+	if err := drainUpdates(ctx, spreaders, handlers); err != nil {
+		logger.Warn("updates are not drained: %s", err.Error())
+		status += ", some updates are not handled: " + err.Error()
+	}
+
+	for _, service := range srvs {
+		if err := service.Stop(ctx); err != nil {
+			logger.Warn("mailing is not stopped: %s", err.Error())
+			status += ", the mailing is not stopped: " + err.Error()
+		}
+	}
+
+	model.FlushUpdateStatistics(func(botLang string, err error) {
+		logger.Warn("failed flush update statistic of %s: %s", botLang, err.Error())
+	})
+
+	for _, service := range srvs {
+		service.Msgs.SendNotificationToDeveloper(status, false)
+	}
+
+	for botLang, bot := range model.Bots {
+		if err := bot.Close(); err != nil {
+			logger.Warn("failed close %s: %s", botLang, err.Error())
+		}
+	}
+
+	logger.Ok("All bots are stopped")
+}
+
+// drainUpdates waits until the updates are passed to the spreaders and the spreaders serve them
+func drainUpdates(ctx context.Context, spreaders []*utils.Spreader, handlers *sync.WaitGroup) error {
+	received := make(chan struct{})
+	go func() {
+		handlers.Wait()
+		close(received)
+	}()
+
+	select {
+	case <-received:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for _, spreader := range spreaders {
+		if err := spreader.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+)
+
+const (
+	cellWidth   = 56
+	cellHeight  = 28
+	labelWidth  = 64
+	glyphScale  = 3
+	glyphWidth  = 3
+	glyphHeight = 5
+	glyphGap    = 1
+)
+
+var (
+	chartBackground = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	chartText       = color.RGBA{R: 33, G: 33, B: 33, A: 255}
+	chartEmpty      = color.RGBA{R: 238, G: 238, B: 238, A: 255}
+)
+
+// glyphs is the tiny bitmap font for the symbols used in the charts
+var glyphs = map[rune][glyphHeight]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'.': {"...", "...", "...", "...", ".#."},
+	'%': {"#.#", "..#", ".#.", "#..", "#.#"},
+}
+
+// RenderHeatmap draws the table of percentages as the PNG image, the cell
+// color gets darker as the value grows. Rows may have different length.
+func RenderHeatmap(rowLabels []string, values [][]float64) ([]byte, error) {
+	var columns int
+	for _, row := range values {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, labelWidth+columns*cellWidth, (len(values)+1)*cellHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: chartBackground}, image.Point{}, draw.Src)
+
+	for k := 0; k < columns; k++ {
+		drawText(img, strconv.Itoa(k), labelWidth+k*cellWidth, 0, cellWidth)
+	}
+
+	for i, row := range values {
+		y := (i + 1) * cellHeight
+		if i < len(rowLabels) {
+			drawText(img, rowLabels[i], 0, y, labelWidth)
+		}
+
+		for k := 0; k < columns; k++ {
+			x := labelWidth + k*cellWidth
+			cell := image.Rect(x+1, y+1, x+cellWidth-1, y+cellHeight-1)
+			if k >= len(row) {
+				draw.Draw(img, cell, &image.Uniform{C: chartEmpty}, image.Point{}, draw.Src)
+				continue
+			}
+
+			draw.Draw(img, cell, &image.Uniform{C: heatColor(row[k])}, image.Point{}, draw.Src)
+			drawText(img, strconv.Itoa(int(row[k]+0.5))+"%", x, y, cellWidth)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// heatColor fades from white to green for 0..100 percents
+func heatColor(percent float64) color.RGBA {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+
+	fade := uint8(255 - percent*1.6)
+	return color.RGBA{R: fade, G: 255 - uint8(percent*0.5), B: fade, A: 255}
+}
+
+// drawText centers the text in the box of the given width and cell height
+func drawText(img *image.RGBA, text string, x, y, width int) {
+	textWidth := len(text)*(glyphWidth+glyphGap)*glyphScale - glyphGap*glyphScale
+	left := x + (width-textWidth)/2
+	top := y + (cellHeight-glyphHeight*glyphScale)/2
+
+	for _, symbol := range text {
+		glyph, ok := glyphs[symbol]
+		if ok {
+			for row, line := range glyph {
+				for col, pixel := range line {
+					if pixel != '#' {
+						continue
+					}
+
+					dot := image.Rect(
+						left+col*glyphScale, top+row*glyphScale,
+						left+(col+1)*glyphScale, top+(row+1)*glyphScale)
+					draw.Draw(img, dot, &image.Uniform{C: chartText}, image.Point{}, draw.Src)
+				}
+			}
+		}
+
+		left += (glyphWidth + glyphGap) * glyphScale
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows each user the limited number of the updates in the window,
+// the negative limit allows everything
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	users     map[int64]*window
+	lastSweep time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+func NewLimiter(limit int, per time.Duration) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		window:    per,
+		users:     make(map[int64]*window),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow counts the update of the user and reports whether it is in the limit
+func (l *Limiter) Allow(userID int64) bool {
+	if l.limit < 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > l.window {
+		l.sweep(now)
+	}
+
+	w, ok := l.users[userID]
+	if !ok || now.Sub(w.start) > l.window {
+		w = &window{start: now}
+		l.users[userID] = w
+	}
+
+	w.count++
+	return w.count <= l.limit
+}
+
+// sweep removes the windows which are over, the users who are gone would stay in memory without it
+func (l *Limiter) sweep(now time.Time) {
+	for id, w := range l.users {
+		if now.Sub(w.start) > l.window {
+			delete(l.users, id)
+		}
+	}
+
+	l.lastSweep = now
+}
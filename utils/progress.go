@@ -0,0 +1,63 @@
+package utils
+
+import "sync"
+
+// Progress tracks the updates in handling. The update is handled when it is released
+// by every holder: the goroutine which got it and the spreader pipe serving its handler
+type Progress struct {
+	mu      sync.Mutex
+	pending map[int]int // update id -> holders
+	last    int         // the greatest update id seen
+	offset  int
+
+	// done is called with the handled update and the first update which is not handled yet
+	done func(updateID, offset int)
+}
+
+func NewProgress(done func(updateID, offset int)) *Progress {
+	return &Progress{
+		pending: make(map[int]int),
+		done:    done,
+	}
+}
+
+func (p *Progress) Hold(updateID int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending[updateID]++
+	if updateID > p.last {
+		p.last = updateID
+	}
+}
+
+func (p *Progress) Release(updateID int) {
+	p.mu.Lock()
+	p.pending[updateID]--
+	if p.pending[updateID] > 0 {
+		p.mu.Unlock()
+		return
+	}
+
+	delete(p.pending, updateID)
+	offset := p.nextOffset()
+	p.mu.Unlock()
+
+	p.done(updateID, offset)
+}
+
+// nextOffset never moves back, the old update delivered again by the webhook is held after the newer ones
+func (p *Progress) nextOffset() int {
+	offset := p.last + 1
+	for id := range p.pending {
+		if id < offset {
+			offset = id
+		}
+	}
+
+	if offset > p.offset {
+		p.offset = offset
+	}
+
+	return p.offset
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -15,6 +16,13 @@ type Spreader struct {
 	ttl time.Duration
 	// mutex for running error when working with map
 	mu *sync.Mutex
+
+	// the pipes are closed and the new handlers are dropped
+	closed bool
+	// running blocks, waited on the shutdown
+	served sync.WaitGroup
+	// the updates are held while their handlers are in the pipes
+	progress *Progress
 }
 
 type block struct {
@@ -26,22 +34,23 @@ type block struct {
 }
 
 type condition struct {
-	handler      model.Handler
-	situation    *model.Situation
-	errorHandler func(error)
-}
-
-func (c condition) serve() error {
-	return c.handler(c.situation)
+	handler   model.Handler
+	situation *model.Situation
+	progress  *Progress
 }
 
-func (b *block) serve() {
+func (b *block) serve(served *sync.WaitGroup) {
+	served.Add(1)
 	go func() {
+		defer served.Done()
+
 		for c := range b.pipe {
 			b.lastUse = time.Now()
 
-			if err := c.serve(); err != nil {
-				c.errorHandler(err)
+			// the errors are reported by the middlewares of the handler
+			_ = c.handler(c.situation)
+			if c.progress != nil {
+				c.progress.Release(c.situation.UpdateID)
 			}
 		}
 	}()
@@ -66,6 +75,11 @@ func NewSpreader(maxTTL time.Duration) *Spreader {
 func (s *Spreader) collectObsoleteBlocks() {
 	for now := range time.Tick(time.Second) {
 		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+
 		for id, b := range s.blocks {
 			if b.lastUse.Add(s.ttl).Before(now) {
 				close(b.pipe)
@@ -76,23 +90,68 @@ func (s *Spreader) collectObsoleteBlocks() {
 	}
 }
 
-func (s *Spreader) ServeHandler(fn model.Handler, sit *model.Situation, errHandler func(error)) {
+func (s *Spreader) ServeHandler(fn model.Handler, sit *model.Situation) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.closed {
+		// the update stays in progress, so it is handled again after the restart
+		if s.progress != nil {
+			s.progress.Hold(sit.UpdateID)
+		}
+		return
+	}
+
 	b, ok := s.blocks[sit.User.ID]
 	if !ok {
 		b = &block{
 			pipe: make(chan condition, 10),
 		}
-		b.serve()
+		b.serve(&s.served)
 
 		s.blocks[sit.User.ID] = b
 	}
 
+	if s.progress != nil {
+		s.progress.Hold(sit.UpdateID)
+	}
+
 	b.pipe <- condition{
-		handler:      fn,
-		situation:    sit,
-		errorHandler: errHandler,
+		handler:   fn,
+		situation: sit,
+		progress:  s.progress,
+	}
+}
+
+// Track holds the updates in progress until their handlers are served
+func (s *Spreader) Track(progress *Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.progress = progress
+}
+
+// Shutdown closes the pipes and waits until the queued handlers are served,
+// the error of ctx is returned when it is done before
+func (s *Spreader) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for id, b := range s.blocks {
+		close(b.pipe)
+		delete(s.blocks, id)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.served.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Watchdog reports the handlers which run longer than their deadline.
+// The stuck handler is reported while it is still running, once
+type Watchdog struct {
+	mu      sync.Mutex
+	next    int
+	running map[int]*watch
+
+	report func(description string, running time.Duration)
+}
+
+type watch struct {
+	description string
+	started     time.Time
+	deadline    time.Time
+	reported    bool
+}
+
+// NewWatchdog checks the running handlers with the interval
+func NewWatchdog(interval time.Duration, report func(description string, running time.Duration)) *Watchdog {
+	w := &Watchdog{
+		running: make(map[int]*watch),
+		report:  report,
+	}
+
+	go w.check(interval)
+
+	return w
+}
+
+// Watch starts watching the handler, the returned stop is called when it is done
+func (w *Watchdog) Watch(description string, deadline time.Time) (stop func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.next
+	w.next++
+	w.running[id] = &watch{
+		description: description,
+		started:     time.Now(),
+		deadline:    deadline,
+	}
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		delete(w.running, id)
+	}
+}
+
+func (w *Watchdog) check(interval time.Duration) {
+	for now := range time.Tick(interval) {
+		overrun := make([]*watch, 0)
+
+		w.mu.Lock()
+		for _, watch := range w.running {
+			if !watch.reported && now.After(watch.deadline) {
+				watch.reported = true
+				overrun = append(overrun, watch)
+			}
+		}
+		w.mu.Unlock()
+
+		for _, watch := range overrun {
+			w.report(watch.description, now.Sub(watch.started))
+		}
+	}
+}